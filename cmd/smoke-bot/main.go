@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
 	"os/signal"
@@ -8,6 +9,9 @@ import (
 	
 	"github.com/glebk/smoke-bot/internal/bot"
 	"github.com/glebk/smoke-bot/internal/config"
+	"github.com/glebk/smoke-bot/internal/health"
+	"github.com/glebk/smoke-bot/internal/locale"
+	"github.com/glebk/smoke-bot/internal/metrics"
 	"github.com/glebk/smoke-bot/internal/repository/sqlite"
 	"github.com/glebk/smoke-bot/internal/service"
 )
@@ -19,6 +23,15 @@ func main() {
 		log.Fatalf("Failed to load config: %v", err)
 	}
 	
+	if err := locale.SetLanguage(cfg.Language); err != nil {
+		log.Fatalf("Failed to set language: %v", err)
+	}
+	for lang, activity := range cfg.Activities {
+		if err := locale.SetActivity(lang, activity.Forms, activity.Verb); err != nil {
+			log.Fatalf("Failed to set activity: %v", err)
+		}
+	}
+	
 	// Initialize database
 	db, err := sqlite.New(cfg.DatabasePath)
 	if err != nil {
@@ -31,9 +44,11 @@ func main() {
 	// Initialize repositories
 	userRepo := sqlite.NewUserRepository(db)
 	sessionRepo := sqlite.NewSessionRepository(db)
+	chatRepo := sqlite.NewChatRepository(db)
+	stateRepo := sqlite.NewStateRepository(db)
 	
 	// Initialize service
-	smokeService := service.NewSmokeService(userRepo, sessionRepo)
+	smokeService := service.NewSmokeService(userRepo, sessionRepo, chatRepo, stateRepo, cfg.SessionTimeout, cfg.StartCooldown, cfg.WorkingHours.Location, cfg.WaitForInvitees)
 	
 	// Initialize bot
 	telegramBot, err := bot.New(cfg.TelegramToken, smokeService, cfg)
@@ -45,16 +60,73 @@ func main() {
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
 	
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	
 	// Start bot in goroutine
+	var botErr error
 	go func() {
+		defer close(done)
 		log.Println("Bot started. Press Ctrl+C to stop.")
-		if err := telegramBot.Start(); err != nil {
-			log.Fatalf("Bot stopped with error: %v", err)
-		}
+		botErr = telegramBot.Start(ctx)
 	}()
 	
-	// Wait for stop signal
-	<-stop
-	log.Println("Shutting down gracefully...")
+	// Serve the health check for deployment probes
+	healthDone := make(chan struct{})
+	if cfg.HealthPort != "" {
+		healthServer := health.NewServer(cfg.HealthPort, map[string]health.Check{
+			"database": db.Ping,
+			"telegram": telegramBot.CheckAuthorized,
+		})
+		
+		go func() {
+			defer close(healthDone)
+			if err := healthServer.Run(ctx); err != nil {
+				log.Printf("Health check stopped with error: %v", err)
+			}
+		}()
+	} else {
+		close(healthDone)
+	}
+	
+	// Expose Prometheus metrics
+	metricsDone := make(chan struct{})
+	if cfg.MetricsPort != "" {
+		metrics.RegisterActiveSessions(smokeService.CountActiveSessions)
+		metricsServer := metrics.NewServer(cfg.MetricsPort)
+		
+		go func() {
+			defer close(metricsDone)
+			if err := metricsServer.Run(ctx); err != nil {
+				log.Printf("Metrics server stopped with error: %v", err)
+			}
+		}()
+	} else {
+		close(metricsDone)
+	}
+	
+	// Wait for stop signal, or for the bot to give up on Telegram
+	select {
+	case <-stop:
+		log.Println("Shutting down gracefully...")
+	case <-done:
+		log.Printf("Bot stopped with error: %v", botErr)
+	}
+	
+	// Let in-flight handlers finish before the database is closed
+	cancel()
+	<-done
+	<-healthDone
+	<-metricsDone
+	telegramBot.Stop()
+	
+	// Exit with an error so a supervisor restarts the bot, once the
+	// database is closed
+	if botErr != nil {
+		db.Close()
+		os.Exit(1)
+	}
+	
+	log.Println("Bot stopped")
 }
 
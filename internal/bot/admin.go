@@ -0,0 +1,303 @@
+package bot
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/glebk/smoke-bot/internal/domain"
+	"github.com/glebk/smoke-bot/internal/locale"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// requireAdmin checks that the message author is an admin and replies with
+// a permission error otherwise
+func (b *Bot) requireAdmin(message *tgbotapi.Message) bool {
+	if b.config.IsAdmin(message.From.ID) {
+		return true
+	}
+
+	b.sendMessage(message.Chat.ID, b.t(message.From.ID, "admin.only"))
+	return false
+}
+
+// handleForceComplete completes the chat's active session right away and runs the
+// regular auto-complete notification, so the flow can be checked without
+// waiting for the timeout
+func (b *Bot) handleForceComplete(message *tgbotapi.Message) {
+	if !b.requireAdmin(message) {
+		return
+	}
+
+	session, err := b.service.GetActiveSession(message.Chat.ID)
+	if err != nil {
+		log.Printf("Error getting active session: %v", err)
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "session.check_failed"))
+		return
+	}
+
+	if session == nil {
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "session.none"))
+		return
+	}
+
+	if err := b.service.CompleteSession(session.ID); err != nil {
+		log.Printf("Error force-completing session %d: %v", session.ID, err)
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "admin.complete_failed"))
+		return
+	}
+
+	b.notifySessionCompleted(session, completedManually, message.From.ID)
+
+	age := time.Since(session.CreatedAt).Round(time.Second)
+	b.sendMessage(message.Chat.ID, b.t(message.From.ID, "admin.completed", session.ID, age))
+}
+
+// sessionsPerMessage limits how many sessions a single /sessions message lists
+const sessionsPerMessage = 20
+
+// handleSessions sends the admin a private overview of all active sessions
+func (b *Bot) handleSessions(message *tgbotapi.Message) {
+	if !b.requireAdmin(message) {
+		return
+	}
+
+	sessions, err := b.service.GetAllActiveSessions()
+	if err != nil {
+		log.Printf("Error getting active sessions: %v", err)
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "admin.sessions_failed"))
+		return
+	}
+
+	if len(sessions) == 0 {
+		b.sendMessage(message.From.ID, b.t(message.From.ID, "admin.sessions_none"))
+		return
+	}
+
+	lang := b.language(message.From.ID)
+
+	for start := 0; start < len(sessions); start += sessionsPerMessage {
+		end := start + sessionsPerMessage
+		if end > len(sessions) {
+			end = len(sessions)
+		}
+
+		var sb strings.Builder
+		sb.WriteString(locale.Tr(lang, "admin.sessions_title", start+1, end, len(sessions)))
+		for _, session := range sessions[start:end] {
+			sb.WriteString(b.describeSession(lang, session))
+			sb.WriteString("\n")
+		}
+
+		b.sendMessage(message.From.ID, sb.String())
+	}
+}
+
+// describeSession renders a one-paragraph operational summary of a session
+func (b *Bot) describeSession(lang string, session *domain.Session) string {
+	initiatorName := fmt.Sprintf("user%d", session.InitiatorID)
+	if initiator, err := b.service.GetUser(session.InitiatorID); err == nil && initiator != nil {
+		initiatorName = initiator.Display()
+	}
+
+	chatName := locale.Tr(lang, "admin.private_chat")
+	if chat, err := b.service.GetChat(session.ChatID); err == nil && chat != nil && chat.Title != "" {
+		chatName = chat.Title
+	}
+
+	counts := make(map[domain.ResponseType]int)
+	responses, err := b.service.GetSessionResponses(session.ID)
+	if err != nil {
+		log.Printf("Error getting responses for session %d: %v", session.ID, err)
+	}
+	for _, resp := range responses {
+		counts[resp.Response]++
+	}
+
+	return locale.Tr(lang,
+		"admin.session",
+		session.ID,
+		initiatorName,
+		chatName,
+		time.Since(session.CreatedAt).Round(time.Minute),
+		counts[domain.ResponseAccepted],
+		counts[domain.ResponseAcceptedDelayed],
+		counts[domain.ResponseMaybe],
+		counts[domain.ResponseDenied],
+		counts[domain.ResponseRemote],
+	)
+}
+
+// handleResetRemote asks the admin to confirm clearing everyone's remote status
+func (b *Bot) handleResetRemote(message *tgbotapi.Message) {
+	if !b.requireAdmin(message) {
+		return
+	}
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(b.t(message.From.ID, "resetremote.confirm"), "resetremote:confirm"),
+			tgbotapi.NewInlineKeyboardButtonData(b.t(message.From.ID, "resetremote.abort"), "resetremote:abort"),
+		),
+	)
+
+	msg := tgbotapi.NewMessage(message.Chat.ID, b.t(message.From.ID, "resetremote.question"))
+	msg.ReplyMarkup = keyboard
+
+	if _, err := b.send(msg); err != nil {
+		log.Printf("Error sending reset confirmation: %v", err)
+	}
+}
+
+// handleResetRemoteCallback performs or aborts the remote status reset
+func (b *Bot) handleResetRemoteCallback(query *tgbotapi.CallbackQuery, choice string) {
+	if !b.config.IsAdmin(query.From.ID) {
+		b.answerCallback(query.ID, b.t(query.From.ID, "admin.only_short"))
+		return
+	}
+
+	var result string
+	switch choice {
+	case "confirm":
+		count, err := b.service.ResetAllRemoteStatus()
+		if err != nil {
+			log.Printf("Error resetting remote status: %v", err)
+			b.answerCallback(query.ID, b.t(query.From.ID, "resetremote.failed"))
+			return
+		}
+		result = b.t(query.From.ID, "resetremote.done", count)
+	case "abort":
+		result = b.t(query.From.ID, "resetremote.aborted")
+	default:
+		b.answerCallback(query.ID, b.t(query.From.ID, "callback.unknown_action"))
+		return
+	}
+
+	b.answerCallback(query.ID, result)
+
+	editMsg := tgbotapi.NewEditMessageText(query.Message.Chat.ID, query.Message.MessageID, result)
+	if _, err := b.send(editMsg); err != nil {
+		log.Printf("Error editing message: %v", err)
+	}
+}
+
+// handleHide hides a user from invitations and summaries, or shows them
+// again. The user is given as /hide @username.
+func (b *Bot) handleHide(message *tgbotapi.Message, hidden bool) {
+	if !b.requireAdmin(message) {
+		return
+	}
+
+	command := "/unhide"
+	if hidden {
+		command = "/hide"
+	}
+
+	username := b.commandArguments(message)
+	if username == "" {
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "hide.usage", command))
+		return
+	}
+
+	user, err := b.service.GetUserByUsername(username)
+	if err != nil {
+		log.Printf("Error getting user %s: %v", username, err)
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "hide.lookup_failed"))
+		return
+	}
+
+	if user == nil {
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "hide.not_found", username))
+		return
+	}
+
+	if err := b.service.SetHidden(user.ID, hidden); err != nil {
+		log.Printf("Error updating hidden status of user %d: %v", user.ID, err)
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "settings.save_failed"))
+		return
+	}
+
+	if hidden {
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "hide.on", user.Username))
+	} else {
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "hide.off", user.Username))
+	}
+}
+
+// handleExport sends the admin a CSV of all sessions and their response
+// counts. Hidden users are anonymized, "/export exclude" leaves them out.
+func (b *Bot) handleExport(message *tgbotapi.Message) {
+	if !b.requireAdmin(message) {
+		return
+	}
+
+	excludeHidden := strings.EqualFold(b.commandArguments(message), "exclude")
+
+	var buf bytes.Buffer
+	if err := b.service.ExportCSV(&buf, excludeHidden); err != nil {
+		log.Printf("Error exporting sessions: %v", err)
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "export.failed"))
+		return
+	}
+
+	doc := tgbotapi.NewDocument(message.From.ID, tgbotapi.FileBytes{
+		Name:  fmt.Sprintf("sessions-%s.csv", time.Now().Format("2006-01-02")),
+		Bytes: buf.Bytes(),
+	})
+
+	if _, err := b.send(doc); err != nil {
+		log.Printf("Error sending export: %v", err)
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "export.send_failed"))
+	}
+}
+
+// Announcements are sent in batches with a pause in between to stay below
+// Telegram's flood limits
+const (
+	announceBatchSize  = 25
+	announceBatchPause = time.Second
+)
+
+// handleAnnounce sends the admin's text to every visible, non-muted user and
+// reports back how many received it. Sending runs in the background so
+// other updates aren't held up by the pauses.
+func (b *Bot) handleAnnounce(message *tgbotapi.Message) {
+	if !b.requireAdmin(message) {
+		return
+	}
+
+	text := b.commandArguments(message)
+	if text == "" {
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "announce.usage"))
+		return
+	}
+
+	recipients, err := b.service.GetAnnouncementRecipients()
+	if err != nil {
+		log.Printf("Error getting announcement recipients: %v", err)
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "announce.recipients_failed"))
+		return
+	}
+
+	b.routines.Add(1)
+	go func() {
+		defer b.routines.Done()
+
+		reached := 0
+		for i, user := range recipients {
+			if i > 0 && i%announceBatchSize == 0 {
+				time.Sleep(announceBatchPause)
+			}
+
+			if _, err := b.sendWithRetry(tgbotapi.NewMessage(user.ID, "📣 "+text)); err != nil {
+				log.Printf("Error sending announcement to user %d: %v", user.ID, err)
+				continue
+			}
+			reached++
+		}
+
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "announce.done", reached, len(recipients)))
+	}()
+}
@@ -0,0 +1,108 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/glebk/smoke-bot/internal/domain"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// checkInitiatorApproved reports whether the message author may start a
+// session. When approval is required, first-time initiators are queued and
+// the admins are asked to decide.
+func (b *Bot) checkInitiatorApproved(message *tgbotapi.Message) bool {
+	if !b.config.RequireApproval || b.config.IsAdmin(message.From.ID) {
+		return true
+	}
+
+	user, err := b.service.GetUser(message.From.ID)
+	if err != nil || user == nil {
+		log.Printf("Error getting user for approval check: %v", err)
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "approval.check_failed"))
+		return false
+	}
+
+	switch user.Approval {
+	case domain.ApprovalApproved:
+		return true
+	case domain.ApprovalPending:
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "approval.pending"))
+		return false
+	case domain.ApprovalRejected:
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "approval.denied"))
+		return false
+	}
+
+	isNew, err := b.service.RequestApproval(user.ID)
+	if err != nil {
+		log.Printf("Error requesting approval for user %d: %v", user.ID, err)
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "approval.request_failed"))
+		return false
+	}
+
+	if isNew {
+		b.askAdminsForApproval(user)
+	}
+
+	b.sendMessage(message.Chat.ID, b.t(message.From.ID, "approval.requested"))
+	return false
+}
+
+// askAdminsForApproval sends every admin an approve/reject prompt for a
+// user, each in their own language
+func (b *Bot) askAdminsForApproval(user *domain.User) {
+	for _, adminID := range b.config.AdminIDs {
+		msg := tgbotapi.NewMessage(adminID, b.t(adminID, "approval.ask", user.FirstName, user.Username, user.ID))
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData(b.t(adminID, "approval.approve"), fmt.Sprintf("approve:%d", user.ID)),
+				tgbotapi.NewInlineKeyboardButtonData(b.t(adminID, "approval.reject"), fmt.Sprintf("reject:%d", user.ID)),
+			),
+		)
+
+		if _, err := b.send(msg); err != nil {
+			log.Printf("Error sending approval request to admin %d: %v", adminID, err)
+		}
+	}
+}
+
+// handleApprovalCallback applies an admin's decision from the approval prompt
+func (b *Bot) handleApprovalCallback(query *tgbotapi.CallbackQuery, action string, payload string) {
+	if !b.config.IsAdmin(query.From.ID) {
+		b.answerCallback(query.ID, b.t(query.From.ID, "admin.only_short"))
+		return
+	}
+
+	userID, err := strconv.ParseInt(payload, 10, 64)
+	if err != nil {
+		b.answerCallback(query.ID, "Invalid user ID")
+		return
+	}
+
+	approved := action == "approve"
+
+	user, err := b.service.SetApproval(userID, approved)
+	if err != nil {
+		log.Printf("Error saving approval for user %d: %v", userID, err)
+		b.answerCallback(query.ID, b.t(query.From.ID, "approval.save_failed"))
+		return
+	}
+
+	result := b.t(query.From.ID, "approval.approved", user.Username)
+	notice := b.t(user.ID, "approval.approved_notice")
+	if !approved {
+		result = b.t(query.From.ID, "approval.rejected", user.Username)
+		notice = b.t(user.ID, "approval.rejected_notice")
+	}
+
+	b.answerCallback(query.ID, result)
+
+	editMsg := tgbotapi.NewEditMessageText(query.Message.Chat.ID, query.Message.MessageID, result)
+	if _, err := b.send(editMsg); err != nil {
+		log.Printf("Error editing message: %v", err)
+	}
+
+	b.sendMessage(user.ID, notice)
+}
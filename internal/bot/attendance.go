@@ -0,0 +1,67 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/glebk/smoke-bot/internal/domain"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// askAttendance asks everyone who accepted a finished session whether they
+// actually came. Users who already answered, for example before the session
+// was extended, are not asked again.
+func (b *Bot) askAttendance(session *domain.Session, responses []*domain.SessionResponse) {
+	for _, resp := range responses {
+		if !resp.IsAcceptance() || resp.Attended != nil {
+			continue
+		}
+
+		user, err := b.service.GetUser(resp.UserID)
+		if err != nil || user == nil || user.IsHidden {
+			continue
+		}
+
+		msg := tgbotapi.NewMessage(user.ID, b.t(user.ID, "attendance.question"))
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData(b.t(user.ID, "attendance.yes"), fmt.Sprintf("attended:%d", session.ID)),
+				tgbotapi.NewInlineKeyboardButtonData(b.t(user.ID, "attendance.no"), fmt.Sprintf("missed:%d", session.ID)),
+			),
+		)
+
+		if _, err := b.send(msg); err != nil {
+			log.Printf("Error asking user %d about attendance: %v", user.ID, err)
+		}
+	}
+}
+
+// handleAttendanceCallback records the answer to an attendance question
+func (b *Bot) handleAttendanceCallback(query *tgbotapi.CallbackQuery, action string, payload string) {
+	sessionID, err := strconv.ParseInt(payload, 10, 64)
+	if err != nil {
+		b.answerCallback(query.ID, "Invalid session ID")
+		return
+	}
+
+	attended := action == "attended"
+
+	if err := b.service.ConfirmAttendance(sessionID, query.From.ID, attended); err != nil {
+		log.Printf("Error confirming attendance: %v", err)
+		b.answerCallback(query.ID, b.t(query.From.ID, "attendance.failed"))
+		return
+	}
+
+	result := b.t(query.From.ID, "attendance.recorded_yes")
+	if !attended {
+		result = b.t(query.From.ID, "attendance.recorded_no")
+	}
+
+	b.answerCallback(query.ID, result)
+
+	editMsg := tgbotapi.NewEditMessageText(query.Message.Chat.ID, query.Message.MessageID, result)
+	if _, err := b.send(editMsg); err != nil {
+		log.Printf("Error editing message: %v", err)
+	}
+}
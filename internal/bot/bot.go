@@ -1,14 +1,20 @@
 package bot
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"math"
+	"net/http"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/glebk/smoke-bot/internal/config"
 	"github.com/glebk/smoke-bot/internal/domain"
+	"github.com/glebk/smoke-bot/internal/locale"
 	"github.com/glebk/smoke-bot/internal/service"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
@@ -16,8 +22,33 @@ import (
 // Bot represents the Telegram bot
 type Bot struct {
 	api     *tgbotapi.BotAPI
+	sender  sender
 	service *service.SmokeService
 	config  *config.Config
+
+	// Response notifications waiting for the debounce window, by session
+	pendingMu            sync.Mutex
+	pendingNotifications map[int64]*notificationBatch
+
+	// The last button each user pressed, to drop double taps
+	callbacksMu     sync.Mutex
+	recentCallbacks map[int64]lastTap
+
+	// Pending reminders for initiators nobody answered yet, by session
+	nudgeMu     sync.Mutex
+	nudgeTimers map[int64]*time.Timer
+
+	// Rate-limited messages waiting to be sent again
+	retryMu     sync.Mutex
+	retryTimers map[int]*time.Timer
+	nextRetryID int
+
+	// When each user last sent /feedback, to limit how often they can
+	feedbackMu   sync.Mutex
+	lastFeedback map[int64]time.Time
+
+	// routines tracks background routines so Start can wait for them
+	routines sync.WaitGroup
 }
 
 // New creates a new Bot instance
@@ -29,55 +60,179 @@ func New(token string, service *service.SmokeService, cfg *config.Config) (*Bot,
 
 	log.Printf("Authorized on account %s", api.Self.UserName)
 
+	var out sender = api
+	if cfg.DryRun {
+		log.Printf("Dry run: outgoing messages are logged, not sent")
+		out = &logSender{}
+	}
+
 	return &Bot{
-		api:     api,
-		service: service,
-		config:  cfg,
+		api:                  api,
+		sender:               out,
+		service:              service,
+		config:               cfg,
+		pendingNotifications: make(map[int64]*notificationBatch),
+		recentCallbacks:      make(map[int64]lastTap),
+		nudgeTimers:          make(map[int64]*time.Timer),
+		retryTimers:          make(map[int]*time.Timer),
+		lastFeedback:         make(map[int64]time.Time),
 	}, nil
 }
 
-// Start starts the bot
-func (b *Bot) Start() error {
-	u := tgbotapi.NewUpdate(0)
-	u.Timeout = 60
+// Start starts the bot and processes updates until ctx is cancelled. It
+// returns once the update loop and all background routines have stopped.
+// It returns an error when polling Telegram fails for good, see pollUpdates.
+func (b *Bot) Start(ctx context.Context) error {
+	// Resume after the last handled update, so a restart neither repeats
+	// nor skips any
+	offset, err := b.service.GetUpdateOffset()
+	if err != nil {
+		log.Printf("Error getting update offset, starting from the oldest pending update: %v", err)
+	}
 
-	updates := b.api.GetUpdatesChan(u)
+	// The routines stop with the update loop, also when it fails
+	ctx, cancel := context.WithCancel(ctx)
+	defer func() {
+		cancel()
+		b.routines.Wait()
+	}()
 
 	// Start background routine to auto-complete old sessions
-	go b.autoCompleteSessionsRoutine()
+	b.startRoutine(ctx, b.autoCompleteSessionsRoutine)
+
+	// Start background routine to remind delayed participants
+	b.startRoutine(ctx, b.delayedRemindersRoutine)
+
+	// Start background routine that resets remote status every morning
+	b.startRoutine(ctx, b.remoteResetRoutine)
+
+	// Start background routine for personal weekly summaries
+	b.startRoutine(ctx, b.weeklySummaryRoutine)
+
+	// Start background routine for the weekly digest
+	if b.config.WeeklyDigest != config.DigestOff {
+		b.startRoutine(ctx, b.weeklyDigestRoutine)
+	}
+
+	// Start background routine for scheduled breaks
+	if len(b.config.ScheduledBreaks) > 0 {
+		b.startRoutine(ctx, b.scheduledBreaksRoutine)
+	}
+
+	updates, errs := b.pollUpdates(ctx, offset)
 
-	for update := range updates {
-		if update.Message != nil {
-			b.handleMessage(update.Message)
-		} else if update.CallbackQuery != nil {
-			b.handleCallbackQuery(update.CallbackQuery)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-errs:
+			return err
+		case update := <-updates:
+			b.handleUpdate(update)
+
+			if err := b.service.SetUpdateOffset(update.UpdateID + 1); err != nil {
+				log.Printf("Error saving update offset: %v", err)
+			}
 		}
 	}
+}
+
+// Stop sends notifications still waiting for the debounce window and drops
+// pending reminders and rate-limited messages. Call it after Start has
+// returned, before closing the database.
+func (b *Bot) Stop() {
+	b.stopNudges()
+
+	b.pendingMu.Lock()
+	sessionIDs := make([]int64, 0, len(b.pendingNotifications))
+	for sessionID := range b.pendingNotifications {
+		sessionIDs = append(sessionIDs, sessionID)
+	}
+	b.pendingMu.Unlock()
+
+	for _, sessionID := range sessionIDs {
+		b.flushNotifications(sessionID)
+	}
+
+	b.stopRetries()
+}
+
+// CheckAuthorized verifies that Telegram still accepts the bot's token
+func (b *Bot) CheckAuthorized(ctx context.Context) error {
+	if _, err := b.api.GetMe(); err != nil {
+		return fmt.Errorf("failed to reach Telegram: %w", err)
+	}
 
 	return nil
 }
 
-// autoCompleteSessionsRoutine runs in background and auto-completes sessions after 15 minutes
-func (b *Bot) autoCompleteSessionsRoutine() {
+// startRoutine runs a background routine that Start waits for on shutdown
+func (b *Bot) startRoutine(ctx context.Context, routine func(ctx context.Context)) {
+	b.routines.Add(1)
+	go func() {
+		defer b.routines.Done()
+		routine(ctx)
+	}()
+}
+
+// handleUpdate dispatches a single update to its handler
+func (b *Bot) handleUpdate(update tgbotapi.Update) {
+	if chat := update.FromChat(); chat != nil {
+		b.trackChat(chat)
+	}
+
+	if update.Message != nil {
+		b.handleMessage(update.Message)
+	} else if update.EditedMessage != nil {
+		b.handleEditedMessage(update.EditedMessage)
+	} else if update.CallbackQuery != nil {
+		b.handleCallbackQuery(update.CallbackQuery)
+	} else if update.MyChatMember != nil {
+		b.handleMyChatMember(update.MyChatMember)
+	}
+}
+
+// autoCompleteSessionsRoutine runs in background and auto-completes sessions
+// after 15 minutes or after the configured inactivity period
+func (b *Bot) autoCompleteSessionsRoutine(ctx context.Context) {
 	ticker := time.NewTicker(1 * time.Minute) // Check every minute
 	defer ticker.Stop()
 
-	for range ticker.C {
-		completedSession, err := b.service.AutoCompleteOldSessions()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		completedSessions, err := b.service.AutoCompleteOldSessions(b.config.InactivityTimeout, b.config.MinParticipants)
 		if err != nil {
 			log.Printf("Error auto-completing sessions: %v", err)
-			continue
 		}
 
-		if completedSession != nil {
-			// Session was auto-completed, notify participants
-			b.notifySessionCompleted(completedSession)
+		// Sessions completed before an error still need their notifications
+		for _, session := range completedSessions {
+			b.notifySessionCompleted(session, completedAuto, 0)
 		}
 	}
 }
 
-// notifySessionCompleted notifies all participants that the session has ended
-func (b *Bot) notifySessionCompleted(session *domain.Session) {
+// durationMinutes returns how many whole minutes the session lasted
+func durationMinutes(session *domain.Session) int {
+	return int(session.Duration().Round(time.Minute).Minutes())
+}
+
+// completionCause describes how a session came to an end
+type completionCause int
+
+const (
+	completedAuto completionCause = iota
+	completedManually
+)
+
+// notifySessionCompleted notifies all participants that the session has ended.
+// finishedBy is the user who completed the session manually, if any.
+func (b *Bot) notifySessionCompleted(session *domain.Session, cause completionCause, finishedBy int64) {
 	// Get all responses to notify everyone who participated
 	responses, err := b.service.GetSessionResponses(session.ID)
 	if err != nil {
@@ -85,9 +240,14 @@ func (b *Bot) notifySessionCompleted(session *domain.Session) {
 		return
 	}
 
+	if session.IsVote() {
+		b.notifyVoteCompleted(session, responses)
+		return
+	}
+
 	// Build final summary with past tense
-	var attended []string
-	var attendedDelayed []string
+	var attended []*domain.User
+	var attendedDelayed []*domain.User
 
 	for _, resp := range responses {
 		user, err := b.service.GetUser(resp.UserID)
@@ -100,49 +260,74 @@ func (b *Bot) notifySessionCompleted(session *domain.Session) {
 			continue
 		}
 
-		displayName := user.Username
-		if displayName == "" {
-			displayName = user.FirstName
-		}
-
 		switch resp.Response {
 		case domain.ResponseAccepted:
-			attended = append(attended, displayName)
+			attended = append(attended, user)
 		case domain.ResponseAcceptedDelayed:
-			attendedDelayed = append(attendedDelayed, displayName)
+			attendedDelayed = append(attendedDelayed, user)
 		}
 	}
 
-	summary := "📊 *Итоги перекура:*\n\n"
+	// Names follow the setting of the chat the session was started in, as
+	// the summaries go to private chats; the text is in each recipient's
+	// language
+	plainNames := b.service.UsesPlainNames(session.ChatID)
+	completionMessage := func(lang string) string {
+		summary := locale.Tr(lang, "summary.title")
+
+		if len(attended) > 0 {
+			summary += locale.Tr(lang, "summary.attended")
+			for _, user := range attended {
+				summary += fmt.Sprintf("  • %s\n", service.Mention(user, plainNames))
+			}
+			summary += "\n"
+		}
 
-	if len(attended) > 0 {
-		summary += "✅ *Были на перекуре:*\n"
-		for _, name := range attended {
-			summary += fmt.Sprintf("  • @%s\n", name)
+		if len(attendedDelayed) > 0 {
+			summary += locale.Tr(lang, "summary.attended_late")
+			for _, user := range attendedDelayed {
+				summary += fmt.Sprintf("  • %s\n", service.Mention(user, plainNames))
+			}
+			summary += "\n"
 		}
-		summary += "\n"
-	}
 
-	if len(attendedDelayed) > 0 {
-		summary += "⏱ *Пришли позже:*\n"
-		for _, name := range attendedDelayed {
-			summary += fmt.Sprintf("  • @%s\n", name)
+		if len(attended) == 0 && len(attendedDelayed) == 0 {
+			summary = locale.Tr(lang, "summary.nobody")
 		}
-		summary += "\n"
-	}
 
-	if len(attended) == 0 && len(attendedDelayed) == 0 {
-		summary = "Никто не пришёл на перекур 😔"
+		if session.Failed {
+			return locale.Tr(lang, "summary.failed", b.config.MinParticipants, summary)
+		}
+
+		return locale.Tr(lang, "summary.completed", durationMinutes(session), summary)
 	}
 
-	completionMsg := fmt.Sprintf("⏰ *Перекур завершён (15 минут прошло)*\n\n%s", summary)
+	// A group invitation ends with one summary for the whole group
+	if session.Shared {
+		lang := b.language(session.InitiatorID)
+		b.closeGroupInvitation(session, locale.Tr(lang, "group.finished"))
+
+		msg := tgbotapi.NewMessage(session.StatusChatID, completionMessage(lang))
+		msg.ParseMode = "Markdown"
+		if _, err := b.send(msg); err != nil {
+			log.Printf("Error sending summary to chat %d: %v", session.StatusChatID, err)
+		}
+
+		if !session.Failed {
+			b.askAttendance(session, responses)
+		}
+		return
+	}
 
-	// Notify the initiator
+	// Notify the initiator, unless they finished the session themselves
+	// and asked not to receive the summary in that case
 	initiator, _ := b.service.GetUser(session.InitiatorID)
-	if initiator == nil || !initiator.IsHidden {
-		msg := tgbotapi.NewMessage(session.InitiatorID, completionMsg)
+	skipInitiator := cause == completedManually && finishedBy == session.InitiatorID &&
+		initiator != nil && initiator.SkipOwnSummary
+	if (initiator == nil || !initiator.IsHidden) && !skipInitiator {
+		msg := tgbotapi.NewMessage(session.InitiatorID, completionMessage(b.language(session.InitiatorID)))
 		msg.ParseMode = "Markdown"
-		if _, err := b.api.Send(msg); err != nil {
+		if _, err := b.send(msg); err != nil {
 			log.Printf("Error notifying initiator: %v", err)
 		}
 	}
@@ -157,9 +342,9 @@ func (b *Bot) notifySessionCompleted(session *domain.Session) {
 			if !notifiedUsers[resp.UserID] {
 				user, _ := b.service.GetUser(resp.UserID)
 				if user == nil || !user.IsHidden {
-					msg := tgbotapi.NewMessage(resp.UserID, completionMsg)
+					msg := tgbotapi.NewMessage(resp.UserID, completionMessage(b.language(resp.UserID)))
 					msg.ParseMode = "Markdown"
-					if _, err := b.api.Send(msg); err != nil {
+					if _, err := b.send(msg); err != nil {
 						log.Printf("Error notifying user %d: %v", resp.UserID, err)
 					}
 				}
@@ -167,60 +352,190 @@ func (b *Bot) notifySessionCompleted(session *domain.Session) {
 			}
 		}
 	}
+
+	// Nobody needs to confirm attending a break that didn't happen
+	if !session.Failed {
+		b.askAttendance(session, responses)
+	}
 }
 
 // handleMessage handles incoming messages
 func (b *Bot) handleMessage(message *tgbotapi.Message) {
 	// Register or update user
-	b.registerUser(message.From)
+	newUser := b.registerUser(message.From)
 
 	// Check if command
-	if message.IsCommand() {
-		b.handleCommand(message)
+	if command, _, ok := b.parseCommand(message); ok {
+		b.handleCommand(message, command, newUser)
 		return
 	}
 
 	// Handle keyboard button
-	if message.Text == "🚬 Го курить!" {
+	if b.isActionButton(message.Text) {
 		b.handleSmoke(message)
 		return
 	}
+
+	if message.Location != nil {
+		b.handleLocation(message)
+	}
+}
+
+// actionButtonText returns the label of the reply keyboard button that
+// starts a break: ACTION_BUTTON_TEXT if set, the user's translation otherwise
+func (b *Bot) actionButtonText(userID int64) string {
+	if b.config.ActionButtonText != "" {
+		return b.config.ActionButtonText
+	}
+	return b.t(userID, "keyboard.smoke")
+}
+
+// isActionButton reports whether a message is a press of the button that
+// starts a break. The translated labels keep matching, so keyboards sent
+// before ACTION_BUTTON_TEXT was set still work.
+func (b *Bot) isActionButton(text string) bool {
+	if b.config.ActionButtonText != "" && text == b.config.ActionButtonText {
+		return true
+	}
+	return locale.Matches("keyboard.smoke", text)
+}
+
+// handleEditedMessage handles messages edited by their author. Edits are
+// ignored unless HANDLE_EDITED_MESSAGES is enabled, in which case an edited
+// command or keyboard text is processed as if it was sent anew.
+func (b *Bot) handleEditedMessage(message *tgbotapi.Message) {
+	if !b.config.HandleEdits || message.From == nil {
+		return
+	}
+
+	b.handleMessage(message)
+}
+
+// parseCommand extracts the command name and its arguments from a message,
+// accepting both Telegram slash commands and the configured command prefix
+func (b *Bot) parseCommand(message *tgbotapi.Message) (string, string, bool) {
+	if message.IsCommand() {
+		return message.Command(), message.CommandArguments(), true
+	}
+
+	prefix := b.config.CommandPrefix
+	if prefix == "" || prefix == "/" || !strings.HasPrefix(message.Text, prefix) {
+		return "", "", false
+	}
+
+	name, args, _ := strings.Cut(strings.TrimPrefix(message.Text, prefix), " ")
+
+	// Strip the bot mention the same way Telegram does for /command@bot
+	command, _, _ := strings.Cut(name, "@")
+	return strings.ToLower(command), strings.TrimSpace(args), command != ""
+}
+
+// commandArguments returns the text following the command
+func (b *Bot) commandArguments(message *tgbotapi.Message) string {
+	_, args, _ := b.parseCommand(message)
+	return args
 }
 
-// handleCommand handles bot commands
-func (b *Bot) handleCommand(message *tgbotapi.Message) {
-	switch message.Command() {
+// handleCommand handles bot commands. newUser tells whether the sender
+// was registered by this message.
+func (b *Bot) handleCommand(message *tgbotapi.Message, command string, newUser bool) {
+	switch command {
 	case "start":
-		b.handleStart(message)
+		b.handleStart(message, newUser)
 	case "smoke":
 		b.handleSmoke(message)
 	case "status":
 		b.handleStatus(message)
+	case "who":
+		b.handleWho(message)
 	case "cancel":
 		b.handleCancel(message)
+	case "poke":
+		b.handlePoke(message)
+	case "extend":
+		b.handleExtend(message)
+	case "join":
+		b.handleRespondCommand(message, "accept")
+	case "later":
+		b.handleRespondCommand(message, "delayed")
+	case "nope":
+		b.handleRespondCommand(message, "deny")
 	case "office":
 		b.handleBackToOffice(message)
+	case "mute":
+		b.handleMute(message, true)
+	case "unmute":
+		b.handleMute(message, false)
 	case "help":
 		b.handleHelp(message)
+	case "organizers":
+		b.handleOrganizers(message)
+	case "leaderboard":
+		b.handleLeaderboard(message)
+	case "mystats":
+		b.handleMyStats(message)
+	case "stats":
+		b.handleStats(message)
+	case "history":
+		b.handleHistory(message)
+	case "streak":
+		b.handleStreak(message)
+	case "ownsummary":
+		b.handleOwnSummary(message)
+	case "weekly":
+		b.handleWeekly(message)
+	case "mentions":
+		b.handleMentions(message)
+	case "terse":
+		b.handleTerse(message)
+	case "timezone":
+		b.handleTimezone(message)
+	case "delay":
+		b.handleDelay(message)
+	case "quiet":
+		b.handleQuiet(message)
+	case "lang":
+		b.handleLanguage(message)
+	case "nick":
+		b.handleNick(message)
+	case "settings":
+		b.handleSettings(message)
+	case "vote":
+		b.handleVote(message)
+	case "feedback":
+		b.handleFeedback(message)
+	case "forcecomplete":
+		b.handleForceComplete(message)
+	case "resetremote":
+		b.handleResetRemote(message)
+	case "hide":
+		b.handleHide(message, true)
+	case "unhide":
+		b.handleHide(message, false)
+	case "sessions":
+		b.handleSessions(message)
+	case "export":
+		b.handleExport(message)
+	case "announce":
+		b.handleAnnounce(message)
+	case "demo":
+		b.handleDemo(message)
 	default:
-		b.sendMessage(message.Chat.ID, "Неизвестная команда. Используйте /help чтобы узнать больше")
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "command.unknown"))
 	}
 }
 
-// handleStart handles the /start command
-func (b *Bot) handleStart(message *tgbotapi.Message) {
-	text := fmt.Sprintf(
-		"👋 Добро пожаловать в бот курильщика, %s!\n\n"+
-			"Этот бот поможет скоординироваться с коллегами для перекура.\n\n"+
-			"Используйте /smoke или нажмите на кнопку ниже, чтобы пригласить других\n"+
-			"Используйте /status чтобы увидеть текущий статус перекура\n"+
-			"Используйте /help для показа информации",
-		message.From.FirstName,
-	)
+// handleStart handles the /start command. New users get the full
+// introduction, returning ones a short greeting.
+func (b *Bot) handleStart(message *tgbotapi.Message, newUser bool) {
+	text := b.t(message.From.ID, "start.welcome", message.From.FirstName)
+	if !newUser {
+		text = b.t(message.From.ID, "start.welcome_back", message.From.FirstName)
+	}
 
 	keyboard := tgbotapi.NewReplyKeyboard(
 		tgbotapi.NewKeyboardButtonRow(
-			tgbotapi.NewKeyboardButton("🚬 Го курить!"),
+			tgbotapi.NewKeyboardButton(b.actionButtonText(message.From.ID)),
 		),
 	)
 
@@ -228,34 +543,89 @@ func (b *Bot) handleStart(message *tgbotapi.Message) {
 	msg.ReplyMarkup = keyboard
 	msg.ParseMode = "Markdown"
 
-	if _, err := b.api.Send(msg); err != nil {
+	if _, err := b.send(msg); err != nil {
 		log.Printf("Error sending start message: %v", err)
 	}
 }
 
 // handleSmoke handles the smoke break initiation
 func (b *Bot) handleSmoke(message *tgbotapi.Message) {
-	// Check working hours
-	if !b.config.IsWorkingHours() {
-		b.sendMessage(message.Chat.ID,
-			"⏰ К сожалению, сейчас не время перекуров. Повторить можно в рабочее время (09:00 - 23:00).")
+	// Check working hours in the initiator's timezone
+	if !b.config.IsWorkingHoursFor(b.userTimezone(message.From.ID)) {
+		b.sendMessage(message.Chat.ID, b.outsideHoursMessage(message.From.ID))
+		return
+	}
+
+	// Optional arguments set the session lifetime and a note for the
+	// invitations, e.g. /smoke 20 на крыше
+	timeoutMinutes, note, ok := parseSmokeArguments(b.commandArguments(message))
+	if !ok {
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "smoke.usage",
+			service.MinSessionTimeoutMinutes, service.MaxSessionTimeoutMinutes))
+		return
+	}
+
+	if !b.checkInitiatorApproved(message) {
 		return
 	}
 
 	// Start new session
-	session, err := b.service.StartSession(message.From.ID)
+	session, err := b.service.StartSession(message.From.ID, message.Chat.ID, timeoutMinutes, note)
 	if err != nil {
-		if strings.Contains(err.Error(), "already an active") {
-			b.sendMessage(message.Chat.ID,
-				"⚠️ Сейчас уже идет активный перекур! Используйте /status чтобы узнать больше")
-		} else {
-			b.sendMessage(message.Chat.ID,
-				"❌ Не вышло организовать перекур. Попробуйте позже")
-			log.Printf("Error starting session: %v", err)
-		}
+		b.reportStartError(message, err)
 		return
 	}
 
+	b.inviteToSession(message, session)
+}
+
+// parseSmokeArguments splits the arguments of /smoke into the session
+// lifetime in minutes, zero for the default, and the note. A leading number
+// is the lifetime only if it is a whole word within the allowed range, so
+// notes like "3 этаж" are kept as they are. A lone number out of range
+// can't be meant as a note and is rejected.
+func parseSmokeArguments(args string) (int, string, bool) {
+	fields := strings.Fields(args)
+	if len(fields) == 0 {
+		return 0, "", true
+	}
+
+	minutes, err := strconv.Atoi(fields[0])
+	if err != nil || strings.IndexFunc(fields[0], func(r rune) bool { return r < '0' || r > '9' }) >= 0 {
+		return 0, args, true
+	}
+
+	if minutes < service.MinSessionTimeoutMinutes || minutes > service.MaxSessionTimeoutMinutes {
+		return 0, args, len(fields) > 1
+	}
+
+	return minutes, strings.Join(fields[1:], " "), true
+}
+
+// reportStartError tells the initiator why their session didn't start
+func (b *Bot) reportStartError(message *tgbotapi.Message, err error) {
+	var cooldownErr *service.CooldownError
+	if errors.As(err, &cooldownErr) {
+		seconds := int(math.Ceil(cooldownErr.Remaining.Seconds()))
+		b.sendMessage(message.Chat.ID,
+			b.t(message.From.ID, "smoke.cooldown", seconds))
+	} else if errors.Is(err, service.ErrActiveSessionExists) {
+		b.sendMessage(message.Chat.ID,
+			b.t(message.From.ID, "smoke.already_active"))
+	} else if errors.Is(err, service.ErrNoActiveUsers) {
+		b.sendMessage(message.Chat.ID,
+			b.t(message.From.ID, "smoke.no_active_users"))
+	} else {
+		b.sendMessage(message.Chat.ID,
+			b.t(message.From.ID, "smoke.failed"))
+		log.Printf("Error starting session: %v", err)
+	}
+}
+
+// inviteToSession announces a session the sender of message just started:
+// in the group itself with group invitations, by DM to every active
+// colleague otherwise
+func (b *Bot) inviteToSession(message *tgbotapi.Message, session *domain.Session) {
 	// Get initiator info
 	initiator, err := b.service.GetUser(message.From.ID)
 	if err != nil {
@@ -263,93 +633,291 @@ func (b *Bot) handleSmoke(message *tgbotapi.Message) {
 		return
 	}
 
-	initiatorName := initiator.Username
-	if initiatorName == "" {
-		initiatorName = initiator.FirstName
+	initiatorName := service.Mention(initiator, false)
+
+	// The group sees one shared invitation instead of everyone getting a DM
+	if b.usesGroupInvitation(message.Chat) {
+		b.postGroupInvitation(session)
+		return
 	}
 
 	// Notify all active users
-	activeUsers, err := b.service.GetActiveUsers(message.From.ID)
+	activeUsers, err := b.invitees(message.From.ID)
 	if err != nil {
 		log.Printf("Error getting active users: %v", err)
 		return
 	}
 
-	if len(activeUsers) == 0 {
-		// Cancel the session since no one to notify
-		b.service.CancelSession(session.ID)
+	if len(activeUsers) == 0 && !b.config.WaitForInvitees {
+		// Everyone active may still be outside working hours or quiet
+		// hours, so cancel the session since no one to notify
+		b.service.CancelSession(session.ID, "")
 		b.sendMessage(message.Chat.ID,
-			"😔 Активных курильщиков в боте нет. Наслаждайтесь своим уединением!")
+			b.t(message.From.ID, "smoke.no_active_users"))
 		return
 	}
 
-	// Send confirmation to initiator with cancel button
-	cancelButton := tgbotapi.NewInlineKeyboardMarkup(
-		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("❌ Отменить перекур", fmt.Sprintf("cancel:%d", session.ID)),
-		),
-	)
+	// Without anyone to invite the session waits for the first colleague
+	// who comes back from remote or unmutes
+	confirmation := b.t(message.From.ID, "smoke.started", len(activeUsers))
+	if session.IsVote() {
+		confirmation = b.t(message.From.ID, "vote.started", len(activeUsers))
+	}
+	if len(activeUsers) == 0 {
+		confirmation = b.t(message.From.ID, "smoke.waiting")
+	}
 
-	msg := tgbotapi.NewMessage(message.Chat.ID,
-		fmt.Sprintf("✅ Перекур начался! Уведомления направлены %d коллегам...\n\nИспользуйте /cancel или кнопку ниже для отмены.", len(activeUsers)))
-	msg.ReplyMarkup = cancelButton
+	// Send confirmation to initiator with cancel button. It is edited
+	// into a running tally as responses come in.
+	msg := tgbotapi.NewMessage(message.Chat.ID, b.reply(message.From.ID, confirmation, "✅"))
+	msg.ReplyMarkup = cancelKeyboard(b.language(message.From.ID), session.ID)
 
-	if _, err := b.api.Send(msg); err != nil {
+	err = b.sendThen(msg, func(sent tgbotapi.Message) {
+		if err := b.service.SetStatusMessage(session.ID, sent.Chat.ID, sent.MessageID); err != nil {
+			log.Printf("Error saving status message of session %d: %v", session.ID, err)
+		}
+	})
+	if err != nil {
 		log.Printf("Error sending confirmation: %v", err)
 	}
 
 	// Send invitation to all active users
 	for _, user := range activeUsers {
-		b.sendInvitation(user.ID, session.ID, initiatorName)
+		b.sendInvitation(user, session, invitationText(userLanguage(user), session, initiatorName))
+	}
+
+	b.scheduleNudge(session)
+}
+
+// handleLocation stores a location shared by the initiator of the chat's
+// active session and forwards it to everyone still expected at the break.
+// Locations shared outside a session of one's own are ignored.
+func (b *Bot) handleLocation(message *tgbotapi.Message) {
+	session, err := b.service.SetSessionLocation(message.From.ID, message.Chat.ID,
+		message.Location.Latitude, message.Location.Longitude)
+	if err != nil {
+		if !errors.Is(err, service.ErrNoActiveSession) && !errors.Is(err, service.ErrNotInitiator) {
+			log.Printf("Error setting location of session: %v", err)
+		}
+		return
+	}
+
+	coming, err := b.service.GetSessionRespondents(session.ID)
+	if err != nil {
+		log.Printf("Error getting respondents of session %d: %v", session.ID, err)
+		return
+	}
+
+	nonResponders, err := b.service.GetNonResponders(session.ID)
+	if err != nil {
+		log.Printf("Error getting non-responders of session %d: %v", session.ID, err)
+		return
+	}
+
+	sent := 0
+	for _, user := range append(coming, nonResponders...) {
+		if user.ID == message.From.ID || !b.config.IsWorkingHoursFor(user.Timezone) {
+			continue
+		}
+
+		b.sendLocation(user.ID, session)
+		sent++
+	}
+
+	b.sendMessage(message.Chat.ID, b.reply(message.From.ID,
+		b.t(message.From.ID, "location.sent", sent),
+		"✅"))
+}
+
+// sendLocation sends the location of a session's break to a user
+func (b *Bot) sendLocation(userID int64, session *domain.Session) {
+	msg := tgbotapi.NewLocation(userID, *session.Latitude, *session.Longitude)
+	if _, err := b.send(msg); err != nil {
+		log.Printf("Error sending location to user %d: %v", userID, err)
+	}
+}
+
+// inviteToRunningSessions invites a user who just became available, by
+// coming back from remote or unmuting, to the sessions that started without
+// them. It does nothing unless WAIT_FOR_INVITEES is set.
+func (b *Bot) inviteToRunningSessions(userID int64) {
+	if !b.config.WaitForInvitees {
+		return
+	}
+
+	user, err := b.service.GetUser(userID)
+	if err != nil || user == nil {
+		log.Printf("Error getting user %d: %v", userID, err)
+		return
+	}
+
+	// The same users GetActiveUsers leaves out stay uninvited
+	if user.IsRemoteToday || user.IsHidden || user.IsMuted || user.IsUnreachable || user.InQuietHours(time.Now()) ||
+		!b.config.IsWorkingHoursFor(user.Timezone) || b.reachedInviteCap(user) {
+		return
+	}
+
+	sessions, err := b.service.GetUninvitedSessions(userID)
+	if err != nil {
+		log.Printf("Error getting sessions for user %d: %v", userID, err)
+		return
+	}
+
+	for _, session := range sessions {
+		text := locale.Tr(userLanguage(user), "schedule.invitation")
+		if session.InitiatorID != service.SystemUserID {
+			initiator, err := b.service.GetUser(session.InitiatorID)
+			if err != nil || initiator == nil {
+				log.Printf("Error getting initiator of session %d: %v", session.ID, err)
+				continue
+			}
+			text = invitationText(userLanguage(user), session, service.Mention(initiator, false))
+		}
+
+		b.sendInvitation(user, session, text)
+	}
+}
+
+// invitees returns active users, except the initiator, who are within their
+// own working hours and haven't reached the daily invitation cap
+func (b *Bot) invitees(initiatorID int64) ([]*domain.User, error) {
+	candidates, err := b.service.GetActiveUsers(initiatorID)
+	if err != nil {
+		return nil, err
+	}
+
+	var users []*domain.User
+	for _, user := range candidates {
+		if b.config.IsWorkingHoursFor(user.Timezone) && !b.reachedInviteCap(user) {
+			users = append(users, user)
+		}
+	}
+
+	return users, nil
+}
+
+// reachedInviteCap reports whether a user already got MAX_INVITES_PER_DAY
+// invitations today, counted from midnight in their timezone
+func (b *Bot) reachedInviteCap(user *domain.User) bool {
+	if b.config.MaxInvitesPerDay == 0 {
+		return false
+	}
+
+	loc := b.userLocation(user)
+	now := time.Now().In(loc)
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+
+	count, err := b.service.CountInvitationsSince(user.ID, midnight)
+	if err != nil {
+		log.Printf("Error counting invitations of user %d: %v", user.ID, err)
+		return false
+	}
+
+	return count >= b.config.MaxInvitesPerDay
+}
+
+// userLocation returns the location of the user's timezone, falling back to
+// the working hours location
+func (b *Bot) userLocation(user *domain.User) *time.Location {
+	if user.Timezone != "" {
+		if loc, err := time.LoadLocation(user.Timezone); err == nil {
+			return loc
+		}
+	}
+	return b.config.WorkingHours.Location
+}
+
+// handleWho lists who would be invited if the user started a break now,
+// and who is remote today
+func (b *Bot) handleWho(message *tgbotapi.Message) {
+	available, err := b.invitees(message.From.ID)
+	if err != nil {
+		log.Printf("Error getting active users: %v", err)
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "who.failed"))
+		return
+	}
+
+	remote, err := b.service.GetRemoteUsers()
+	if err != nil {
+		log.Printf("Error getting remote users: %v", err)
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "who.failed"))
+		return
+	}
+
+	plainNames := b.service.UsesPlainNames(message.Chat.ID)
+	names := func(users []*domain.User) string {
+		var sb strings.Builder
+		for _, user := range users {
+			sb.WriteString(fmt.Sprintf("  • %s\n", service.Mention(user, plainNames)))
+		}
+		return sb.String()
+	}
+
+	var text string
+	if len(available) == 0 {
+		text = b.t(message.From.ID, "who.nobody")
+	} else {
+		text = b.t(message.From.ID, "who.available", len(available), names(available))
+	}
+
+	if len(remote) > 0 {
+		text += b.t(message.From.ID, "who.remote", names(remote))
+	}
+
+	msg := tgbotapi.NewMessage(message.Chat.ID, text)
+	msg.ParseMode = "Markdown"
+
+	if _, err := b.send(msg); err != nil {
+		log.Printf("Error sending available users: %v", err)
 	}
 }
 
 // handleStatus shows the current session status
 func (b *Bot) handleStatus(message *tgbotapi.Message) {
-	session, err := b.service.GetActiveSession()
+	session, err := b.service.GetActiveSession(message.Chat.ID)
 	if err != nil {
 		log.Printf("Error getting active session: %v", err)
-		b.sendMessage(message.Chat.ID, "❌ Ошибка при проверке статуса перекура")
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "session.check_failed"))
 		return
 	}
 
 	if session == nil {
-		b.sendMessage(message.Chat.ID, "📭 Сейчас перекура нет")
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "session.none"))
 		return
 	}
 
-	summary, err := b.service.GetSessionSummary(session.ID)
+	summary, err := b.service.GetSessionSummary(session.ID, b.language(message.From.ID), b.service.UsesPlainNames(session.ChatID))
 	if err != nil {
 		log.Printf("Error getting session summary: %v", err)
-		b.sendMessage(message.Chat.ID, "❌ Что-то пошло не так в этом перекуре")
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "status.failed"))
 		return
 	}
 
 	msg := tgbotapi.NewMessage(message.Chat.ID, summary)
 	msg.ParseMode = "Markdown"
 
-	if _, err := b.api.Send(msg); err != nil {
+	if _, err := b.send(msg); err != nil {
 		log.Printf("Error sending status: %v", err)
 	}
 }
 
 // handleCancel handles canceling an active session
 func (b *Bot) handleCancel(message *tgbotapi.Message) {
-	session, err := b.service.GetActiveSession()
+	session, err := b.service.GetActiveSession(message.Chat.ID)
 	if err != nil {
 		log.Printf("Error getting active session: %v", err)
-		b.sendMessage(message.Chat.ID, "❌ Ошибка при проверке статуса перекура")
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "session.check_failed"))
 		return
 	}
 
 	if session == nil {
-		b.sendMessage(message.Chat.ID, "📭 Нет активного перекура для отмены")
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "cancel.none"))
 		return
 	}
 
 	// Check if user is the initiator
 	if session.InitiatorID != message.From.ID {
-		b.sendMessage(message.Chat.ID, "⛔️ Только инициатор перекура может его отменить")
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "cancel.not_initiator"))
 		return
 	}
 
@@ -359,110 +927,611 @@ func (b *Bot) handleCancel(message *tgbotapi.Message) {
 		log.Printf("Error getting respondents: %v", err)
 	}
 
-	// Cancel the session
-	if err := b.service.CancelSession(session.ID); err != nil {
+	// Cancel the session, anything after the command is the reason
+	if err := b.service.CancelSession(session.ID, b.commandArguments(message)); err != nil {
 		log.Printf("Error canceling session: %v", err)
-		b.sendMessage(message.Chat.ID, "❌ Не удалось отменить перекур")
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "cancel.failed"))
 		return
 	}
 
-	b.sendMessage(message.Chat.ID, "✅ Перекур отменён!")
+	b.sendMessage(message.Chat.ID, b.reply(message.From.ID, b.t(message.From.ID, "cancel.done"), "✅"))
+
+	b.notifyCancelled(session.ID, respondedUsers, message.From.ID)
+}
+
+// notifyCancelled tells everyone who responded to a cancelled session,
+// except whoever cancelled it, that the break is off, along with the
+// initiator's reason if they gave one
+func (b *Bot) notifyCancelled(sessionID int64, respondents []*domain.User, cancelledBy int64) {
+	// The reason is shown the way it was stored, after cleanup
+	session, err := b.service.GetSession(sessionID)
+	if err != nil || session == nil {
+		log.Printf("Error getting cancelled session %d: %v", sessionID, err)
+		return
+	}
+
+	if session.Shared {
+		outcome := b.t(session.InitiatorID, "group.cancelled")
+		if session.CancelReason != "" {
+			outcome = b.t(session.InitiatorID, "group.cancelled_reason", session.CancelReason)
+		}
+		b.closeGroupInvitation(session, outcome)
+	}
+
+	for _, user := range respondents {
+		if user.ID == cancelledBy {
+			continue
+		}
 
-	// Notify all users who responded
-	for _, user := range respondedUsers {
-		if user.ID != message.From.ID {
-			b.sendMessage(user.ID, "❌ Перекур был отменён инициатором")
+		if session.CancelReason != "" {
+			b.sendMessage(user.ID, b.t(user.ID, "cancel.notify_reason", session.CancelReason))
+		} else {
+			b.sendMessage(user.ID, b.t(user.ID, "cancel.notify"))
 		}
 	}
 }
 
-// handleBackToOffice removes remote status
-func (b *Bot) handleBackToOffice(message *tgbotapi.Message) {
-	user, err := b.service.GetUser(message.From.ID)
+// handlePoke re-sends the invitation to everyone who hasn't answered yet.
+// Each user is reminded at most once per session.
+func (b *Bot) handlePoke(message *tgbotapi.Message) {
+	session, err := b.service.GetActiveSession(message.Chat.ID)
 	if err != nil {
-		log.Printf("Error getting user: %v", err)
-		b.sendMessage(message.Chat.ID, "❌ Ошибка получения статуса")
+		log.Printf("Error getting active session: %v", err)
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "session.check_failed"))
 		return
 	}
 
-	if user == nil {
-		b.sendMessage(message.Chat.ID, "⚠️ Сначала используйте /start")
+	if session == nil {
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "session.none"))
 		return
 	}
 
-	if !user.IsRemoteToday {
-		b.sendMessage(message.Chat.ID, "✅ Вы и так не на удаленке. Можете получать уведомления!")
+	if session.InitiatorID != message.From.ID {
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "poke.not_initiator"))
 		return
 	}
 
-	if err := b.service.ClearRemoteStatus(message.From.ID); err != nil {
-		log.Printf("Error clearing remote status: %v", err)
-		b.sendMessage(message.Chat.ID, "❌ Не удалось сбросить статус")
+	nonResponders, err := b.service.GetNonResponders(session.ID)
+	if err != nil {
+		log.Printf("Error getting non-responders: %v", err)
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "poke.failed"))
 		return
 	}
 
-	b.sendMessage(message.Chat.ID, "🏢 Отлично! Вы вернулись в офис. Теперь будете получать уведомления о перекурах!")
-}
+	initiatorName := message.From.FirstName
+	if initiator, err := b.service.GetUser(message.From.ID); err == nil && initiator != nil {
+		initiatorName = service.Mention(initiator, false)
+	}
 
-// handleHelp shows help information
-func (b *Bot) handleHelp(message *tgbotapi.Message) {
-	text := `*Бот для курильщиков - Помощь*
-
-*Команды:*
-/start - Активировать бота и показать меню
-/smoke - Пригласить коллег на перекур
-/status - Проверить текущий статус перекура
-/cancel - Отменить текущий перекур (только для инициатора)
-/office - Вернуться в офис (отменить статус "на удаленке")
-/help - Показать помощь
-
-*Как это работает:*
-1. Нажмите "🚬 Го курить!" или используйте /smoke
-2. Все коллеги получат уведомление
-3. Они могут ответить:
-   • ✅ Го курить! - Присоединиться сразу
-   • ⏱ В течение 5 минут - Присоединиться с задержкой
-   • ❌ Не, спс - Отклонить приглашение
-   • 🏠 Я на удаленке (больше уведомлений не будет до завтра)
-
-*Рабочие часы:*
-Бот обрабатывает запросы только в рабочее время (09:00 - 23:00).
-
-Наслаждайтесь перекурами! 🚬☕`
+	poked := 0
+	for _, user := range nonResponders {
+		if !b.config.IsWorkingHoursFor(user.Timezone) {
+			continue
+		}
 
-	msg := tgbotapi.NewMessage(message.Chat.ID, text)
-	msg.ParseMode = "Markdown"
+		first, err := b.service.MarkPoked(session.ID, user.ID)
+		if err != nil {
+			log.Printf("Error marking user %d poked: %v", user.ID, err)
+			continue
+		}
+		if !first {
+			continue
+		}
 
-	if _, err := b.api.Send(msg); err != nil {
-		log.Printf("Error sending help: %v", err)
+		b.sendInvitation(user, session, locale.Tr(userLanguage(user), "poke.invitation", initiatorName))
+		poked++
 	}
-}
-
-// sendInvitation sends a smoking invitation to a user
-func (b *Bot) sendInvitation(userID int64, sessionID int64, initiatorName string) {
-	text := fmt.Sprintf("🚬 @%s приглашает вас на перекур!\n\nГо курить?", initiatorName)
 
-	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+	if poked == 0 {
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "poke.nobody"))
+		return
+	}
+
+	b.sendMessage(message.Chat.ID, b.reply(message.From.ID,
+		b.t(message.From.ID, "poke.done", poked), "✅"))
+}
+
+// handleExtend reopens the chat's last session if it completed only a few
+// minutes ago, so the initiator doesn't have to start over
+func (b *Bot) handleExtend(message *tgbotapi.Message) {
+	session, err := b.service.GetLatestSession(message.Chat.ID)
+	if err != nil {
+		log.Printf("Error getting latest session: %v", err)
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "session.check_failed"))
+		return
+	}
+
+	if session != nil && session.Status == domain.SessionStatusActive {
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "extend.still_active"))
+		return
+	}
+
+	if session == nil || session.Status != domain.SessionStatusCompleted || session.CompletedAt == nil ||
+		time.Since(*session.CompletedAt) > service.ExtendGracePeriod {
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "extend.too_late",
+			int(service.ExtendGracePeriod.Minutes())))
+		return
+	}
+
+	if session.InitiatorID != message.From.ID {
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "extend.not_initiator"))
+		return
+	}
+
+	if err := b.service.ExtendSession(session.ID); err != nil {
+		log.Printf("Error extending session %d: %v", session.ID, err)
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "extend.failed"))
+		return
+	}
+
+	b.sendMessage(message.Chat.ID, b.reply(message.From.ID,
+		b.t(message.From.ID, "extend.done", int(b.config.SessionTimeout.Minutes())),
+		"✅"))
+}
+
+// handleBackToOffice removes remote status
+func (b *Bot) handleBackToOffice(message *tgbotapi.Message) {
+	user, err := b.service.GetUser(message.From.ID)
+	if err != nil {
+		log.Printf("Error getting user: %v", err)
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "user.status_failed"))
+		return
+	}
+
+	if user == nil {
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "user.not_registered"))
+		return
+	}
+
+	if !user.IsRemoteToday {
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "office.not_remote"))
+		return
+	}
+
+	if err := b.service.ClearRemoteStatus(message.From.ID); err != nil {
+		log.Printf("Error clearing remote status: %v", err)
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "office.failed"))
+		return
+	}
+
+	b.sendMessage(message.Chat.ID, b.reply(message.From.ID,
+		b.t(message.From.ID, "office.done"), "🏢"))
+
+	b.inviteToRunningSessions(message.From.ID)
+}
+
+// handleOwnSummary toggles the final summary for sessions the user
+// finished themselves
+func (b *Bot) handleOwnSummary(message *tgbotapi.Message) {
+	enabled, ok := parseToggle(b.commandArguments(message))
+	if !ok {
+		b.sendMessage(message.Chat.ID,
+			b.t(message.From.ID, "ownsummary.usage"))
+		return
+	}
+
+	if err := b.service.SetSkipOwnSummary(message.From.ID, !enabled); err != nil {
+		log.Printf("Error updating summary preference: %v", err)
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "settings.save_failed"))
+		return
+	}
+
+	if enabled {
+		b.sendMessage(message.Chat.ID, b.reply(message.From.ID, b.t(message.From.ID, "ownsummary.on"), "✅"))
+	} else {
+		b.sendMessage(message.Chat.ID, b.reply(message.From.ID, b.t(message.From.ID, "ownsummary.off"), "✅"))
+	}
+}
+
+// handleMute stops or resumes invitations for the user until they change
+// their mind. Unlike remote status it doesn't expire.
+func (b *Bot) handleMute(message *tgbotapi.Message, muted bool) {
+	user, err := b.service.GetUser(message.From.ID)
+	if err != nil {
+		log.Printf("Error getting user: %v", err)
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "user.status_failed"))
+		return
+	}
+
+	if user == nil {
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "user.not_registered"))
+		return
+	}
+
+	if user.IsMuted == muted {
+		if muted {
+			b.sendMessage(message.Chat.ID, b.t(message.From.ID, "mute.already_on"))
+		} else {
+			b.sendMessage(message.Chat.ID, b.t(message.From.ID, "mute.already_off"))
+		}
+		return
+	}
+
+	if err := b.service.SetMuted(message.From.ID, muted); err != nil {
+		log.Printf("Error updating mute status: %v", err)
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "settings.save_failed"))
+		return
+	}
+
+	if muted {
+		b.sendMessage(message.Chat.ID, b.reply(message.From.ID,
+			b.t(message.From.ID, "mute.on"), "🔕"))
+	} else {
+		b.sendMessage(message.Chat.ID, b.reply(message.From.ID,
+			b.t(message.From.ID, "mute.off"), "🔔"))
+		b.inviteToRunningSessions(message.From.ID)
+	}
+}
+
+// handleMentions switches the current chat between @-mentions and plain names
+func (b *Bot) handleMentions(message *tgbotapi.Message) {
+	mentions, ok := parseToggle(b.commandArguments(message))
+	if !ok {
+		b.sendMessage(message.Chat.ID,
+			b.t(message.From.ID, "mentions.usage"))
+		return
+	}
+
+	if err := b.service.SetChatPlainNames(message.Chat.ID, !mentions); err != nil {
+		log.Printf("Error updating mentions setting: %v", err)
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "settings.save_failed"))
+		return
+	}
+
+	if mentions {
+		b.sendMessage(message.Chat.ID, b.reply(message.From.ID, b.t(message.From.ID, "mentions.on"), "✅"))
+	} else {
+		b.sendMessage(message.Chat.ID, b.reply(message.From.ID, b.t(message.From.ID, "mentions.off"), "✅"))
+	}
+}
+
+// handleTerse switches the user between short and full confirmations
+func (b *Bot) handleTerse(message *tgbotapi.Message) {
+	terse, ok := parseToggle(b.commandArguments(message))
+	if !ok {
+		b.sendMessage(message.Chat.ID,
+			b.t(message.From.ID, "terse.usage"))
+		return
+	}
+
+	if err := b.service.SetTerseReplies(message.From.ID, terse); err != nil {
+		log.Printf("Error updating reply preference: %v", err)
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "settings.save_failed"))
+		return
+	}
+
+	if terse {
+		b.sendMessage(message.Chat.ID, "✅")
+	} else {
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "terse.off"))
+	}
+}
+
+// handleQuiet shows, sets or clears the user's daily quiet window
+func (b *Bot) handleQuiet(message *tgbotapi.Message) {
+	usage := b.t(message.From.ID, "quiet.usage")
+
+	args := strings.Fields(b.commandArguments(message))
+	if len(args) == 0 {
+		user, err := b.service.GetUser(message.From.ID)
+		if err != nil || user == nil || !user.HasQuietHours() {
+			b.sendMessage(message.Chat.ID, b.t(message.From.ID, "quiet.none", usage))
+			return
+		}
+
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "quiet.current",
+			formatMinuteOfDay(user.QuietFrom), formatMinuteOfDay(user.QuietTo), usage))
+		return
+	}
+
+	if len(args) == 1 {
+		if enabled, ok := parseToggle(args[0]); ok && !enabled {
+			if err := b.service.SetQuietHours(message.From.ID, 0, 0); err != nil {
+				log.Printf("Error clearing quiet hours: %v", err)
+				b.sendMessage(message.Chat.ID, b.t(message.From.ID, "settings.save_failed"))
+				return
+			}
+
+			b.sendMessage(message.Chat.ID, b.reply(message.From.ID, b.t(message.From.ID, "quiet.off"), "✅"))
+			return
+		}
+	}
+
+	if len(args) != 2 {
+		b.sendMessage(message.Chat.ID, usage)
+		return
+	}
+
+	from, errFrom := parseMinuteOfDay(args[0])
+	to, errTo := parseMinuteOfDay(args[1])
+	if errFrom != nil || errTo != nil || from == to {
+		b.sendMessage(message.Chat.ID, usage)
+		return
+	}
+
+	if err := b.service.SetQuietHours(message.From.ID, from, to); err != nil {
+		log.Printf("Error setting quiet hours: %v", err)
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "settings.save_failed"))
+		return
+	}
+
+	b.sendMessage(message.Chat.ID, b.reply(message.From.ID,
+		b.t(message.From.ID, "quiet.set", formatMinuteOfDay(from), formatMinuteOfDay(to)), "✅"))
+}
+
+// parseMinuteOfDay parses an "HH:MM" time into minutes since midnight
+func parseMinuteOfDay(value string) (int, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, err
+	}
+
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// formatMinuteOfDay formats minutes since midnight as "HH:MM"
+func formatMinuteOfDay(minute int) string {
+	return fmt.Sprintf("%02d:%02d", minute/60, minute%60)
+}
+
+// outsideHoursMessage tells a user that breaks can't be started now and
+// when working hours start again in their timezone
+func (b *Bot) outsideHoursMessage(userID int64) string {
+	user, err := b.service.GetUser(userID)
+	if err != nil {
+		log.Printf("Error getting user %d: %v", userID, err)
+	}
+
+	loc := b.config.WorkingHours.Location
+	if user != nil {
+		loc = b.userLocation(user)
+	}
+
+	now := time.Now().In(loc)
+	next := b.config.WorkingHours.NextWorkingTime(now)
+
+	// The date only matters when working hours don't start again within a day
+	at := next.Format("15:04")
+	if next.Sub(now) >= 24*time.Hour {
+		at = next.Format("02.01 15:04")
+	}
+
+	lang := userLanguage(user)
+	return locale.Tr(lang, "smoke.outside_hours", at, formatWait(lang, next.Sub(now)), b.config.WorkingHours)
+}
+
+// formatWait formats a wait as hours and minutes, rounding up to a minute
+func formatWait(lang string, wait time.Duration) string {
+	minutes := int(math.Ceil(wait.Minutes()))
+	hours, minutes := minutes/60, minutes%60
+
+	switch {
+	case hours == 0:
+		return locale.Tr(lang, "wait.minutes", minutes)
+	case minutes == 0:
+		return locale.Tr(lang, "wait.hours", hours)
+	default:
+		return locale.Tr(lang, "wait.hours_minutes", hours, minutes)
+	}
+}
+
+// handleTimezone shows or sets the timezone used for the user's working hours
+func (b *Bot) handleTimezone(message *tgbotapi.Message) {
+	tz := b.commandArguments(message)
+	if tz == "" {
+		current := b.userTimezone(message.From.ID)
+		if current == "" {
+			current = b.t(message.From.ID, "timezone.default", b.config.WorkingHours.Location)
+		}
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "timezone.current", current))
+		return
+	}
+
+	if err := b.service.SetTimezone(message.From.ID, tz); err != nil {
+		log.Printf("Error setting timezone: %v", err)
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "timezone.unknown", tz))
+		return
+	}
+
+	b.sendMessage(message.Chat.ID, b.reply(message.From.ID, b.t(message.From.ID, "timezone.set", tz), "✅"))
+}
+
+// handleNick shows or sets the nickname used for the user in summaries and
+// notifications. "/nick clear" goes back to the username.
+func (b *Bot) handleNick(message *tgbotapi.Message) {
+	name := b.commandArguments(message)
+	if name == "" {
+		user, err := b.service.GetUser(message.From.ID)
+		if err != nil || user == nil {
+			log.Printf("Error getting user: %v", err)
+			b.sendMessage(message.Chat.ID, b.t(message.From.ID, "settings.load_failed"))
+			return
+		}
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "nick.current", user.Display()))
+		return
+	}
+
+	if strings.EqualFold(name, "clear") {
+		name = ""
+	}
+
+	nick, err := b.service.SetDisplayName(message.From.ID, name)
+	if err != nil {
+		log.Printf("Error setting nickname: %v", err)
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "settings.save_failed"))
+		return
+	}
+
+	if nick == "" {
+		b.sendMessage(message.Chat.ID, b.reply(message.From.ID, b.t(message.From.ID, "nick.cleared"), "✅"))
+		return
+	}
+
+	b.sendMessage(message.Chat.ID, b.reply(message.From.ID, b.t(message.From.ID, "nick.set", nick), "✅"))
+}
+
+// handleLanguage shows or sets the language of the user's messages
+func (b *Bot) handleLanguage(message *tgbotapi.Message) {
+	supported := strings.Join(locale.Supported(), ", ")
+
+	lang := strings.ToLower(b.commandArguments(message))
+	if lang == "" {
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "lang.current", b.language(message.From.ID), supported))
+		return
+	}
+
+	if !locale.IsSupported(lang) {
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "lang.unknown", lang, supported))
+		return
+	}
+
+	if err := b.service.SetLanguage(message.From.ID, lang); err != nil {
+		log.Printf("Error setting language: %v", err)
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "settings.save_failed"))
+		return
+	}
+
+	b.sendMessage(message.Chat.ID, b.reply(message.From.ID, b.t(message.From.ID, "lang.set"), "✅"))
+}
+
+// handleDelay sets how many minutes the user needs after answering "later"
+func (b *Bot) handleDelay(message *tgbotapi.Message) {
+	minutes, err := strconv.Atoi(b.commandArguments(message))
+	if err != nil || minutes < service.MinDelayMinutes || minutes > service.MaxDelayMinutes {
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "delay.usage",
+			service.MinDelayMinutes, service.MaxDelayMinutes))
+		return
+	}
+
+	if err := b.service.SetDelayMinutes(message.From.ID, minutes); err != nil {
+		log.Printf("Error updating delay: %v", err)
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "settings.save_failed"))
+		return
+	}
+
+	b.sendMessage(message.Chat.ID, b.reply(message.From.ID,
+		b.t(message.From.ID, "delay.set", minutes), "✅"))
+}
+
+// delayMinutes returns the user's delay, or the default for unknown users
+func (b *Bot) delayMinutes(user *domain.User) int {
+	if user == nil {
+		return service.DefaultDelayMinutes
+	}
+	return user.DelayMinutes
+}
+
+// userTimezone returns the user's configured timezone, or an empty string
+// when the default location applies
+func (b *Bot) userTimezone(userID int64) string {
+	user, err := b.service.GetUser(userID)
+	if err != nil || user == nil {
+		return ""
+	}
+	return user.Timezone
+}
+
+// handleHelp shows help information
+func (b *Bot) handleHelp(message *tgbotapi.Message) {
+	text := b.t(message.From.ID, "help.text", b.config.WorkingHours)
+
+	msg := tgbotapi.NewMessage(message.Chat.ID, text)
+	msg.ParseMode = "Markdown"
+
+	if _, err := b.send(msg); err != nil {
+		log.Printf("Error sending help: %v", err)
+	}
+}
+
+// invitationText is the invitation from initiatorName to a session: a break
+// or a vote
+func invitationText(lang string, session *domain.Session, initiatorName string) string {
+	if session.IsVote() {
+		return locale.Tr(lang, "vote.invitation", initiatorName)
+	}
+	return locale.Tr(lang, "smoke.invitation", initiatorName)
+}
+
+// sendInvitation sends a smoking invitation to a user. The session note, if
+// any, is added to the first line of the text.
+func (b *Bot) sendInvitation(user *domain.User, session *domain.Session, text string) {
+	keyboard := invitationKeyboard(userLanguage(user), user.DelayMinutes, func(action string) string {
+		return fmt.Sprintf("%s:%d", action, session.ID)
+	})
+	if session.IsVote() {
+		keyboard = voteKeyboard(session)
+	}
+
+	if session.Note != "" {
+		lines := strings.SplitN(text, "\n", 2)
+		lines[0] += fmt.Sprintf(" (%s)", session.Note)
+		text = strings.Join(lines, "\n")
+	}
+
+	msg := tgbotapi.NewMessage(user.ID, text)
+	msg.ReplyMarkup = keyboard
+
+	// A rate-limited invitation is recorded once the retry delivers it
+	err := b.sendThen(msg, func(tgbotapi.Message) {
+		if session.HasLocation() {
+			b.sendLocation(user.ID, session)
+		}
+
+		if err := b.service.RecordInvitation(session.ID, user.ID); err != nil {
+			log.Printf("Error recording invitation for user %d: %v", user.ID, err)
+		}
+	})
+	if err != nil {
+		log.Printf("Error sending invitation to user %d: %v", user.ID, err)
+	}
+}
+
+// invitationKeyboard builds the response buttons of an invitation for a user
+// with the given language and delay. callbackData maps each response action to the
+// button's callback data.
+func invitationKeyboard(lang string, delayMinutes int, callbackData func(action string) string) tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(locale.Tr(lang, "invite.accept"), callbackData("accept")),
+			tgbotapi.NewInlineKeyboardButtonData(locale.Tr(lang, "invite.delayed", delayMinutes), callbackData("delayed")),
+		),
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("✅ Го курить!", fmt.Sprintf("accept:%d", sessionID)),
-			tgbotapi.NewInlineKeyboardButtonData("⏱ В течение 5 минут", fmt.Sprintf("delayed:%d", sessionID)),
+			tgbotapi.NewInlineKeyboardButtonData(locale.Tr(lang, "invite.maybe"), callbackData("maybe")),
+			tgbotapi.NewInlineKeyboardButtonData(locale.Tr(lang, "invite.deny"), callbackData("deny")),
 		),
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("❌ Не, спс", fmt.Sprintf("deny:%d", sessionID)),
-			tgbotapi.NewInlineKeyboardButtonData("🏠 Я на удаленке", fmt.Sprintf("remote:%d", sessionID)),
+			tgbotapi.NewInlineKeyboardButtonData(locale.Tr(lang, "invite.remote"), callbackData("remote")),
 		),
 	)
+}
 
-	msg := tgbotapi.NewMessage(userID, text)
-	msg.ReplyMarkup = keyboard
-
-	if _, err := b.api.Send(msg); err != nil {
-		log.Printf("Error sending invitation to user %d: %v", userID, err)
+// responseForAction maps an invitation button action to its response type
+// and the confirmation shown to a user with the given language and delay
+func responseForAction(action string, lang string, delayMinutes int) (domain.ResponseType, string, bool) {
+	switch action {
+	case "accept":
+		return domain.ResponseAccepted, locale.Tr(lang, "respond.accepted"), true
+	case "delayed":
+		return domain.ResponseAcceptedDelayed, locale.Tr(lang, "respond.delayed", delayMinutes), true
+	case "maybe":
+		return domain.ResponseMaybe, locale.Tr(lang, "respond.maybe"), true
+	case "deny":
+		return domain.ResponseDenied, locale.Tr(lang, "respond.denied"), true
+	case "remote":
+		return domain.ResponseRemote, locale.Tr(lang, "respond.remote"), true
+	default:
+		return "", "", false
 	}
 }
 
 // handleCallbackQuery handles button callbacks
 func (b *Bot) handleCallbackQuery(query *tgbotapi.CallbackQuery) {
+	// A double tap only needs the spinner on the button to stop
+	if b.isDuplicateCallback(query) {
+		b.answerCallback(query.ID, "")
+		return
+	}
+
 	// Parse callback data
 	parts := strings.Split(query.Data, ":")
 	if len(parts) != 2 {
@@ -471,6 +1540,26 @@ func (b *Bot) handleCallbackQuery(query *tgbotapi.CallbackQuery) {
 	}
 
 	action := parts[0]
+
+	// Actions that don't refer to a session carry their own payload
+	switch action {
+	case "resetremote":
+		b.handleResetRemoteCallback(query, parts[1])
+		return
+	case "approve", "reject":
+		b.handleApprovalCallback(query, action, parts[1])
+		return
+	case "demo":
+		b.handleDemoCallback(query, parts[1])
+		return
+	case "attended", "missed":
+		b.handleAttendanceCallback(query, action, parts[1])
+		return
+	case "vote":
+		b.handleVoteCallback(query, parts[1])
+		return
+	}
+
 	sessionID, err := strconv.ParseInt(parts[1], 10, 64)
 	if err != nil {
 		b.answerCallback(query.ID, "Invalid session ID")
@@ -482,14 +1571,14 @@ func (b *Bot) handleCallbackQuery(query *tgbotapi.CallbackQuery) {
 
 	// Handle cancel action
 	if action == "cancel" {
-		session, err := b.service.GetActiveSession()
-		if err != nil || session == nil || session.ID != sessionID {
-			b.answerCallback(query.ID, "❌ Перекур уже не активен")
+		session, err := b.service.GetSession(sessionID)
+		if err != nil || session == nil || session.Status != domain.SessionStatusActive {
+			b.answerCallback(query.ID, b.t(query.From.ID, "callback.inactive"))
 			return
 		}
 
 		if session.InitiatorID != query.From.ID {
-			b.answerCallback(query.ID, "⛔️ Только инициатор может отменить")
+			b.answerCallback(query.ID, b.t(query.From.ID, "callback.cancel_not_initiator"))
 			return
 		}
 
@@ -499,113 +1588,205 @@ func (b *Bot) handleCallbackQuery(query *tgbotapi.CallbackQuery) {
 			log.Printf("Error getting respondents: %v", err)
 		}
 
-		// Cancel the session
-		if err := b.service.CancelSession(sessionID); err != nil {
+		// Cancel the session. The button gives no reason, /cancel does.
+		if err := b.service.CancelSession(sessionID, ""); err != nil {
 			log.Printf("Error canceling session: %v", err)
-			b.answerCallback(query.ID, "❌ Не удалось отменить")
+			b.answerCallback(query.ID, b.t(query.From.ID, "callback.cancel_failed"))
 			return
 		}
 
-		b.answerCallback(query.ID, "✅ Перекур отменён!")
-
-		// Update initiator's message
-		editMsg := tgbotapi.NewEditMessageText(
-			query.Message.Chat.ID,
-			query.Message.MessageID,
-			query.Message.Text+"\n\n❌ *Перекур отменён*",
-		)
-		editMsg.ParseMode = "Markdown"
-		if _, err := b.api.Send(editMsg); err != nil {
-			log.Printf("Error editing message: %v", err)
-		}
-
-		// Notify all users who responded
-		for _, user := range respondedUsers {
-			if user.ID != query.From.ID {
-				b.sendMessage(user.ID, "❌ Перекур был отменён инициатором")
+		b.answerCallback(query.ID, b.reply(query.From.ID, b.t(query.From.ID, "cancel.done"), "✅"))
+
+		// Update initiator's message, a group invitation is closed with
+		// the notifications
+		if !session.Shared {
+			editMsg := tgbotapi.NewEditMessageText(
+				query.Message.Chat.ID,
+				query.Message.MessageID,
+				query.Message.Text+b.t(query.From.ID, "callback.cancelled"),
+			)
+			editMsg.ParseMode = "Markdown"
+			if _, err := b.send(editMsg); err != nil {
+				log.Printf("Error editing message: %v", err)
 			}
 		}
+
+		b.notifyCancelled(sessionID, respondedUsers, query.From.ID)
 		return
 	}
 
 	// Verify session is still active
-	session, err := b.service.GetActiveSession()
-	if err != nil || session == nil || session.ID != sessionID {
-		b.answerCallback(query.ID, "❌ Этот перекур уже не активен")
+	session, err := b.service.GetSession(sessionID)
+	if err != nil || session == nil || session.Status != domain.SessionStatusActive {
+		b.answerCallback(query.ID, b.t(query.From.ID, "callback.invitation_inactive"))
+
+		// A group invitation is closed when its session ends
+		if session != nil && session.Shared {
+			return
+		}
 
 		// Update message to show it's cancelled
 		editMsg := tgbotapi.NewEditMessageText(
 			query.Message.Chat.ID,
 			query.Message.MessageID,
-			query.Message.Text+"\n\n❌ *Перекур отменён*",
+			query.Message.Text+b.t(query.From.ID, "callback.cancelled"),
 		)
 		editMsg.ParseMode = "Markdown"
-		if _, err := b.api.Send(editMsg); err != nil {
+		if _, err := b.send(editMsg); err != nil {
 			log.Printf("Error editing message: %v", err)
 		}
 		return
 	}
 
-	// Map action to response type
-	var responseType domain.ResponseType
-	var responseText string
-
-	switch action {
-	case "accept":
-		responseType = domain.ResponseAccepted
-		responseText = "✅ Отлично! Увидимся в курилке!"
-	case "delayed":
-		responseType = domain.ResponseAcceptedDelayed
-		responseText = "⏱ Ясненько! Увидимся в течение 5 минут!"
-	case "deny":
-		responseType = domain.ResponseDenied
-		responseText = "👌 Пон! В следующий раз тогда."
-	case "remote":
-		responseType = domain.ResponseRemote
-		responseText = "🏠 Удаленно сегодня. Никаких уведомлений до завтра.\n\nИспользуйте /office чтобы вернуться в офис."
-	default:
-		b.answerCallback(query.ID, "Неизвестное действие")
-		return
-	}
-
 	// Get user info for notification
 	respondent, err := b.service.GetUser(query.From.ID)
 	if err != nil {
 		log.Printf("Error getting respondent: %v", err)
 	}
 
+	// Map action to response type
+	responseType, responseText, ok := responseForAction(action, b.language(query.From.ID), b.delayMinutes(respondent))
+	if !ok {
+		b.answerCallback(query.ID, b.t(query.From.ID, "callback.unknown_action"))
+		return
+	}
+
 	respondentName := query.From.FirstName
-	if respondent != nil && respondent.Username != "" {
-		respondentName = "@" + respondent.Username
+	if respondent != nil {
+		respondentName = service.Mention(respondent, false)
 	}
 
 	// Record response
-	if err := b.service.RespondToSession(sessionID, query.From.ID, responseType); err != nil {
+	changed, err := b.service.RespondToSession(sessionID, query.From.ID, responseType)
+	if err != nil {
 		log.Printf("Error recording response: %v", err)
-		b.answerCallback(query.ID, "❌ Ошибка записи ответа")
+		b.answerCallback(query.ID, b.t(query.From.ID, "respond.failed"))
+		return
+	}
+
+	// Re-tapping the same button has nothing new to announce
+	if !changed {
+		b.answerCallback(query.ID, "")
 		return
 	}
 
 	// Answer callback
-	b.answerCallback(query.ID, responseText)
+	b.answerCallback(query.ID, b.reply(query.From.ID, responseText, "✅"))
 
-	// Update message to show response
-	editMsg := tgbotapi.NewEditMessageText(
-		query.Message.Chat.ID,
-		query.Message.MessageID,
-		query.Message.Text+"\n\n"+responseText,
-	)
+	// Update message to show response. A group invitation is shared, so
+	// its tally is refreshed with the notifications instead.
+	if !session.Shared {
+		editMsg := tgbotapi.NewEditMessageText(
+			query.Message.Chat.ID,
+			query.Message.MessageID,
+			query.Message.Text+"\n\n"+responseText,
+		)
 
-	if _, err := b.api.Send(editMsg); err != nil {
-		log.Printf("Error editing message: %v", err)
+		if _, err := b.send(editMsg); err != nil {
+			log.Printf("Error editing message: %v", err)
+		}
 	}
 
 	// Send notifications based on response type
 	b.notifyParticipants(session, query.From.ID, respondentName, responseType)
 }
 
-// registerUser registers or updates a user
-func (b *Bot) registerUser(user *tgbotapi.User) {
+// handleRespondCommand answers the current invitation like the matching
+// button would, for when the invitation message is out of reach
+func (b *Bot) handleRespondCommand(message *tgbotapi.Message, action string) {
+	session, err := b.sessionToAnswer(message)
+	if err != nil {
+		log.Printf("Error getting active session: %v", err)
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "session.check_failed"))
+		return
+	}
+
+	if session == nil {
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "respond.no_session"))
+		return
+	}
+
+	respondent, err := b.service.GetUser(message.From.ID)
+	if err != nil {
+		log.Printf("Error getting respondent: %v", err)
+	}
+
+	responseType, responseText, _ := responseForAction(action, b.language(message.From.ID), b.delayMinutes(respondent))
+
+	respondentName := message.From.FirstName
+	if respondent != nil {
+		respondentName = service.Mention(respondent, false)
+	}
+
+	changed, err := b.service.RespondToSession(session.ID, message.From.ID, responseType)
+	if errors.Is(err, service.ErrVoteSession) {
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "vote.use_buttons"))
+		return
+	}
+	if err != nil {
+		log.Printf("Error recording response: %v", err)
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "respond.failed"))
+		return
+	}
+
+	b.sendMessage(message.Chat.ID, b.reply(message.From.ID, responseText, "✅"))
+
+	if changed {
+		b.notifyParticipants(session, message.From.ID, respondentName, responseType)
+	}
+}
+
+// sessionToAnswer finds the active session a user responds to by command:
+// the invitation the command replies to, otherwise the active session of
+// the current chat. Sessions of other chats are never answered.
+func (b *Bot) sessionToAnswer(message *tgbotapi.Message) (*domain.Session, error) {
+	if sessionID, ok := invitationSessionID(message.ReplyToMessage); ok {
+		session, err := b.service.GetSession(sessionID)
+		if err != nil || session == nil || session.Status != domain.SessionStatusActive {
+			return nil, err
+		}
+		return session, nil
+	}
+
+	return b.service.GetActiveSession(message.Chat.ID)
+}
+
+// invitationSessionID reads the session a message's buttons belong to, for
+// a command sent in reply to an invitation or a status message
+func invitationSessionID(message *tgbotapi.Message) (int64, bool) {
+	if message == nil || message.ReplyMarkup == nil {
+		return 0, false
+	}
+
+	for _, row := range message.ReplyMarkup.InlineKeyboard {
+		for _, button := range row {
+			if button.CallbackData == nil {
+				continue
+			}
+
+			action, payload, ok := strings.Cut(*button.CallbackData, ":")
+			if !ok {
+				continue
+			}
+
+			switch action {
+			case "accept", "delayed", "maybe", "deny", "remote", "cancel":
+				if sessionID, err := strconv.ParseInt(payload, 10, 64); err == nil {
+					return sessionID, true
+				}
+			case "vote":
+				if sessionID, _, ok := parseVoteData(payload); ok {
+					return sessionID, true
+				}
+			}
+		}
+	}
+
+	return 0, false
+}
+
+// registerUser registers or updates a user and reports whether they were new
+func (b *Bot) registerUser(user *tgbotapi.User) bool {
 	username := user.UserName
 	if username == "" {
 		username = fmt.Sprintf("user%d", user.ID)
@@ -613,27 +1794,241 @@ func (b *Bot) registerUser(user *tgbotapi.User) {
 
 	lastName := user.LastName
 
-	if err := b.service.RegisterUser(user.ID, username, user.FirstName, lastName); err != nil {
+	isNew, err := b.service.RegisterUser(user.ID, username, user.FirstName, lastName)
+	if err != nil {
 		log.Printf("Error registering user %d: %v", user.ID, err)
 	}
+
+	return isNew
+}
+
+// parseToggle parses an on/off command argument
+func parseToggle(arg string) (bool, bool) {
+	switch strings.ToLower(strings.TrimSpace(arg)) {
+	case "on", "вкл":
+		return true, true
+	case "off", "выкл":
+		return false, true
+	default:
+		return false, false
+	}
+}
+
+// trackChat records the chat an update came from, keeping its title current
+func (b *Bot) trackChat(chat *tgbotapi.Chat) {
+	title := chat.Title
+	if title == "" {
+		// Private chats have no title, use the user's name instead
+		title = strings.TrimSpace(chat.FirstName + " " + chat.LastName)
+	}
+
+	if err := b.service.TrackChat(chat.ID, title, chat.Type); err != nil {
+		log.Printf("Error tracking chat %d: %v", chat.ID, err)
+	}
+}
+
+// send delivers a message. When Telegram rejects its Markdown (for example
+// because of an unescaped username), it is resent once as plain text so the
+// content is not lost. A rate-limited message is resent in the background,
+// see retryLater, and send returns the rate-limit error without waiting;
+// callers that act on the sent message use sendThen instead.
+//
+// A user who blocked the bot is muted, so they are not invited again.
+func (b *Bot) send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	return b.deliver(c, 0, nil)
+}
+
+// deliver sends a message that was already retried the given number of
+// times after rate limits. A rate-limited message is retried on a timer,
+// and the error then wraps errRetryScheduled. delivered, if set, runs once
+// the message is sent.
+func (b *Bot) deliver(c tgbotapi.Chattable, retries int, delivered func(tgbotapi.Message)) (tgbotapi.Message, error) {
+	sent, c, err := b.sendOnce(c)
+	if err == nil {
+		if delivered != nil {
+			delivered(sent)
+		}
+		return sent, nil
+	}
+
+	if wait, limited := retryAfter(err); limited && b.retryLater(c, wait, retries+1, delivered) {
+		return sent, fmt.Errorf("%w: %w", errRetryScheduled, err)
+	}
+
+	return sent, err
+}
+
+// sendOnce sends a message, falling back to plain text when Telegram can't
+// parse its markup, and marks users who blocked the bot. It returns the
+// message as it was last sent, to retry it without the markup again.
+func (b *Bot) sendOnce(c tgbotapi.Chattable) (tgbotapi.Message, tgbotapi.Chattable, error) {
+	sent, err := b.sender.Send(c)
+	if err != nil && isParseModeError(err) {
+		if plain, ok := withoutParseMode(c); ok {
+			log.Printf("Markdown rejected (%v), resending as plain text", err)
+			c = plain
+			sent, err = b.sender.Send(c)
+		}
+	}
+
+	if err != nil && isBlockedError(err) {
+		b.markUnreachable(c)
+	}
+
+	return sent, c, err
+}
+
+// retryAfter reports whether Telegram rejected a request for exceeding its
+// rate limits and how long it asked to wait before the next one
+func retryAfter(err error) (time.Duration, bool) {
+	var apiErr *tgbotapi.Error
+	if !errors.As(err, &apiErr) || apiErr.Code != http.StatusTooManyRequests {
+		return 0, false
+	}
+
+	wait := time.Duration(apiErr.RetryAfter) * time.Second
+	if wait <= 0 {
+		wait = time.Second
+	}
+
+	return wait, true
+}
+
+// isBlockedError reports whether Telegram refused to deliver a message
+// because the user blocked the bot or deleted their account
+func isBlockedError(err error) bool {
+	var apiErr *tgbotapi.Error
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return apiErr.Code == http.StatusForbidden
+}
+
+// markUnreachable stops inviting the user a message could not be delivered to.
+// Group chats the bot was removed from are left alone.
+func (b *Bot) markUnreachable(c tgbotapi.Chattable) {
+	var chatID int64
+	switch msg := c.(type) {
+	case tgbotapi.MessageConfig:
+		chatID = msg.ChatID
+	case tgbotapi.EditMessageTextConfig:
+		chatID = msg.ChatID
+	case tgbotapi.LocationConfig:
+		chatID = msg.ChatID
+	}
+
+	// Private chats share the user's ID, group chats have negative IDs
+	if chatID <= 0 {
+		return
+	}
+
+	log.Printf("User %d blocked the bot, not messaging them until they come back", chatID)
+	if err := b.service.MarkUserUnreachable(chatID); err != nil {
+		log.Printf("Error marking user %d unreachable: %v", chatID, err)
+	}
+}
+
+// isParseModeError reports whether Telegram rejected a message because of its markup
+func isParseModeError(err error) bool {
+	var apiErr *tgbotapi.Error
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return apiErr.Code == http.StatusBadRequest && strings.Contains(apiErr.Message, "can't parse entities")
+}
+
+// isNotModifiedError reports whether Telegram refused an edit because the
+// message already has that content
+func isNotModifiedError(err error) bool {
+	var apiErr *tgbotapi.Error
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return apiErr.Code == http.StatusBadRequest && strings.Contains(apiErr.Message, "message is not modified")
+}
+
+// withoutParseMode returns a copy of a formatted message with formatting disabled
+func withoutParseMode(c tgbotapi.Chattable) (tgbotapi.Chattable, bool) {
+	switch msg := c.(type) {
+	case tgbotapi.MessageConfig:
+		if msg.ParseMode == "" {
+			return nil, false
+		}
+		msg.ParseMode = ""
+		return msg, true
+	case tgbotapi.EditMessageTextConfig:
+		if msg.ParseMode == "" {
+			return nil, false
+		}
+		msg.ParseMode = ""
+		return msg, true
+	default:
+		return nil, false
+	}
 }
 
 // sendMessage sends a simple text message
 func (b *Bot) sendMessage(chatID int64, text string) {
 	msg := tgbotapi.NewMessage(chatID, text)
-	if _, err := b.api.Send(msg); err != nil {
+	if _, err := b.send(msg); err != nil {
 		log.Printf("Error sending message: %v", err)
 	}
 }
 
+// language returns the language of the messages sent to a user
+func (b *Bot) language(userID int64) string {
+	user, err := b.service.GetUser(userID)
+	if err != nil {
+		log.Printf("Error getting user %d: %v", userID, err)
+	}
+	return userLanguage(user)
+}
+
+// userLanguage returns the user's chosen language, or the bot's default
+// for users who haven't chosen one
+func userLanguage(user *domain.User) string {
+	if user == nil || user.Language == "" {
+		return locale.Language()
+	}
+	return user.Language
+}
+
+// t translates a message into the language of the user it is sent to
+func (b *Bot) t(userID int64, key string, args ...any) string {
+	return locale.Tr(b.language(userID), key, args...)
+}
+
+// reply picks the full or the short variant of a confirmation according to
+// the user's preference. Full text is the default.
+func (b *Bot) reply(userID int64, verbose, terse string) string {
+	user, err := b.service.GetUser(userID)
+	if err != nil {
+		log.Printf("Error getting user %d: %v", userID, err)
+	}
+
+	if user != nil && user.TerseReplies {
+		return terse
+	}
+
+	return verbose
+}
+
 // answerCallback answers a callback query
 func (b *Bot) answerCallback(callbackID string, text string) {
 	callback := tgbotapi.NewCallback(callbackID, text)
-	if _, err := b.api.Request(callback); err != nil {
+	if _, err := b.sender.Request(callback); err != nil {
 		log.Printf("Error answering callback: %v", err)
 	}
 }
 
+// responseEvent is a single response that participants should hear about
+type responseEvent struct {
+	responderID   int64
+	responderName string
+	responseType  domain.ResponseType
+	delayMinutes  int
+}
+
 // notifyParticipants notifies relevant users about a response
 func (b *Bot) notifyParticipants(session *domain.Session, responderID int64, responderName string, responseType domain.ResponseType) {
 	// Check if responder is hidden
@@ -648,6 +2043,83 @@ func (b *Bot) notifyParticipants(session *domain.Session, responderID int64, res
 		return
 	}
 
+	b.updateStatusMessage(session)
+
+	// Everyone follows a group invitation in the group itself
+	if session.Shared {
+		return
+	}
+
+	event := responseEvent{
+		responderID:   responderID,
+		responderName: responderName,
+		responseType:  responseType,
+		delayMinutes:  b.delayMinutes(responder),
+	}
+
+	if b.config.NotifyDebounce > 0 {
+		b.queueNotification(session, event)
+		return
+	}
+
+	b.deliverNotifications(session, []responseEvent{event})
+}
+
+// cancelKeyboard builds the initiator's button for cancelling a session
+func cancelKeyboard(lang string, sessionID int64) tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(locale.Tr(lang, "status.cancel_button"), fmt.Sprintf("cancel:%d", sessionID)),
+		),
+	)
+}
+
+// updateStatusMessage edits the initiator's confirmation, or the group
+// invitation, into a running tally of the responses so far
+func (b *Bot) updateStatusMessage(session *domain.Session) {
+	if session.StatusMessageID == 0 {
+		return
+	}
+
+	if session.Shared {
+		text, keyboard := b.groupInvitation(session)
+		edit := tgbotapi.NewEditMessageTextAndMarkup(session.StatusChatID, session.StatusMessageID, text, keyboard)
+		if _, err := b.send(edit); err != nil && !isNotModifiedError(err) {
+			log.Printf("Error updating group invitation of session %d: %v", session.ID, err)
+		}
+		return
+	}
+
+	responses, err := b.service.GetSessionResponses(session.ID)
+	if err != nil {
+		log.Printf("Error getting session responses: %v", err)
+		return
+	}
+
+	counts := make(map[domain.ResponseType]int)
+	for _, resp := range responses {
+		if user, err := b.service.GetUser(resp.UserID); err == nil && user != nil && user.IsHidden {
+			continue
+		}
+		counts[resp.Response]++
+	}
+
+	lang := b.language(session.InitiatorID)
+	text := locale.Tr(lang, "status.tally",
+		counts[domain.ResponseAccepted], counts[domain.ResponseAcceptedDelayed], counts[domain.ResponseMaybe], counts[domain.ResponseDenied])
+	if session.IsVote() {
+		text = locale.Tr(lang, "vote.tally", b.voteTally(session, responses, b.service.UsesPlainNames(session.ChatID)))
+	}
+
+	edit := tgbotapi.NewEditMessageTextAndMarkup(session.StatusChatID, session.StatusMessageID, text, cancelKeyboard(lang, session.ID))
+	if _, err := b.send(edit); err != nil && !isNotModifiedError(err) {
+		log.Printf("Error updating status message of session %d: %v", session.ID, err)
+	}
+}
+
+// deliverNotifications sends each recipient one message covering all the
+// events they should hear about
+func (b *Bot) deliverNotifications(session *domain.Session, events []responseEvent) {
 	// Get all responses for this session
 	responses, err := b.service.GetSessionResponses(session.ID)
 	if err != nil {
@@ -655,32 +2127,44 @@ func (b *Bot) notifyParticipants(session *domain.Session, responderID int64, res
 		return
 	}
 
-	// Build notification message based on response type
-	var notificationMsg string
-	switch responseType {
-	case domain.ResponseAccepted:
-		notificationMsg = fmt.Sprintf("✅ %s идёт на перекур!", responderName)
-	case domain.ResponseAcceptedDelayed:
-		notificationMsg = fmt.Sprintf("⏱ %s придёт в течение 5 минут!", responderName)
-	case domain.ResponseDenied:
-		notificationMsg = fmt.Sprintf("❌ %s не идёт на перекур", responderName)
-	case domain.ResponseRemote:
-		notificationMsg = fmt.Sprintf("🏠 %s на удалёнке сегодня", responderName)
+	var recipients []int64
+	eventsByRecipient := make(map[int64][]responseEvent)
+
+	for _, event := range events {
+		for _, recipientID := range b.notificationRecipients(session, responses, event) {
+			if _, ok := eventsByRecipient[recipientID]; !ok {
+				recipients = append(recipients, recipientID)
+			}
+			eventsByRecipient[recipientID] = append(eventsByRecipient[recipientID], event)
+		}
 	}
 
+	// Names follow the setting of the session's chat, not the private chats
+	// the notifications go to
+	plainNames := b.service.UsesPlainNames(session.ChatID)
+	for _, recipientID := range recipients {
+		b.sendMessage(recipientID, formatResponseEvents(eventsByRecipient[recipientID], b.language(recipientID), plainNames))
+	}
+}
+
+// notificationRecipients returns who should hear about a response: the
+// initiator always, and everyone who accepted when the responder is coming too
+func (b *Bot) notificationRecipients(session *domain.Session, responses []*domain.SessionResponse, event responseEvent) []int64 {
+	var recipients []int64
+
 	// Always notify the initiator (unless they're hidden)
-	if session.InitiatorID != responderID {
+	if session.InitiatorID != event.responderID {
 		initiator, _ := b.service.GetUser(session.InitiatorID)
 		if initiator == nil || !initiator.IsHidden {
-			b.sendMessage(session.InitiatorID, notificationMsg)
+			recipients = append(recipients, session.InitiatorID)
 		}
 	}
 
 	// If response is accept or delayed, notify all other accepted users
-	if responseType == domain.ResponseAccepted || responseType == domain.ResponseAcceptedDelayed {
+	if event.responseType == domain.ResponseAccepted || event.responseType == domain.ResponseAcceptedDelayed {
 		for _, resp := range responses {
 			// Skip the responder themselves and the initiator (already notified)
-			if resp.UserID == responderID || resp.UserID == session.InitiatorID {
+			if resp.UserID == event.responderID || resp.UserID == session.InitiatorID {
 				continue
 			}
 
@@ -689,9 +2173,96 @@ func (b *Bot) notifyParticipants(session *domain.Session, responderID int64, res
 				// Don't notify hidden users
 				user, _ := b.service.GetUser(resp.UserID)
 				if user == nil || !user.IsHidden {
-					b.sendMessage(resp.UserID, notificationMsg)
+					recipients = append(recipients, resp.UserID)
 				}
 			}
 		}
 	}
+
+	return recipients
+}
+
+// formatResponseEvents builds a notification message. A single event keeps
+// the classic wording, several events are grouped by response type.
+func formatResponseEvents(events []responseEvent, lang string, plainNames bool) string {
+	// Only the latest answer of each responder matters
+	latest := make(map[int64]responseEvent)
+	var order []int64
+	for _, event := range events {
+		if _, ok := latest[event.responderID]; !ok {
+			order = append(order, event.responderID)
+		}
+		latest[event.responderID] = event
+	}
+
+	namesByType := make(map[domain.ResponseType][]string)
+	// Delayed responders are grouped by how long they need
+	delayedNames := make(map[int][]string)
+	var delays []int
+	for _, responderID := range order {
+		event := latest[responderID]
+		name := event.responderName
+		if plainNames {
+			name = strings.TrimPrefix(name, "@")
+		}
+		namesByType[event.responseType] = append(namesByType[event.responseType], name)
+
+		if event.responseType == domain.ResponseAcceptedDelayed {
+			if _, ok := delayedNames[event.delayMinutes]; !ok {
+				delays = append(delays, event.delayMinutes)
+			}
+			delayedNames[event.delayMinutes] = append(delayedNames[event.delayMinutes], name)
+		}
+	}
+
+	var lines []string
+	for _, responseType := range []domain.ResponseType{
+		domain.ResponseAccepted,
+		domain.ResponseAcceptedDelayed,
+		domain.ResponseMaybe,
+		domain.ResponseDenied,
+		domain.ResponseRemote,
+	} {
+		names := namesByType[responseType]
+		if len(names) == 0 {
+			continue
+		}
+
+		who := strings.Join(names, ", ")
+		plural := len(names) > 1
+
+		switch responseType {
+		case domain.ResponseAccepted:
+			if plural {
+				lines = append(lines, locale.Tr(lang, "notify.accepted_many", who))
+			} else {
+				lines = append(lines, locale.Tr(lang, "notify.accepted_one", who))
+			}
+		case domain.ResponseAcceptedDelayed:
+			for _, minutes := range delays {
+				names := delayedNames[minutes]
+				if len(names) > 1 {
+					lines = append(lines, locale.Tr(lang, "notify.delayed_many", strings.Join(names, ", "), minutes))
+				} else {
+					lines = append(lines, locale.Tr(lang, "notify.delayed_one", names[0], minutes))
+				}
+			}
+		case domain.ResponseMaybe:
+			if plural {
+				lines = append(lines, locale.Tr(lang, "notify.maybe_many", who))
+			} else {
+				lines = append(lines, locale.Tr(lang, "notify.maybe_one", who))
+			}
+		case domain.ResponseDenied:
+			if plural {
+				lines = append(lines, locale.Tr(lang, "notify.denied_many", who))
+			} else {
+				lines = append(lines, locale.Tr(lang, "notify.denied_one", who))
+			}
+		case domain.ResponseRemote:
+			lines = append(lines, locale.Tr(lang, "notify.remote", who))
+		}
+	}
+
+	return strings.Join(lines, "\n")
 }
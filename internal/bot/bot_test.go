@@ -0,0 +1,434 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/glebk/smoke-bot/internal/config"
+	"github.com/glebk/smoke-bot/internal/domain"
+	"github.com/glebk/smoke-bot/internal/repository/memory"
+	"github.com/glebk/smoke-bot/internal/service"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// fakeSender records what the bot sends. When reject is set, it decides
+// which sends fail.
+type fakeSender struct {
+	mu     sync.Mutex
+	sent   []tgbotapi.Chattable
+	reject func(c tgbotapi.Chattable) error
+}
+
+func (s *fakeSender) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sent = append(s.sent, c)
+	if s.reject != nil {
+		if err := s.reject(c); err != nil {
+			return tgbotapi.Message{}, err
+		}
+	}
+	sent := tgbotapi.Message{MessageID: len(s.sent)}
+	if msg, ok := c.(tgbotapi.MessageConfig); ok {
+		sent.Chat = &tgbotapi.Chat{ID: msg.ChatID}
+	}
+	return sent, nil
+}
+
+func (s *fakeSender) Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error) {
+	_, err := s.Send(c)
+	return &tgbotapi.APIResponse{Ok: err == nil}, err
+}
+
+func (s *fakeSender) messages() []tgbotapi.Chattable {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]tgbotapi.Chattable(nil), s.sent...)
+}
+
+// rejectMarkdown fails every formatted message the way Telegram does when
+// it can't parse the markup
+func rejectMarkdown(c tgbotapi.Chattable) error {
+	if msg, ok := c.(tgbotapi.MessageConfig); ok && msg.ParseMode != "" {
+		return &tgbotapi.Error{
+			Code:    http.StatusBadRequest,
+			Message: "Bad Request: can't parse entities: Can't find end of the entity starting at byte offset 7",
+		}
+	}
+	return nil
+}
+
+func TestSendFallsBackToPlainText(t *testing.T) {
+	out := &fakeSender{reject: rejectMarkdown}
+	b := &Bot{sender: out}
+
+	msg := tgbotapi.NewMessage(42, "*@user_name* joined")
+	msg.ParseMode = "Markdown"
+
+	if _, err := b.send(msg); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+
+	sent := out.messages()
+	if len(sent) != 2 {
+		t.Fatalf("sent %d messages, want the rejected one and its plain retry", len(sent))
+	}
+
+	retry := sent[1].(tgbotapi.MessageConfig)
+	if retry.ParseMode != "" {
+		t.Errorf("retry parse mode = %q, want plain text", retry.ParseMode)
+	}
+	if retry.Text != msg.Text {
+		t.Errorf("retry text = %q, want %q", retry.Text, msg.Text)
+	}
+}
+
+func TestSendKeepsOtherErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{"bad request", &tgbotapi.Error{Code: http.StatusBadRequest, Message: "Bad Request: chat not found"}},
+		{"network", errors.New("connection reset by peer")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := &fakeSender{reject: func(tgbotapi.Chattable) error { return tt.err }}
+			b := &Bot{sender: out}
+
+			msg := tgbotapi.NewMessage(42, "*hello*")
+			msg.ParseMode = "Markdown"
+
+			if _, err := b.send(msg); !errors.Is(err, tt.err) {
+				t.Errorf("send error = %v, want %v", err, tt.err)
+			}
+			if n := len(out.messages()); n != 1 {
+				t.Errorf("sent %d messages, want no retry", n)
+			}
+		})
+	}
+}
+
+func TestSendDoesNotRetryPlainText(t *testing.T) {
+	parseErr := &tgbotapi.Error{Code: http.StatusBadRequest, Message: "Bad Request: can't parse entities"}
+	out := &fakeSender{reject: func(tgbotapi.Chattable) error { return parseErr }}
+	b := &Bot{sender: out}
+
+	if _, err := b.send(tgbotapi.NewMessage(42, "plain")); err == nil {
+		t.Fatal("send succeeded, want the parse error")
+	}
+	if n := len(out.messages()); n != 1 {
+		t.Errorf("sent %d messages, want a plain message to be tried once", n)
+	}
+}
+
+func TestParseSmokeArguments(t *testing.T) {
+	tests := []struct {
+		args        string
+		wantMinutes int
+		wantNote    string
+		wantOK      bool
+	}{
+		{"", 0, "", true},
+		{"20", 20, "", true},
+		{"20 на крыше", 20, "на крыше", true},
+		{"на крыше", 0, "на крыше", true},
+		{"90 минут на крыше", 0, "90 минут на крыше", true},
+		{"0 этаж", 0, "0 этаж", true},
+		{"3-й этаж", 0, "3-й этаж", true},
+		{"+5 у входа", 0, "+5 у входа", true},
+		{"90", 0, "", false},
+		{"0", 0, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.args, func(t *testing.T) {
+			minutes, note, ok := parseSmokeArguments(tt.args)
+			if ok != tt.wantOK {
+				t.Fatalf("parseSmokeArguments(%q) ok = %v, want %v", tt.args, ok, tt.wantOK)
+			}
+			if !ok {
+				return
+			}
+			if minutes != tt.wantMinutes || note != tt.wantNote {
+				t.Errorf("parseSmokeArguments(%q) = %d, %q, want %d, %q", tt.args, minutes, note, tt.wantMinutes, tt.wantNote)
+			}
+		})
+	}
+}
+
+func TestInvitationSessionID(t *testing.T) {
+	invitation := &tgbotapi.Message{ReplyMarkup: &tgbotapi.InlineKeyboardMarkup{
+		InlineKeyboard: invitationKeyboard("en", 5, func(action string) string { return action + ":42" }).InlineKeyboard,
+	}}
+	vote := &tgbotapi.Message{ReplyMarkup: &tgbotapi.InlineKeyboardMarkup{
+		InlineKeyboard: voteKeyboard(&domain.Session{ID: 7, Options: []string{"roof", "yard"}}).InlineKeyboard,
+	}}
+	approval := &tgbotapi.Message{ReplyMarkup: &tgbotapi.InlineKeyboardMarkup{
+		InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{
+			tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("OK", "approve:42")),
+		},
+	}}
+
+	tests := []struct {
+		name    string
+		message *tgbotapi.Message
+		wantID  int64
+		wantOK  bool
+	}{
+		{"no reply", nil, 0, false},
+		{"reply without buttons", &tgbotapi.Message{Text: "hi"}, 0, false},
+		{"invitation", invitation, 42, true},
+		{"vote", vote, 7, true},
+		{"other buttons", approval, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, ok := invitationSessionID(tt.message)
+			if id != tt.wantID || ok != tt.wantOK {
+				t.Errorf("invitationSessionID() = %d, %v, want %d, %v", id, ok, tt.wantID, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestRepeatsLastTap(t *testing.T) {
+	start := time.Date(2024, 3, 5, 12, 0, 0, 0, time.UTC)
+
+	type tap struct {
+		userID int64
+		data   string
+		after  time.Duration
+		want   bool
+	}
+	tests := []struct {
+		name string
+		taps []tap
+	}{
+		{"double tap", []tap{
+			{1, "accept:7", 0, false},
+			{1, "accept:7", 500 * time.Millisecond, true},
+		}},
+		{"changing one's mind back", []tap{
+			{1, "accept:7", 0, false},
+			{1, "deny:7", time.Second, false},
+			{1, "accept:7", 2 * time.Second, false},
+		}},
+		{"same button after the window", []tap{
+			{1, "accept:7", 0, false},
+			{1, "accept:7", callbackDedupWindow, false},
+		}},
+		{"another user", []tap{
+			{1, "accept:7", 0, false},
+			{2, "accept:7", time.Second, false},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &Bot{recentCallbacks: make(map[int64]lastTap)}
+			for i, tap := range tt.taps {
+				if got := b.repeatsLastTap(tap.userID, tap.data, start.Add(tap.after)); got != tap.want {
+					t.Errorf("tap %d (%s by %d) duplicate = %v, want %v", i+1, tap.data, tap.userID, got, tap.want)
+				}
+			}
+		})
+	}
+}
+
+func TestIsPersistentUpdatesError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"network", errors.New("dial tcp: i/o timeout"), false},
+		{"rate limited", &tgbotapi.Error{Code: http.StatusTooManyRequests, Message: "Too Many Requests"}, false},
+		{"server error", &tgbotapi.Error{Code: http.StatusBadGateway, Message: "Bad Gateway"}, false},
+		{"revoked token", &tgbotapi.Error{Code: http.StatusUnauthorized, Message: "Unauthorized"}, true},
+		{"wrong token", &tgbotapi.Error{Code: http.StatusNotFound, Message: "Not Found"}, true},
+		{"another instance", &tgbotapi.Error{Code: http.StatusConflict, Message: "Conflict: terminated by other getUpdates request"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isPersistentUpdatesError(tt.err); got != tt.want {
+				t.Errorf("isPersistentUpdatesError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSendRetriesRateLimitedMessagesInBackground(t *testing.T) {
+	limited := &tgbotapi.Error{
+		Code:               http.StatusTooManyRequests,
+		Message:            "Too Many Requests: retry after 1",
+		ResponseParameters: tgbotapi.ResponseParameters{RetryAfter: 1},
+	}
+	calls := 0
+	out := &fakeSender{reject: func(tgbotapi.Chattable) error {
+		calls++
+		if calls == 1 {
+			return limited
+		}
+		return nil
+	}}
+	b := &Bot{sender: out, retryTimers: make(map[int]*time.Timer)}
+
+	start := time.Now()
+	if _, err := b.send(tgbotapi.NewMessage(42, "hello")); !errors.Is(err, limited) {
+		t.Fatalf("send error = %v, want the rate limit", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("send blocked for %s waiting out the rate limit", elapsed)
+	}
+
+	deadline := time.Now().Add(3 * time.Second)
+	for len(out.messages()) < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("the rate-limited message was not sent again")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestStopDropsPendingRetries(t *testing.T) {
+	limited := &tgbotapi.Error{
+		Code:               http.StatusTooManyRequests,
+		ResponseParameters: tgbotapi.ResponseParameters{RetryAfter: 1},
+	}
+	out := &fakeSender{reject: func(tgbotapi.Chattable) error { return limited }}
+	b := &Bot{sender: out, retryTimers: make(map[int]*time.Timer)}
+
+	b.send(tgbotapi.NewMessage(42, "hello"))
+	b.stopRetries()
+
+	time.Sleep(1500 * time.Millisecond)
+	if n := len(out.messages()); n != 1 {
+		t.Errorf("sent %d messages, want the retry dropped", n)
+	}
+}
+
+func TestInviteToSessionRecordsRateLimitedSends(t *testing.T) {
+	// Every chat's first message is rate limited
+	limited := &tgbotapi.Error{
+		Code:               http.StatusTooManyRequests,
+		ResponseParameters: tgbotapi.ResponseParameters{RetryAfter: 1},
+	}
+	seen := make(map[int64]bool)
+	out := &fakeSender{reject: func(c tgbotapi.Chattable) error {
+		chatID := c.(tgbotapi.MessageConfig).ChatID
+		if seen[chatID] {
+			return nil
+		}
+		seen[chatID] = true
+		return limited
+	}}
+
+	users := memory.NewUserRepository()
+	svc := service.NewSmokeService(users, memory.NewSessionRepository(users), memory.NewChatRepository(),
+		memory.NewStateRepository(), 15*time.Minute, 0, time.UTC, false)
+	cfg := &config.Config{WorkingHours: config.WorkingHours{StartHour: 0, EndHour: 24, WorkWeekends: true, Location: time.UTC}}
+	b := &Bot{sender: out, service: svc, config: cfg, retryTimers: make(map[int]*time.Timer)}
+	defer b.stopRetries()
+
+	for _, id := range []int64{1, 2} {
+		if _, err := svc.RegisterUser(id, fmt.Sprintf("user%d", id), fmt.Sprintf("User %d", id), ""); err != nil {
+			t.Fatalf("RegisterUser(%d): %v", id, err)
+		}
+	}
+	session, err := svc.StartSession(1, 1, 0, "")
+	if err != nil {
+		t.Fatalf("StartSession: %v", err)
+	}
+
+	b.inviteToSession(&tgbotapi.Message{
+		From: &tgbotapi.User{ID: 1},
+		Chat: &tgbotapi.Chat{ID: 1, Type: "private"},
+	}, session)
+
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		invited, err := svc.CountInvitationsSince(2, session.CreatedAt.Add(-time.Minute))
+		if err != nil {
+			t.Fatalf("CountInvitationsSince: %v", err)
+		}
+		stored, err := svc.GetSession(session.ID)
+		if err != nil {
+			t.Fatalf("GetSession: %v", err)
+		}
+		if invited == 1 && stored.StatusMessageID != 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("after the retries %d invitations were recorded and the status message is %d, want 1 and set", invited, stored.StatusMessageID)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestSendWithRetryWaitsOutRateLimit(t *testing.T) {
+	limited := &tgbotapi.Error{
+		Code:               http.StatusTooManyRequests,
+		ResponseParameters: tgbotapi.ResponseParameters{RetryAfter: 1},
+	}
+	calls := 0
+	out := &fakeSender{reject: func(tgbotapi.Chattable) error {
+		calls++
+		if calls == 1 {
+			return limited
+		}
+		return nil
+	}}
+	b := &Bot{sender: out, retryTimers: make(map[int]*time.Timer)}
+
+	if _, err := b.sendWithRetry(tgbotapi.NewMessage(42, "hello")); err != nil {
+		t.Fatalf("sendWithRetry: %v", err)
+	}
+	if n := len(out.messages()); n != 2 {
+		t.Errorf("sent %d messages, want the limited one and its retry", n)
+	}
+}
+
+func TestScheduledBreakPostsToGroup(t *testing.T) {
+	const groupID = -100
+
+	out := &fakeSender{}
+	users := memory.NewUserRepository()
+	svc := service.NewSmokeService(users, memory.NewSessionRepository(users), memory.NewChatRepository(),
+		memory.NewStateRepository(), 15*time.Minute, 0, time.UTC, false)
+	cfg := &config.Config{
+		WorkingHours:     config.WorkingHours{StartHour: 0, EndHour: 24, WorkWeekends: true, Location: time.UTC},
+		GroupInvitations: true,
+		ScheduledChatID:  groupID,
+	}
+	b := &Bot{sender: out, service: svc, config: cfg, retryTimers: make(map[int]*time.Timer)}
+	defer b.stopRetries()
+
+	for _, id := range []int64{1, 2} {
+		if _, err := svc.RegisterUser(id, fmt.Sprintf("user%d", id), fmt.Sprintf("User %d", id), ""); err != nil {
+			t.Fatalf("RegisterUser(%d): %v", id, err)
+		}
+	}
+
+	b.startScheduledBreak()
+
+	sent := out.messages()
+	if len(sent) != 1 {
+		t.Fatalf("sent %d messages, want only the group invitation", len(sent))
+	}
+	msg := sent[0].(tgbotapi.MessageConfig)
+	if msg.ChatID != groupID {
+		t.Errorf("invitation went to chat %d, want the group %d", msg.ChatID, groupID)
+	}
+	if msg.ReplyMarkup == nil {
+		t.Error("the group invitation has no buttons")
+	}
+}
@@ -0,0 +1,46 @@
+package bot
+
+import (
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// callbackDedupWindow is how long a repeated tap on the same button by the
+// same user is ignored
+const callbackDedupWindow = 3 * time.Second
+
+// lastTap is the button a user pressed last and when
+type lastTap struct {
+	data string
+	at   time.Time
+}
+
+// isDuplicateCallback reports whether the user pressed the same button
+// within callbackDedupWindow, as a double tap delivers two callback queries
+// with different IDs but the same data. It remembers the query otherwise.
+func (b *Bot) isDuplicateCallback(query *tgbotapi.CallbackQuery) bool {
+	return b.repeatsLastTap(query.From.ID, query.Data, time.Now())
+}
+
+// repeatsLastTap checks a tap against the user's previous one only, so
+// changing one's mind back within the window, e.g. accept, deny and accept
+// again, still counts every tap
+func (b *Bot) repeatsLastTap(userID int64, data string, now time.Time) bool {
+	b.callbacksMu.Lock()
+	defer b.callbacksMu.Unlock()
+
+	// Forget expired taps so the map only holds the last few seconds
+	for id, tap := range b.recentCallbacks {
+		if now.Sub(tap.at) >= callbackDedupWindow {
+			delete(b.recentCallbacks, id)
+		}
+	}
+
+	if tap, ok := b.recentCallbacks[userID]; ok && tap.data == data {
+		return true
+	}
+
+	b.recentCallbacks[userID] = lastTap{data: data, at: now}
+	return false
+}
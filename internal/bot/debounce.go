@@ -0,0 +1,47 @@
+package bot
+
+import (
+	"time"
+
+	"github.com/glebk/smoke-bot/internal/domain"
+)
+
+// notificationBatch collects response events of one session until the
+// debounce window closes
+type notificationBatch struct {
+	session *domain.Session
+	events  []responseEvent
+}
+
+// queueNotification buffers a response event and schedules a single flush
+// for the session when the first event of a batch arrives
+func (b *Bot) queueNotification(session *domain.Session, event responseEvent) {
+	b.pendingMu.Lock()
+	defer b.pendingMu.Unlock()
+
+	batch, ok := b.pendingNotifications[session.ID]
+	if !ok {
+		batch = &notificationBatch{session: session}
+		b.pendingNotifications[session.ID] = batch
+
+		time.AfterFunc(b.config.NotifyDebounce, func() {
+			b.flushNotifications(session.ID)
+		})
+	}
+
+	batch.events = append(batch.events, event)
+}
+
+// flushNotifications sends the combined notifications collected for a session
+func (b *Bot) flushNotifications(sessionID int64) {
+	b.pendingMu.Lock()
+	batch, ok := b.pendingNotifications[sessionID]
+	delete(b.pendingNotifications, sessionID)
+	b.pendingMu.Unlock()
+
+	if !ok || len(batch.events) == 0 {
+		return
+	}
+
+	b.deliverNotifications(batch.session, batch.events)
+}
@@ -0,0 +1,94 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/glebk/smoke-bot/internal/domain"
+	"github.com/glebk/smoke-bot/internal/locale"
+	"github.com/glebk/smoke-bot/internal/service"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// handleDemo walks an admin through a mock session. Nothing is stored and
+// nobody else is notified, so a real session can run at the same time.
+func (b *Bot) handleDemo(message *tgbotapi.Message) {
+	if !b.requireAdmin(message) {
+		return
+	}
+
+	admin, err := b.service.GetUser(message.From.ID)
+	if err != nil {
+		log.Printf("Error getting user: %v", err)
+	}
+	lang := userLanguage(admin)
+
+	b.sendMessage(message.From.ID, locale.Tr(lang, "demo.intro"))
+
+	initiatorName := message.From.FirstName
+	if admin != nil {
+		initiatorName = service.Mention(admin, false)
+	}
+
+	msg := tgbotapi.NewMessage(message.From.ID, locale.Tr(lang, "smoke.invitation", initiatorName))
+
+	msg.ReplyMarkup = invitationKeyboard(lang, b.delayMinutes(admin), func(action string) string {
+		return "demo:" + action
+	})
+
+	if _, err := b.send(msg); err != nil {
+		log.Printf("Error sending demo invitation: %v", err)
+	}
+}
+
+// handleDemoCallback answers a button press on a demo invitation and shows a
+// sample final summary
+func (b *Bot) handleDemoCallback(query *tgbotapi.CallbackQuery, action string) {
+	user, err := b.service.GetUser(query.From.ID)
+	if err != nil {
+		log.Printf("Error getting user: %v", err)
+	}
+
+	lang := userLanguage(user)
+
+	responseType, responseText, ok := responseForAction(action, lang, b.delayMinutes(user))
+	if !ok {
+		b.answerCallback(query.ID, locale.Tr(lang, "callback.unknown_action"))
+		return
+	}
+
+	b.answerCallback(query.ID, responseText)
+
+	editMsg := tgbotapi.NewEditMessageText(
+		query.Message.Chat.ID,
+		query.Message.MessageID,
+		query.Message.Text+"\n\n"+responseText,
+	)
+	if _, err := b.send(editMsg); err != nil {
+		log.Printf("Error editing message: %v", err)
+	}
+
+	name := query.From.FirstName
+	if user != nil {
+		name = service.Mention(user, false)
+	}
+
+	summary := locale.Tr(lang, "summary.title")
+	switch responseType {
+	case domain.ResponseAccepted:
+		summary += locale.Tr(lang, "summary.attended") + fmt.Sprintf("  • %s\n", name)
+	case domain.ResponseAcceptedDelayed:
+		summary += locale.Tr(lang, "summary.attended_late") + fmt.Sprintf("  • %s\n", name)
+	default:
+		summary = locale.Tr(lang, "summary.nobody")
+	}
+
+	msg := tgbotapi.NewMessage(query.Message.Chat.ID, locale.Tr(lang,
+		"demo.summary", int(b.config.SessionTimeout/time.Minute), summary))
+	msg.ParseMode = "Markdown"
+
+	if _, err := b.send(msg); err != nil {
+		log.Printf("Error sending demo summary: %v", err)
+	}
+}
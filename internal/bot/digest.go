@@ -0,0 +1,128 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/glebk/smoke-bot/internal/config"
+	"github.com/glebk/smoke-bot/internal/domain"
+	"github.com/glebk/smoke-bot/internal/locale"
+	"github.com/glebk/smoke-bot/internal/service"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// weeklyDigestRoutine runs in background and sends the team digest for the
+// previous week on Monday during working hours
+func (b *Bot) weeklyDigestRoutine(ctx context.Context) {
+	ticker := time.NewTicker(10 * time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		now := time.Now().In(b.config.WorkingHours.Location)
+		if now.Weekday() != time.Monday || !b.config.IsWorkingHours() {
+			continue
+		}
+
+		b.sendWeeklyDigest(now)
+	}
+}
+
+// sendWeeklyDigest sends last week's digest to everyone who hasn't received
+// it yet this week
+func (b *Bot) sendWeeklyDigest(now time.Time) {
+	year, week := now.ISOWeek()
+	weekKey := fmt.Sprintf("%d-W%02d", year, week)
+
+	recipients, err := b.service.GetDigestRecipients(weekKey)
+	if err != nil {
+		log.Printf("Error getting digest recipients: %v", err)
+		return
+	}
+
+	if b.config.WeeklyDigest == config.DigestAdmins {
+		var admins []*domain.User
+		for _, user := range recipients {
+			if b.config.IsAdmin(user.ID) {
+				admins = append(admins, user)
+			}
+		}
+		recipients = admins
+	}
+
+	if len(recipients) == 0 {
+		return
+	}
+
+	startOfToday := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	digest, err := b.service.GetWeeklyDigest(startOfToday.AddDate(0, 0, -7))
+	if err != nil {
+		log.Printf("Error building weekly digest: %v", err)
+		return
+	}
+
+	// Render the digest once per language
+	texts := make(map[string]string)
+
+	for _, user := range recipients {
+		// Mark first so a failing send doesn't repeat every tick
+		if err := b.service.MarkDigestSent(user.ID, weekKey); err != nil {
+			log.Printf("Error marking digest for user %d: %v", user.ID, err)
+			continue
+		}
+
+		lang := userLanguage(user)
+		if _, ok := texts[lang]; !ok {
+			texts[lang] = b.formatDigest(lang, digest)
+		}
+
+		msg := tgbotapi.NewMessage(user.ID, texts[lang])
+		msg.ParseMode = "Markdown"
+
+		if _, err := b.send(msg); err != nil {
+			log.Printf("Error sending weekly digest to user %d: %v", user.ID, err)
+		}
+	}
+}
+
+// formatDigest renders the weekly digest as a Markdown report
+func (b *Bot) formatDigest(lang string, digest *domain.Digest) string {
+	weekEnd := digest.WeekStart.AddDate(0, 0, 6)
+
+	var sb strings.Builder
+	sb.WriteString(locale.Tr(lang, "digest.title",
+		digest.WeekStart.Format("02.01"), weekEnd.Format("02.01")))
+
+	if digest.TotalBreaks == 0 {
+		sb.WriteString(locale.Tr(lang, "digest.empty"))
+		return sb.String()
+	}
+
+	sb.WriteString(locale.Tr(lang, "digest.total", digest.TotalBreaks))
+	sb.WriteString(locale.Tr(lang, "digest.busiest_day", weekdayName(lang, digest.BusiestDay), digest.BusiestDayBreaks))
+
+	if digest.TopInitiatorCount > 0 {
+		name := fmt.Sprintf("user%d", digest.TopInitiatorID)
+		if user, err := b.service.GetUser(digest.TopInitiatorID); err == nil && user != nil {
+			name = service.Mention(user, false)
+		}
+		sb.WriteString(locale.Tr(lang, "digest.top_initiator", name, digest.TopInitiatorCount))
+	}
+
+	sb.WriteString(locale.Tr(lang, "digest.average", digest.AverageAttendees))
+
+	return sb.String()
+}
+
+// weekdayName names a weekday the way the digest uses it, e.g. "on Monday"
+func weekdayName(lang string, day time.Weekday) string {
+	return locale.Tr(lang, "weekday."+strings.ToLower(day.String()))
+}
@@ -0,0 +1,88 @@
+package bot
+
+import (
+	"log"
+	"math"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// feedbackCooldown is how long a user waits between two /feedback messages
+const feedbackCooldown = time.Minute
+
+// handleFeedback relays the text after /feedback to every admin together
+// with who sent it
+func (b *Bot) handleFeedback(message *tgbotapi.Message) {
+	text := b.commandArguments(message)
+	if text == "" {
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "feedback.usage"))
+		return
+	}
+
+	if len(b.config.AdminIDs) == 0 {
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "feedback.unavailable"))
+		return
+	}
+
+	if wait := b.reserveFeedback(message.From.ID); wait > 0 {
+		seconds := int(math.Ceil(wait.Seconds()))
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "feedback.cooldown", seconds))
+		return
+	}
+
+	sender := message.From.FirstName
+	if message.From.UserName != "" {
+		sender = "@" + message.From.UserName
+	}
+
+	delivered := 0
+	for _, adminID := range b.config.AdminIDs {
+		relay := b.t(adminID, "feedback.relay", sender, message.From.ID, text)
+		if _, err := b.send(tgbotapi.NewMessage(adminID, relay)); err != nil {
+			log.Printf("Error sending feedback to admin %d: %v", adminID, err)
+			continue
+		}
+		delivered++
+	}
+
+	if delivered == 0 {
+		// Nothing got through, so let the user try again right away
+		b.releaseFeedback(message.From.ID)
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "feedback.failed"))
+		return
+	}
+
+	b.sendMessage(message.Chat.ID, b.t(message.From.ID, "feedback.sent"))
+}
+
+// reserveFeedback records that the user sends feedback now and returns
+// zero, or returns how long they still have to wait after the previous one
+func (b *Bot) reserveFeedback(userID int64) time.Duration {
+	now := time.Now()
+
+	b.feedbackMu.Lock()
+	defer b.feedbackMu.Unlock()
+
+	// Forget expired cooldowns so the map only holds the last minute
+	for id, sentAt := range b.lastFeedback {
+		if now.Sub(sentAt) >= feedbackCooldown {
+			delete(b.lastFeedback, id)
+		}
+	}
+
+	if sentAt, ok := b.lastFeedback[userID]; ok {
+		return feedbackCooldown - now.Sub(sentAt)
+	}
+
+	b.lastFeedback[userID] = now
+	return 0
+}
+
+// releaseFeedback drops the cooldown reserved by reserveFeedback
+func (b *Bot) releaseFeedback(userID int64) {
+	b.feedbackMu.Lock()
+	defer b.feedbackMu.Unlock()
+
+	delete(b.lastFeedback, userID)
+}
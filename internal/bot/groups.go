@@ -0,0 +1,173 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/glebk/smoke-bot/internal/domain"
+	"github.com/glebk/smoke-bot/internal/locale"
+	"github.com/glebk/smoke-bot/internal/service"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// handleMyChatMember reacts to changes of the bot's own membership in a chat
+func (b *Bot) handleMyChatMember(update *tgbotapi.ChatMemberUpdated) {
+	chat := update.Chat
+	b.trackChat(&chat)
+
+	if !chat.IsGroup() && !chat.IsSuperGroup() {
+		return
+	}
+
+	// Only greet when the bot actually joins, not on promotions or
+	// restrictions while it is already a member
+	if !isChatMemberGone(update.OldChatMember) || isChatMemberGone(update.NewChatMember) {
+		return
+	}
+
+	if !b.config.GroupIntro.Enabled {
+		return
+	}
+
+	// The default intro is used when no custom one is configured
+	text := b.config.GroupIntro.Text
+	if text == "" {
+		text = b.t(update.From.ID, "group.intro")
+	}
+
+	if _, err := b.send(tgbotapi.NewMessage(chat.ID, text)); err != nil {
+		log.Printf("Error sending group intro to chat %d: %v", chat.ID, err)
+	}
+}
+
+// isChatMemberGone reports whether the member is not part of the chat
+func isChatMemberGone(member tgbotapi.ChatMember) bool {
+	return member.HasLeft() || member.WasKicked()
+}
+
+// usesGroupInvitation reports whether a break started in the chat should be
+// announced with a single invitation posted to the chat instead of DMs
+func (b *Bot) usesGroupInvitation(chat *tgbotapi.Chat) bool {
+	return b.config.GroupInvitations && (chat.IsGroup() || chat.IsSuperGroup())
+}
+
+// postGroupInvitation posts the invitation of a session to the group it was
+// started in, for everyone to answer with its buttons. The message is then
+// kept up to date with the responses.
+func (b *Bot) postGroupInvitation(session *domain.Session) {
+	text, keyboard := b.groupInvitation(session)
+
+	msg := tgbotapi.NewMessage(session.ChatID, text)
+	msg.ReplyMarkup = keyboard
+
+	err := b.sendThen(msg, func(sent tgbotapi.Message) {
+		if err := b.service.SetGroupMessage(session.ID, sent.Chat.ID, sent.MessageID); err != nil {
+			log.Printf("Error saving group message of session %d: %v", session.ID, err)
+		}
+	})
+	if err != nil {
+		log.Printf("Error posting invitation to chat %d: %v", session.ChatID, err)
+	}
+}
+
+// groupInvitation renders the shared invitation of a session: who invites,
+// how everyone answered so far, and the buttons to answer or cancel
+func (b *Bot) groupInvitation(session *domain.Session) (string, tgbotapi.InlineKeyboardMarkup) {
+	lang := b.language(session.InitiatorID)
+	plainNames := b.service.UsesPlainNames(session.ChatID)
+
+	initiatorName := fmt.Sprintf("user%d", session.InitiatorID)
+	if initiator, err := b.service.GetUser(session.InitiatorID); err == nil && initiator != nil {
+		initiatorName = service.Mention(initiator, plainNames)
+	}
+
+	// A vote is answered by choosing one of its options
+	if session.IsVote() {
+		responses, err := b.service.GetSessionResponses(session.ID)
+		if err != nil {
+			log.Printf("Error getting session responses: %v", err)
+		}
+
+		text := locale.Tr(lang, "group.vote", initiatorName) + "\n\n" + b.voteTally(session, responses, plainNames)
+		keyboard := voteKeyboard(session)
+		keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, cancelKeyboard(lang, session.ID).InlineKeyboard...)
+		return text, keyboard
+	}
+
+	text := locale.Tr(lang, "group.invitation", initiatorName)
+	if session.InitiatorID == service.SystemUserID {
+		text = locale.Tr(lang, "group.scheduled")
+	}
+	if session.Note != "" {
+		text += fmt.Sprintf(" (%s)", session.Note)
+	}
+	text += "\n\n" + b.groupTally(session, lang, plainNames)
+
+	// Everyone shares the buttons, so "later" shows the default delay
+	keyboard := invitationKeyboard(lang, service.DefaultDelayMinutes, func(action string) string {
+		return fmt.Sprintf("%s:%d", action, session.ID)
+	})
+	keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, cancelKeyboard(lang, session.ID).InlineKeyboard...)
+
+	return text, keyboard
+}
+
+// groupTallyLines lists the responses shown on a group invitation, in order
+var groupTallyLines = []struct {
+	response domain.ResponseType
+	key      string
+}{
+	{domain.ResponseAccepted, "group.going"},
+	{domain.ResponseAcceptedDelayed, "group.later"},
+	{domain.ResponseMaybe, "group.maybe"},
+	{domain.ResponseDenied, "group.declined"},
+	{domain.ResponseRemote, "group.remote"},
+}
+
+// groupTally lists who gave which answer to a group invitation. The
+// initiator is left out as the invitation already names them.
+func (b *Bot) groupTally(session *domain.Session, lang string, plainNames bool) string {
+	responses, err := b.service.GetSessionResponses(session.ID)
+	if err != nil {
+		log.Printf("Error getting session responses: %v", err)
+	}
+
+	names := make(map[domain.ResponseType][]string)
+	for _, resp := range responses {
+		if resp.UserID == session.InitiatorID {
+			continue
+		}
+
+		user, err := b.service.GetUser(resp.UserID)
+		if err != nil || user == nil || user.IsHidden {
+			continue
+		}
+
+		names[resp.Response] = append(names[resp.Response], service.Mention(user, plainNames))
+	}
+
+	var lines []string
+	for _, line := range groupTallyLines {
+		if len(names[line.response]) > 0 {
+			lines = append(lines, locale.Tr(lang, line.key, strings.Join(names[line.response], ", ")))
+		}
+	}
+
+	if len(lines) == 0 {
+		return locale.Tr(lang, "group.no_answers")
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// closeGroupInvitation removes the buttons from a group invitation once its
+// session is over and appends how it ended
+func (b *Bot) closeGroupInvitation(session *domain.Session, outcome string) {
+	text, _ := b.groupInvitation(session)
+
+	edit := tgbotapi.NewEditMessageText(session.StatusChatID, session.StatusMessageID, text+"\n\n"+outcome)
+	if _, err := b.send(edit); err != nil && !isNotModifiedError(err) {
+		log.Printf("Error closing group invitation of session %d: %v", session.ID, err)
+	}
+}
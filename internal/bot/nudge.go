@@ -0,0 +1,72 @@
+package bot
+
+import (
+	"log"
+	"time"
+
+	"github.com/glebk/smoke-bot/internal/domain"
+)
+
+// scheduleNudge arranges for the initiator of a new session to be reminded
+// about /poke if nobody answers within the configured delay. Each session
+// is scheduled at most once.
+func (b *Bot) scheduleNudge(session *domain.Session) {
+	if b.config.NoResponseNudge == 0 {
+		return
+	}
+
+	b.nudgeMu.Lock()
+	defer b.nudgeMu.Unlock()
+
+	if _, ok := b.nudgeTimers[session.ID]; ok {
+		return
+	}
+
+	b.nudgeTimers[session.ID] = time.AfterFunc(b.config.NoResponseNudge, func() {
+		b.nudgeInitiator(session.ID)
+	})
+}
+
+// nudgeInitiator tells the initiator that nobody answered yet, unless the
+// session is over or somebody did respond in the meantime
+func (b *Bot) nudgeInitiator(sessionID int64) {
+	b.nudgeMu.Lock()
+	delete(b.nudgeTimers, sessionID)
+	b.nudgeMu.Unlock()
+
+	session, err := b.service.GetSession(sessionID)
+	if err != nil || session == nil {
+		log.Printf("Error getting session %d to nudge: %v", sessionID, err)
+		return
+	}
+
+	if session.Status != domain.SessionStatusActive {
+		return
+	}
+
+	responses, err := b.service.GetSessionResponses(sessionID)
+	if err != nil {
+		log.Printf("Error getting responses of session %d: %v", sessionID, err)
+		return
+	}
+
+	// The initiator counts as coming from the start
+	for _, resp := range responses {
+		if resp.UserID != session.InitiatorID {
+			return
+		}
+	}
+
+	b.sendMessage(session.InitiatorID, b.t(session.InitiatorID, "nudge.no_responses"))
+}
+
+// stopNudges cancels the reminders that haven't fired yet
+func (b *Bot) stopNudges() {
+	b.nudgeMu.Lock()
+	defer b.nudgeMu.Unlock()
+
+	for sessionID, timer := range b.nudgeTimers {
+		timer.Stop()
+		delete(b.nudgeTimers, sessionID)
+	}
+}
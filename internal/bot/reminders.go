@@ -0,0 +1,94 @@
+package bot
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/glebk/smoke-bot/internal/domain"
+	"github.com/glebk/smoke-bot/internal/locale"
+	"github.com/glebk/smoke-bot/internal/service"
+)
+
+// delayedRemindersRoutine runs in background and pings users who promised to
+// come later once their delay has passed
+func (b *Bot) delayedRemindersRoutine(ctx context.Context) {
+	ticker := time.NewTicker(15 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		b.remindDelayedResponders()
+	}
+}
+
+// remindDelayedResponders sends a one-time reminder for every due delayed response
+func (b *Bot) remindDelayedResponders() {
+	responses, err := b.service.GetDueDelayedResponses()
+	if err != nil {
+		log.Printf("Error getting due delayed responses: %v", err)
+		return
+	}
+
+	for _, resp := range responses {
+		// Mark first so a failing send never results in repeated pings
+		if err := b.service.MarkReminderSent(resp.ID); err != nil {
+			log.Printf("Error marking reminder for response %d: %v", resp.ID, err)
+			continue
+		}
+
+		user, err := b.service.GetUser(resp.UserID)
+		if err != nil || user == nil {
+			continue
+		}
+
+		b.sendMessage(user.ID, locale.Tr(userLanguage(user), "reminder.due", user.DelayMinutes))
+
+		// Hidden users stay invisible to everyone else
+		if user.IsHidden {
+			continue
+		}
+
+		session, err := b.service.GetSession(resp.SessionID)
+		if err != nil || session == nil || session.Status != domain.SessionStatusActive {
+			continue
+		}
+
+		b.notifyDelayedDue(session, user)
+	}
+}
+
+// notifyDelayedDue tells the initiator and everyone who accepted that a
+// delayed participant should be arriving
+func (b *Bot) notifyDelayedDue(session *domain.Session, user *domain.User) {
+	responses, err := b.service.GetSessionResponses(session.ID)
+	if err != nil {
+		log.Printf("Error getting session responses: %v", err)
+		return
+	}
+
+	recipients := []int64{session.InitiatorID}
+	for _, resp := range responses {
+		if resp.Response == domain.ResponseAccepted || resp.Response == domain.ResponseAcceptedDelayed {
+			recipients = append(recipients, resp.UserID)
+		}
+	}
+
+	notified := map[int64]bool{user.ID: true}
+	for _, recipientID := range recipients {
+		if notified[recipientID] {
+			continue
+		}
+		notified[recipientID] = true
+
+		recipient, _ := b.service.GetUser(recipientID)
+		if recipient == nil || !recipient.IsHidden {
+			b.sendMessage(recipientID, locale.Tr(userLanguage(recipient), "reminder.due_notify", user.DelayMinutes, service.Mention(user, false)))
+		}
+	}
+}
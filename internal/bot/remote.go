@@ -0,0 +1,36 @@
+package bot
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// remoteResetRoutine runs in background and clears expired remote statuses
+// once a day when working hours start, whether or not anyone starts a break
+func (b *Bot) remoteResetRoutine(ctx context.Context) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	// lastReset is the day the statuses were last cleared
+	var lastReset string
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		now := time.Now().In(b.config.WorkingHours.Location)
+		today := now.Format("2006-01-02")
+		if now.Hour() < b.config.WorkingHours.StartHour || lastReset == today {
+			continue
+		}
+		lastReset = today
+
+		if err := b.service.ClearExpiredRemoteStatus(); err != nil {
+			log.Printf("Error clearing expired remote status: %v", err)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package bot
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Limits for retrying sends Telegram rejected with 429 Too Many Requests
+const (
+	maxSendRetries = 3
+	maxRetryWait   = 30 * time.Second
+)
+
+// errRetryScheduled marks a send that was rate limited and will be retried
+// on a timer
+var errRetryScheduled = errors.New("rate limited, retry scheduled")
+
+// retryLater sends a rate-limited message again once the wait Telegram
+// asked for is over, and reports whether it will. Waiting happens on a
+// timer, so the update loop keeps handling other users meanwhile. The
+// message is dropped after maxSendRetries retries or when Telegram asks to
+// wait longer than maxRetryWait. delivered, if set, runs once it is sent.
+func (b *Bot) retryLater(c tgbotapi.Chattable, wait time.Duration, attempt int, delivered func(tgbotapi.Message)) bool {
+	if attempt > maxSendRetries || wait > maxRetryWait {
+		log.Printf("Rate limited by Telegram, dropping message (asked to wait %s, attempt %d)", wait, attempt)
+		return false
+	}
+
+	log.Printf("Rate limited by Telegram, retrying in %s (attempt %d/%d)", wait, attempt, maxSendRetries)
+
+	b.retryMu.Lock()
+	defer b.retryMu.Unlock()
+
+	b.nextRetryID++
+	id := b.nextRetryID
+	b.retryTimers[id] = time.AfterFunc(wait, func() {
+		b.retryMu.Lock()
+		_, pending := b.retryTimers[id]
+		delete(b.retryTimers, id)
+		b.retryMu.Unlock()
+
+		// Stop got to it first
+		if !pending {
+			return
+		}
+
+		if _, err := b.deliver(c, attempt, delivered); err != nil && !errors.Is(err, errRetryScheduled) {
+			log.Printf("Error resending rate-limited message: %v", err)
+		}
+	})
+	return true
+}
+
+// sendThen sends a message and calls delivered with it once it is sent,
+// right away or after a rate-limited retry goes through. The error is only
+// returned when the message won't be sent.
+func (b *Bot) sendThen(c tgbotapi.Chattable, delivered func(tgbotapi.Message)) error {
+	_, err := b.deliver(c, 0, delivered)
+	if errors.Is(err, errRetryScheduled) {
+		return nil
+	}
+	return err
+}
+
+// sendWithRetry sends a message and, when Telegram rate limits it, waits
+// and retries before reporting the result. It blocks, so use it only off
+// the update loop, e.g. in background routines.
+func (b *Bot) sendWithRetry(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	for attempt := 0; ; attempt++ {
+		sent, last, err := b.sendOnce(c)
+		c = last
+		wait, limited := retryAfter(err)
+		if !limited || attempt >= maxSendRetries || wait > maxRetryWait {
+			return sent, err
+		}
+
+		log.Printf("Rate limited by Telegram, retrying in %s (attempt %d/%d)", wait, attempt+1, maxSendRetries)
+		time.Sleep(wait)
+	}
+}
+
+// stopRetries drops the rate-limited messages still waiting to be resent
+func (b *Bot) stopRetries() {
+	b.retryMu.Lock()
+	defer b.retryMu.Unlock()
+
+	if len(b.retryTimers) > 0 {
+		log.Printf("Dropping %d rate-limited messages on shutdown", len(b.retryTimers))
+	}
+	for id, timer := range b.retryTimers {
+		timer.Stop()
+		delete(b.retryTimers, id)
+	}
+}
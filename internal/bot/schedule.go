@@ -0,0 +1,93 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/glebk/smoke-bot/internal/domain"
+	"github.com/glebk/smoke-bot/internal/locale"
+	"github.com/glebk/smoke-bot/internal/service"
+)
+
+// scheduledBreaksRoutine runs in background and starts a break at every
+// configured time on working days during working hours
+func (b *Bot) scheduledBreaksRoutine(ctx context.Context) {
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+
+	// lastFired maps a schedule entry to the day it last fired, so a slot
+	// fires once per day however often the ticker hits that minute
+	lastFired := make(map[string]string)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		now := time.Now().In(b.config.WorkingHours.Location)
+		if !b.config.IsWorkingHours() {
+			continue
+		}
+
+		today := now.Format("2006-01-02")
+		slot := now.Format("15:04")
+
+		for _, entry := range b.config.ScheduledBreaks {
+			if entry != slot || lastFired[entry] == today {
+				continue
+			}
+			lastFired[entry] = today
+
+			b.startScheduledBreak()
+		}
+	}
+}
+
+// startScheduledBreak starts a bot-initiated session and invites everyone
+func (b *Bot) startScheduledBreak() {
+	chatID := b.config.ScheduledChatID
+
+	session, err := b.service.StartScheduledSession(chatID)
+	if err != nil {
+		if errors.Is(err, service.ErrActiveSessionExists) {
+			log.Printf("Skipping scheduled break: a session is already active")
+		} else if errors.Is(err, service.ErrNoActiveUsers) {
+			log.Printf("Skipping scheduled break: nobody to invite")
+		} else {
+			log.Printf("Error starting scheduled break: %v", err)
+		}
+		return
+	}
+
+	// A group in group mode gets one shared invitation, like a break
+	// started there by hand
+	if b.config.GroupInvitations && domain.IsGroupChat(chatID) {
+		b.postGroupInvitation(session)
+		return
+	}
+
+	activeUsers, err := b.invitees(service.SystemUserID)
+	if err != nil {
+		log.Printf("Error getting active users: %v", err)
+		return
+	}
+
+	if len(activeUsers) == 0 {
+		if err := b.service.CancelSession(session.ID, ""); err != nil {
+			log.Printf("Error cancelling scheduled break: %v", err)
+		}
+		return
+	}
+
+	if chatID != 0 {
+		b.sendMessage(chatID, locale.T("schedule.started"))
+	}
+
+	for _, user := range activeUsers {
+		b.sendInvitation(user, session, locale.Tr(userLanguage(user), "schedule.invitation"))
+	}
+}
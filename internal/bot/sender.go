@@ -0,0 +1,64 @@
+package bot
+
+import (
+	"log"
+	"sync/atomic"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// sender delivers outgoing messages, edits and callback answers.
+// *tgbotapi.BotAPI sends them to Telegram; logSender only logs them.
+type sender interface {
+	Send(c tgbotapi.Chattable) (tgbotapi.Message, error)
+	Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error)
+}
+
+// logSender logs outgoing messages instead of sending them, for dry runs
+type logSender struct {
+	// lastMessageID numbers the pretend messages so they can be edited later
+	lastMessageID atomic.Int64
+}
+
+// Send logs a message and returns a stand-in for the one Telegram would create
+func (s *logSender) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	chatID := logChattable(c)
+
+	return tgbotapi.Message{
+		MessageID: int(s.lastMessageID.Add(1)),
+		Chat:      &tgbotapi.Chat{ID: chatID},
+	}, nil
+}
+
+// Request logs a request and reports success
+func (s *logSender) Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error) {
+	logChattable(c)
+	return &tgbotapi.APIResponse{Ok: true}, nil
+}
+
+// logChattable logs what would have been sent and returns the target chat
+func logChattable(c tgbotapi.Chattable) int64 {
+	switch msg := c.(type) {
+	case tgbotapi.MessageConfig:
+		log.Printf("[dry run] message to %d: %s", msg.ChatID, msg.Text)
+		return msg.ChatID
+	case tgbotapi.EditMessageTextConfig:
+		log.Printf("[dry run] edit of message %d in %d: %s", msg.MessageID, msg.ChatID, msg.Text)
+		return msg.ChatID
+	case tgbotapi.EditMessageReplyMarkupConfig:
+		log.Printf("[dry run] keyboard edit of message %d in %d", msg.MessageID, msg.ChatID)
+		return msg.ChatID
+	case tgbotapi.LocationConfig:
+		log.Printf("[dry run] location to %d: %f, %f", msg.ChatID, msg.Latitude, msg.Longitude)
+		return msg.ChatID
+	case tgbotapi.DocumentConfig:
+		log.Printf("[dry run] document to %d", msg.ChatID)
+		return msg.ChatID
+	case tgbotapi.CallbackConfig:
+		log.Printf("[dry run] callback answer %s: %s", msg.CallbackQueryID, msg.Text)
+		return 0
+	default:
+		log.Printf("[dry run] %T request", c)
+		return 0
+	}
+}
@@ -0,0 +1,82 @@
+package bot
+
+import (
+	"log"
+	"strings"
+
+	"github.com/glebk/smoke-bot/internal/domain"
+	"github.com/glebk/smoke-bot/internal/locale"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// handleSettings shows every preference of the user with its current value
+// and the command that changes it
+func (b *Bot) handleSettings(message *tgbotapi.Message) {
+	user, err := b.service.GetUser(message.From.ID)
+	if err != nil {
+		log.Printf("Error getting user: %v", err)
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "settings.load_failed"))
+		return
+	}
+
+	if user == nil {
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "user.not_registered"))
+		return
+	}
+
+	msg := tgbotapi.NewMessage(message.Chat.ID, b.formatSettings(user, message.Chat.ID))
+	msg.ParseMode = "Markdown"
+
+	if _, err := b.send(msg); err != nil {
+		log.Printf("Error sending settings: %v", err)
+	}
+}
+
+// formatSettings renders the user's preferences one per line. Mentions are
+// a setting of the chat the command was sent in.
+func (b *Bot) formatSettings(user *domain.User, chatID int64) string {
+	lang := userLanguage(user)
+	tr := func(key string, args ...any) string {
+		return locale.Tr(lang, key, args...)
+	}
+	escape := func(value string) string {
+		return tgbotapi.EscapeText(tgbotapi.ModeMarkdown, value)
+	}
+	toggle := func(enabled bool) string {
+		if enabled {
+			return tr("settings.on")
+		}
+		return tr("settings.off")
+	}
+
+	timezone := user.Timezone
+	if timezone == "" {
+		timezone = tr("timezone.default", b.config.WorkingHours.Location)
+	}
+
+	quiet := tr("settings.quiet_none")
+	if user.HasQuietHours() {
+		quiet = formatMinuteOfDay(user.QuietFrom) + " – " + formatMinuteOfDay(user.QuietTo)
+	}
+
+	lines := []string{
+		tr("settings.title"),
+		tr("settings.timezone", escape(timezone)),
+		tr("settings.delay", b.delayMinutes(user)),
+		tr("settings.quiet", quiet),
+		tr("settings.language", lang),
+		tr("settings.nick", escape(user.Display())),
+		tr("settings.invitations", toggle(!user.IsMuted)),
+		tr("settings.terse", toggle(user.TerseReplies)),
+		tr("settings.ownsummary", toggle(!user.SkipOwnSummary)),
+		tr("settings.weekly", toggle(user.WeeklySummary)),
+		tr("settings.mentions", toggle(!b.service.UsesPlainNames(chatID))),
+	}
+
+	if user.IsRemoteToday && user.RemoteUntil != nil {
+		until := user.RemoteUntil.In(b.userLocation(user)).Format("02.01 15:04")
+		lines = append(lines, tr("settings.remote", until))
+	}
+
+	return strings.Join(lines, "\n")
+}
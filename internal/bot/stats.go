@@ -0,0 +1,244 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/glebk/smoke-bot/internal/domain"
+	"github.com/glebk/smoke-bot/internal/locale"
+	"github.com/glebk/smoke-bot/internal/service"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// leaderboardSize is how many users ranking commands show
+const leaderboardSize = 10
+
+// statsWindow is the default period personal stats cover
+const statsWindow = 30 * 24 * time.Hour
+
+// historySize is how many sessions /history lists
+const historySize = 10
+
+// handleMyStats shows how many invitations the user got and answered
+func (b *Bot) handleMyStats(message *tgbotapi.Message) {
+	invitations, answered, err := b.service.GetNotificationStats(message.From.ID, time.Now().Add(-statsWindow))
+	if err != nil {
+		log.Printf("Error getting notification stats: %v", err)
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "stats.failed"))
+		return
+	}
+
+	if invitations == 0 {
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "mystats.none"))
+		return
+	}
+
+	rate := answered * 100 / invitations
+
+	text := b.t(message.From.ID, "mystats.text", invitations, answered, rate)
+
+	msg := tgbotapi.NewMessage(message.Chat.ID, text)
+	msg.ParseMode = "Markdown"
+
+	if _, err := b.send(msg); err != nil {
+		log.Printf("Error sending my stats: %v", err)
+	}
+}
+
+// handleStats shows how the user responded to invitations over the last 30 days
+func (b *Bot) handleStats(message *tgbotapi.Message) {
+	since := time.Now().Add(-statsWindow)
+
+	accepted, delayed, denied, err := b.service.GetUserStats(message.From.ID, since)
+	if err != nil {
+		log.Printf("Error getting user stats: %v", err)
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "stats.failed"))
+		return
+	}
+
+	attended, unconfirmed, err := b.service.GetAttendanceStats(message.From.ID, since)
+	if err != nil {
+		log.Printf("Error getting attendance stats: %v", err)
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "stats.failed"))
+		return
+	}
+
+	if accepted+delayed+denied == 0 {
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "stats.none"))
+		return
+	}
+
+	text := b.t(message.From.ID, "stats.text", accepted, delayed, denied, attended, unconfirmed)
+
+	msg := tgbotapi.NewMessage(message.Chat.ID, text)
+	msg.ParseMode = "Markdown"
+
+	if _, err := b.send(msg); err != nil {
+		log.Printf("Error sending stats: %v", err)
+	}
+}
+
+// handleStreak shows how many working days in a row the user joined a break
+func (b *Bot) handleStreak(message *tgbotapi.Message) {
+	user, err := b.service.GetUser(message.From.ID)
+	if err != nil || user == nil {
+		log.Printf("Error getting user %d: %v", message.From.ID, err)
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "stats.failed"))
+		return
+	}
+
+	streak, err := b.service.GetCurrentStreak(user.ID, b.config.WorkingHours, b.userLocation(user))
+	if err != nil {
+		log.Printf("Error getting streak: %v", err)
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "stats.failed"))
+		return
+	}
+
+	if streak == 0 {
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "streak.none"))
+		return
+	}
+
+	b.sendMessage(message.Chat.ID, b.t(message.From.ID, "streak.current", streak))
+}
+
+// handleHistory lists the latest sessions the user started or joined
+func (b *Bot) handleHistory(message *tgbotapi.Message) {
+	entries, err := b.service.GetUserHistory(message.From.ID, historySize)
+	if err != nil {
+		log.Printf("Error getting history: %v", err)
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "history.failed"))
+		return
+	}
+
+	if len(entries) == 0 {
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "history.none"))
+		return
+	}
+
+	lang := b.language(message.From.ID)
+
+	var sb strings.Builder
+	sb.WriteString(locale.Tr(lang, "history.title"))
+	for _, entry := range entries {
+		sb.WriteString(b.formatHistoryEntry(lang, entry))
+		sb.WriteString("\n")
+	}
+
+	msg := tgbotapi.NewMessage(message.Chat.ID, sb.String())
+	msg.ParseMode = "Markdown"
+
+	if _, err := b.send(msg); err != nil {
+		log.Printf("Error sending history: %v", err)
+	}
+}
+
+// formatHistoryEntry renders one /history line: start time, duration and
+// how many people came
+func (b *Bot) formatHistoryEntry(lang string, entry domain.SessionHistoryEntry) string {
+	session := entry.Session
+	started := session.CreatedAt.In(b.config.WorkingHours.Location).Format("02.01 15:04")
+
+	switch {
+	case session.Status == domain.SessionStatusCancelled:
+		return locale.Tr(lang, "history.cancelled", started)
+	case session.Failed:
+		return locale.Tr(lang, "history.not_happened", started, entry.Attendees)
+	case session.CompletedAt == nil:
+		return locale.Tr(lang, "history.running", started, entry.Attendees)
+	default:
+		return locale.Tr(lang, "history.completed", started, durationMinutes(session), entry.Attendees)
+	}
+}
+
+// handleOrganizers shows who started the most sessions this month
+func (b *Bot) handleOrganizers(message *tgbotapi.Message) {
+	entries, err := b.service.GetInitiatorLeaderboard(b.startOfMonth(), leaderboardSize)
+	if err != nil {
+		log.Printf("Error getting initiator leaderboard: %v", err)
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "stats.failed"))
+		return
+	}
+
+	if len(entries) == 0 {
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "organizers.none"))
+		return
+	}
+
+	text := b.t(message.From.ID, "organizers.title") + b.formatLeaderboard(entries)
+
+	msg := tgbotapi.NewMessage(message.Chat.ID, text)
+	msg.ParseMode = "Markdown"
+
+	if _, err := b.send(msg); err != nil {
+		log.Printf("Error sending organizers: %v", err)
+	}
+}
+
+// handleLeaderboard shows who joined the most sessions this month
+func (b *Bot) handleLeaderboard(message *tgbotapi.Message) {
+	entries, err := b.service.GetLeaderboard(b.startOfMonth(), leaderboardSize)
+	if err != nil {
+		log.Printf("Error getting leaderboard: %v", err)
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "stats.failed"))
+		return
+	}
+
+	if len(entries) == 0 {
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "leaderboard.none"))
+		return
+	}
+
+	text := b.t(message.From.ID, "leaderboard.title") + b.formatLeaderboard(entries)
+
+	msg := tgbotapi.NewMessage(message.Chat.ID, text)
+	msg.ParseMode = "Markdown"
+
+	if _, err := b.send(msg); err != nil {
+		log.Printf("Error sending leaderboard: %v", err)
+	}
+}
+
+// formatLeaderboard renders ranking entries as a numbered list. Users with
+// equal counts share the same place.
+func (b *Bot) formatLeaderboard(entries []domain.LeaderboardEntry) string {
+	var sb strings.Builder
+
+	place := 0
+	for i, entry := range entries {
+		if i == 0 || entry.Count != entries[i-1].Count {
+			place = i + 1
+		}
+
+		name := fmt.Sprintf("user%d", entry.UserID)
+		if user, err := b.service.GetUser(entry.UserID); err == nil && user != nil {
+			name = service.Mention(user, false)
+		}
+
+		sb.WriteString(fmt.Sprintf("%s %s — %d\n", placeLabel(place), name, entry.Count))
+	}
+
+	return sb.String()
+}
+
+// placeLabel returns a medal for the top three places and a number otherwise
+func placeLabel(place int) string {
+	switch place {
+	case 1:
+		return "🥇"
+	case 2:
+		return "🥈"
+	case 3:
+		return "🥉"
+	default:
+		return fmt.Sprintf("%d.", place)
+	}
+}
+
+// startOfMonth returns the beginning of the current month in the bot's timezone
+func (b *Bot) startOfMonth() time.Time {
+	now := time.Now().In(b.config.WorkingHours.Location)
+	return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+}
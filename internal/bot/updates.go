@@ -0,0 +1,90 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// updatesTimeout is how long a long-polling request waits for updates
+	updatesTimeout = 60
+
+	// reconnectMinBackoff and reconnectMaxBackoff bound the wait between
+	// attempts to reach Telegram again; it doubles after every failure
+	reconnectMinBackoff = time.Second
+	reconnectMaxBackoff = time.Minute
+)
+
+// pollUpdates long-polls Telegram for updates starting at offset until ctx
+// is cancelled. Polling stops and the error is sent on the second channel at
+// the first failure. With ReconnectUpdates a failed request is retried with
+// exponential backoff instead, as the network or Telegram are usually back
+// soon, and polling only stops when retrying can't help.
+func (b *Bot) pollUpdates(ctx context.Context, offset int) (<-chan tgbotapi.Update, <-chan error) {
+	updates := make(chan tgbotapi.Update)
+	errs := make(chan error, 1)
+
+	go func() {
+		u := tgbotapi.NewUpdate(offset)
+		u.Timeout = updatesTimeout
+		backoff := reconnectMinBackoff
+
+		for ctx.Err() == nil {
+			batch, err := b.api.GetUpdates(u)
+			if err != nil {
+				if !b.config.ReconnectUpdates || isPersistentUpdatesError(err) {
+					errs <- fmt.Errorf("failed to get updates: %w", err)
+					return
+				}
+
+				log.Printf("Error getting updates, retrying in %s: %v", backoff, err)
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(backoff):
+				}
+				backoff = min(backoff*2, reconnectMaxBackoff)
+				continue
+			}
+			backoff = reconnectMinBackoff
+
+			for _, update := range batch {
+				if update.UpdateID < u.Offset {
+					continue
+				}
+				u.Offset = update.UpdateID + 1
+
+				select {
+				case <-ctx.Done():
+					return
+				case updates <- update:
+				}
+			}
+		}
+	}()
+
+	return updates, errs
+}
+
+// isPersistentUpdatesError reports whether polling failed in a way retrying
+// won't fix: the token is wrong or was revoked, or another instance of the
+// bot is polling with the same token
+func isPersistentUpdatesError(err error) bool {
+	var apiErr *tgbotapi.Error
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+
+	switch apiErr.Code {
+	case http.StatusUnauthorized, http.StatusNotFound, http.StatusConflict:
+		return true
+	default:
+		return false
+	}
+}
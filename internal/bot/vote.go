@@ -0,0 +1,254 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/glebk/smoke-bot/internal/domain"
+	"github.com/glebk/smoke-bot/internal/locale"
+	"github.com/glebk/smoke-bot/internal/service"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// handleVote starts a vote between the comma-separated options after the
+// command, e.g. /vote крыша, задний двор, вообще не идём. It is sent like
+// an invitation, with one button per option.
+func (b *Bot) handleVote(message *tgbotapi.Message) {
+	if !b.config.IsWorkingHoursFor(b.userTimezone(message.From.ID)) {
+		b.sendMessage(message.Chat.ID, b.outsideHoursMessage(message.From.ID))
+		return
+	}
+
+	args := b.commandArguments(message)
+	if args == "" {
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "vote.usage", service.MinVoteOptions, service.MaxVoteOptions))
+		return
+	}
+
+	if !b.checkInitiatorApproved(message) {
+		return
+	}
+
+	session, err := b.service.StartVote(message.From.ID, message.Chat.ID, strings.Split(args, ","))
+	if errors.Is(err, service.ErrInvalidVoteOptions) {
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "vote.usage", service.MinVoteOptions, service.MaxVoteOptions))
+		return
+	}
+	if err != nil {
+		b.reportStartError(message, err)
+		return
+	}
+
+	b.inviteToSession(message, session)
+}
+
+// voteKeyboard builds one button per option of a vote. The callback data
+// carries the option's index, as the option itself may not fit.
+func voteKeyboard(session *domain.Session) tgbotapi.InlineKeyboardMarkup {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for i, option := range session.Options {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(option, fmt.Sprintf("vote:%d/%d", session.ID, i)),
+		))
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// parseVoteData reads the session ID and option index of a vote button
+func parseVoteData(payload string) (int64, int, bool) {
+	sessionPart, optionPart, ok := strings.Cut(payload, "/")
+	if !ok {
+		return 0, 0, false
+	}
+
+	sessionID, err := strconv.ParseInt(sessionPart, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+
+	option, err := strconv.Atoi(optionPart)
+	if err != nil {
+		return 0, 0, false
+	}
+
+	return sessionID, option, true
+}
+
+// handleVoteCallback records the option a user picked in a vote
+func (b *Bot) handleVoteCallback(query *tgbotapi.CallbackQuery, payload string) {
+	sessionID, option, ok := parseVoteData(payload)
+	if !ok {
+		b.answerCallback(query.ID, "Invalid vote")
+		return
+	}
+
+	b.registerUser(query.From)
+
+	session, err := b.service.GetSession(sessionID)
+	if err != nil || session == nil || session.Status != domain.SessionStatusActive {
+		b.answerCallback(query.ID, b.t(query.From.ID, "callback.invitation_inactive"))
+		return
+	}
+
+	choice, changed, err := b.service.Vote(sessionID, query.From.ID, option)
+	if err != nil {
+		log.Printf("Error recording vote: %v", err)
+		b.answerCallback(query.ID, b.t(query.From.ID, "respond.failed"))
+		return
+	}
+
+	// Picking the same option again has nothing new to announce
+	if !changed {
+		b.answerCallback(query.ID, "")
+		return
+	}
+
+	confirmation := b.t(query.From.ID, "vote.recorded", choice)
+	b.answerCallback(query.ID, b.reply(query.From.ID, confirmation, "✅"))
+
+	// Show the choice on a DM invitation, a group invitation is shared and
+	// refreshed with the tally instead
+	if !session.Shared {
+		edit := tgbotapi.NewEditMessageText(query.Message.Chat.ID, query.Message.MessageID,
+			query.Message.Text+"\n\n"+confirmation)
+		if _, err := b.send(edit); err != nil {
+			log.Printf("Error editing message: %v", err)
+		}
+	}
+
+	b.notifyVote(session, query.From.ID, choice)
+}
+
+// notifyVote refreshes the tally of a vote and tells the initiator who
+// picked what
+func (b *Bot) notifyVote(session *domain.Session, voterID int64, choice string) {
+	voter, err := b.service.GetUser(voterID)
+	if err != nil || voter == nil {
+		log.Printf("Error getting voter %d: %v", voterID, err)
+		return
+	}
+
+	// Don't notify about hidden users
+	if voter.IsHidden {
+		return
+	}
+
+	b.updateStatusMessage(session)
+
+	if session.Shared || voterID == session.InitiatorID {
+		return
+	}
+
+	initiator, _ := b.service.GetUser(session.InitiatorID)
+	if initiator != nil && initiator.IsHidden {
+		return
+	}
+
+	name := service.Mention(voter, b.service.UsesPlainNames(session.ChatID))
+	b.sendMessage(session.InitiatorID, b.t(session.InitiatorID, "vote.notify", name, choice))
+}
+
+// voteTally lists each option of a vote with how many picked it and who.
+// Hidden users are left out.
+func (b *Bot) voteTally(session *domain.Session, responses []*domain.SessionResponse, plainNames bool) string {
+	var visible []*domain.SessionResponse
+	voters := make(map[string][]string)
+	for _, resp := range responses {
+		user, err := b.service.GetUser(resp.UserID)
+		if err != nil || user == nil || user.IsHidden {
+			continue
+		}
+		visible = append(visible, resp)
+		if resp.Response == domain.ResponseVoted {
+			voters[resp.Option] = append(voters[resp.Option], service.Mention(user, plainNames))
+		}
+	}
+
+	counts := session.TallyVotes(visible)
+	lines := make([]string, len(session.Options))
+	for i, option := range session.Options {
+		lines[i] = fmt.Sprintf("• %s — %d", option, counts[i])
+		if len(voters[option]) > 0 {
+			lines[i] += fmt.Sprintf(" (%s)", strings.Join(voters[option], ", "))
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// voteOutcome names the option with the most votes, or the tied ones
+func voteOutcome(lang string, session *domain.Session, counts []int) string {
+	best := 0
+	for _, count := range counts {
+		best = max(best, count)
+	}
+
+	if best == 0 {
+		return locale.Tr(lang, "vote.no_votes")
+	}
+
+	var leaders []string
+	for i, count := range counts {
+		if count == best {
+			leaders = append(leaders, session.Options[i])
+		}
+	}
+
+	if len(leaders) > 1 {
+		return locale.Tr(lang, "vote.tie", strings.Join(leaders, ", "))
+	}
+	return locale.Tr(lang, "vote.winner", leaders[0])
+}
+
+// notifyVoteCompleted sends the results of a finished vote to the group it
+// was posted in, or to the initiator and everyone who voted
+func (b *Bot) notifyVoteCompleted(session *domain.Session, responses []*domain.SessionResponse) {
+	var visible []*domain.SessionResponse
+	for _, resp := range responses {
+		if user, err := b.service.GetUser(resp.UserID); err == nil && user != nil && !user.IsHidden {
+			visible = append(visible, resp)
+		}
+	}
+	counts := session.TallyVotes(visible)
+
+	plainNames := b.service.UsesPlainNames(session.ChatID)
+	results := func(lang string) string {
+		return locale.Tr(lang, "vote.summary",
+			b.voteTally(session, responses, plainNames), voteOutcome(lang, session, counts))
+	}
+
+	if session.Shared {
+		lang := b.language(session.InitiatorID)
+		b.closeGroupInvitation(session, locale.Tr(lang, "vote.finished"))
+
+		msg := tgbotapi.NewMessage(session.StatusChatID, results(lang))
+		msg.ParseMode = "Markdown"
+		if _, err := b.send(msg); err != nil {
+			log.Printf("Error sending vote results to chat %d: %v", session.StatusChatID, err)
+		}
+		return
+	}
+
+	recipients := []int64{session.InitiatorID}
+	for _, resp := range visible {
+		if resp.Response == domain.ResponseVoted && resp.UserID != session.InitiatorID {
+			recipients = append(recipients, resp.UserID)
+		}
+	}
+
+	for _, userID := range recipients {
+		user, _ := b.service.GetUser(userID)
+		if user != nil && user.IsHidden {
+			continue
+		}
+
+		msg := tgbotapi.NewMessage(userID, results(b.language(userID)))
+		msg.ParseMode = "Markdown"
+		if _, err := b.send(msg); err != nil {
+			log.Printf("Error sending vote results to user %d: %v", userID, err)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/glebk/smoke-bot/internal/locale"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// weeklySummaryRoutine runs in background and sends personal weekly
+// summaries on Monday during working hours
+func (b *Bot) weeklySummaryRoutine(ctx context.Context) {
+	ticker := time.NewTicker(10 * time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		now := time.Now().In(b.config.WorkingHours.Location)
+		if now.Weekday() != time.Monday || !b.config.IsWorkingHours() {
+			continue
+		}
+
+		b.sendWeeklySummaries(now)
+	}
+}
+
+// sendWeeklySummaries sends last week's personal stats to every opted-in
+// user who hasn't received them yet this week
+func (b *Bot) sendWeeklySummaries(now time.Time) {
+	year, week := now.ISOWeek()
+	weekKey := fmt.Sprintf("%d-W%02d", year, week)
+
+	recipients, err := b.service.GetWeeklySummaryRecipients(weekKey)
+	if err != nil {
+		log.Printf("Error getting weekly summary recipients: %v", err)
+		return
+	}
+
+	startOfToday := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	since := startOfToday.AddDate(0, 0, -7)
+
+	for _, user := range recipients {
+		accepted, delayed, denied, err := b.service.GetUserStats(user.ID, since)
+		if err != nil {
+			log.Printf("Error getting weekly stats for user %d: %v", user.ID, err)
+			continue
+		}
+
+		// Mark first so a failing send doesn't repeat every tick
+		if err := b.service.MarkWeeklySummarySent(user.ID, weekKey); err != nil {
+			log.Printf("Error marking weekly summary for user %d: %v", user.ID, err)
+			continue
+		}
+
+		text := locale.Tr(userLanguage(user), "weekly.summary", accepted, delayed, denied)
+
+		msg := tgbotapi.NewMessage(user.ID, text)
+		msg.ParseMode = "Markdown"
+
+		if _, err := b.send(msg); err != nil {
+			log.Printf("Error sending weekly summary to user %d: %v", user.ID, err)
+		}
+	}
+}
+
+// handleWeekly toggles the personal weekly summary
+func (b *Bot) handleWeekly(message *tgbotapi.Message) {
+	enabled, ok := parseToggle(b.commandArguments(message))
+	if !ok {
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "weekly.usage"))
+		return
+	}
+
+	if err := b.service.SetWeeklySummary(message.From.ID, enabled); err != nil {
+		log.Printf("Error updating weekly summary preference: %v", err)
+		b.sendMessage(message.Chat.ID, b.t(message.From.ID, "settings.save_failed"))
+		return
+	}
+
+	if enabled {
+		b.sendMessage(message.Chat.ID, b.reply(message.From.ID, b.t(message.From.ID, "weekly.on"), "✅"))
+	} else {
+		b.sendMessage(message.Chat.ID, b.reply(message.From.ID, b.t(message.From.ID, "weekly.off"), "✅"))
+	}
+}
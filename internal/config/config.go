@@ -1,24 +1,75 @@
 package config
 
 import (
+	"fmt"
 	"os"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/glebk/smoke-bot/internal/locale"
 	"github.com/joho/godotenv"
 )
 
 // Config holds application configuration
 type Config struct {
-	TelegramToken string
-	DatabasePath  string
-	WorkingHours  WorkingHours
+	TelegramToken     string
+	DatabasePath      string
+	CommandPrefix     string
+	Language          string
+	AdminIDs          []int64
+	RequireApproval   bool
+	GroupIntro        GroupIntro
+	ActionButtonText  string
+	Activities        map[string]Activity
+	NotifyDebounce    time.Duration
+	NoResponseNudge   time.Duration
+	HandleEdits       bool
+	DryRun            bool
+	WaitForInvitees   bool
+	ReconnectUpdates  bool
+	GroupInvitations  bool
+	InactivityTimeout time.Duration
+	SessionTimeout    time.Duration
+	StartCooldown     time.Duration
+	MaxInvitesPerDay  int
+	MinParticipants   int
+	ScheduledBreaks   []string
+	ScheduledChatID   int64
+	WeeklyDigest      string
+	HealthPort        string
+	MetricsPort       string
+	WorkingHours      WorkingHours
+}
+
+// Audiences of the weekly digest
+const (
+	DigestOff    = "off"
+	DigestAll    = "all"
+	DigestAdmins = "admins"
+)
+
+// GroupIntro configures the message sent when the bot is added to a group
+type GroupIntro struct {
+	Enabled bool
+	Text    string
+}
+
+// Activity replaces the activity the messages of a language talk about.
+// Forms lists the forms of locale.ActivityForms in the same order.
+type Activity struct {
+	Forms []string
+	Verb  string
 }
 
 // WorkingHours defines when the bot should operate
 type WorkingHours struct {
-	StartHour int
-	EndHour   int
-	Location  *time.Location
+	StartHour    int
+	EndHour      int
+	Location     *time.Location
+	WorkWeekends bool
+	Holidays     map[string]bool
 }
 
 // Load loads configuration from environment variables
@@ -26,10 +77,9 @@ func Load() (*Config, error) {
 	// Try to load .env file (ignore error if not exists)
 	_ = godotenv.Load()
 
-	token := os.Getenv("TELEGRAM_BOT_TOKEN")
+	token := strings.TrimSpace(os.Getenv("TELEGRAM_BOT_TOKEN"))
 	if token == "" {
-		// Hardcoded token as fallback
-		token = ""
+		return nil, fmt.Errorf("TELEGRAM_BOT_TOKEN is required")
 	}
 
 	dbPath := os.Getenv("DATABASE_PATH")
@@ -37,6 +87,237 @@ func Load() (*Config, error) {
 		dbPath = "./smoke_bot.db"
 	}
 
+	// Commands are always accepted with "/", the prefix adds an alternative
+	commandPrefix := os.Getenv("COMMAND_PREFIX")
+	if commandPrefix == "" {
+		commandPrefix = "/"
+	}
+
+	language, err := parseLanguage(os.Getenv("BOT_LANG"), os.Getenv("LANG"))
+	if err != nil {
+		return nil, err
+	}
+
+	activities, err := parseActivities(language)
+	if err != nil {
+		return nil, err
+	}
+
+	adminIDs, err := parseIDList(os.Getenv("ADMIN_IDS"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid ADMIN_IDS: %w", err)
+	}
+
+	requireApproval := false
+	if value := os.Getenv("REQUIRE_APPROVAL"); value != "" {
+		requireApproval, err = strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid REQUIRE_APPROVAL: %w", err)
+		}
+	}
+
+	groupIntroEnabled := true
+	if value := os.Getenv("GROUP_INTRO_ENABLED"); value != "" {
+		groupIntroEnabled, err = strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid GROUP_INTRO_ENABLED: %w", err)
+		}
+	}
+
+	// Response notifications are sent immediately unless a debounce window is set
+	var notifyDebounce time.Duration
+	if value := os.Getenv("NOTIFY_DEBOUNCE_SECONDS"); value != "" {
+		seconds, err := strconv.Atoi(value)
+		if err != nil || seconds < 0 {
+			return nil, fmt.Errorf("invalid NOTIFY_DEBOUNCE_SECONDS: %q", value)
+		}
+		notifyDebounce = time.Duration(seconds) * time.Second
+	}
+
+	// Initiators are nudged when nobody answered their break in this time
+	noResponseNudge := 5 * time.Minute
+	if value := os.Getenv("NO_RESPONSE_NUDGE_MINUTES"); value != "" {
+		minutes, err := strconv.Atoi(value)
+		if err != nil || minutes < 0 {
+			return nil, fmt.Errorf("invalid NO_RESPONSE_NUDGE_MINUTES: %q", value)
+		}
+		noResponseNudge = time.Duration(minutes) * time.Minute
+	}
+
+	handleEdits := false
+	if value := os.Getenv("HANDLE_EDITED_MESSAGES"); value != "" {
+		handleEdits, err = strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid HANDLE_EDITED_MESSAGES: %w", err)
+		}
+	}
+
+	// In dry-run mode outgoing messages are logged instead of sent
+	dryRun := false
+	if value := os.Getenv("DRY_RUN"); value != "" {
+		dryRun, err = strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DRY_RUN: %w", err)
+		}
+	}
+
+	// The bot stops when it loses Telegram and leaves restarting it to a
+	// supervisor, unless it should keep reconnecting by itself
+	reconnectUpdates := false
+	if value := os.Getenv("RECONNECT_UPDATES"); value != "" {
+		reconnectUpdates, err = strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RECONNECT_UPDATES: %w", err)
+		}
+	}
+
+	// Breaks nobody can be invited to are cancelled unless they should wait
+	// for someone to become available
+	waitForInvitees := false
+	if value := os.Getenv("WAIT_FOR_INVITEES"); value != "" {
+		waitForInvitees, err = strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid WAIT_FOR_INVITEES: %w", err)
+		}
+	}
+
+	// Breaks started in a group invite everyone by DM unless the invitation
+	// should be posted to the group itself
+	groupInvitations := false
+	if value := os.Getenv("GROUP_INVITATIONS"); value != "" {
+		groupInvitations, err = strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid GROUP_INVITATIONS: %w", err)
+		}
+	}
+
+	sessionTimeout := 15 * time.Minute
+	if value := os.Getenv("SESSION_TIMEOUT_MINUTES"); value != "" {
+		minutes, err := strconv.Atoi(value)
+		if err != nil || minutes <= 0 {
+			return nil, fmt.Errorf("invalid SESSION_TIMEOUT_MINUTES: %q", value)
+		}
+		sessionTimeout = time.Duration(minutes) * time.Minute
+	}
+
+	startCooldown := 2 * time.Minute
+	if value := os.Getenv("START_COOLDOWN_SECONDS"); value != "" {
+		seconds, err := strconv.Atoi(value)
+		if err != nil || seconds < 0 {
+			return nil, fmt.Errorf("invalid START_COOLDOWN_SECONDS: %q", value)
+		}
+		startCooldown = time.Duration(seconds) * time.Second
+	}
+
+	// Invitations are unlimited unless a daily cap is set
+	maxInvitesPerDay := 0
+	if value := os.Getenv("MAX_INVITES_PER_DAY"); value != "" {
+		maxInvitesPerDay, err = strconv.Atoi(value)
+		if err != nil || maxInvitesPerDay < 0 {
+			return nil, fmt.Errorf("invalid MAX_INVITES_PER_DAY: %q", value)
+		}
+	}
+
+	// Every break counts unless a minimum number of participants is set
+	minParticipants := 0
+	if value := os.Getenv("MIN_PARTICIPANTS"); value != "" {
+		minParticipants, err = strconv.Atoi(value)
+		if err != nil || minParticipants < 0 {
+			return nil, fmt.Errorf("invalid MIN_PARTICIPANTS: %q", value)
+		}
+	}
+
+	// Sessions only end by age unless an inactivity timeout is set
+	var inactivityTimeout time.Duration
+	if value := os.Getenv("INACTIVITY_TIMEOUT_MINUTES"); value != "" {
+		minutes, err := strconv.Atoi(value)
+		if err != nil || minutes < 0 {
+			return nil, fmt.Errorf("invalid INACTIVITY_TIMEOUT_MINUTES: %q", value)
+		}
+		inactivityTimeout = time.Duration(minutes) * time.Minute
+	}
+
+	startHour, err := parseHour("WORKING_HOURS_START", 9)
+	if err != nil {
+		return nil, err
+	}
+
+	endHour, err := parseHour("WORKING_HOURS_END", 23)
+	if err != nil {
+		return nil, err
+	}
+
+	if startHour >= endHour {
+		return nil, fmt.Errorf("invalid working hours: start %d must be before end %d", startHour, endHour)
+	}
+
+	workWeekends := false
+	if value := os.Getenv("WORK_WEEKENDS"); value != "" {
+		workWeekends, err = strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid WORK_WEEKENDS: %w", err)
+		}
+	}
+
+	// Non-working dates, as "YYYY-MM-DD" entries
+	holidays := make(map[string]bool)
+	for _, entry := range strings.Split(os.Getenv("HOLIDAYS"), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", entry); err != nil {
+			return nil, fmt.Errorf("invalid HOLIDAYS: %q is not a YYYY-MM-DD date", entry)
+		}
+		holidays[entry] = true
+	}
+
+	// Breaks the bot starts on its own, as "HH:MM" entries
+	var scheduledBreaks []string
+	for _, entry := range strings.Split(os.Getenv("SCHEDULED_BREAKS"), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if _, err := time.Parse("15:04", entry); err != nil {
+			return nil, fmt.Errorf("invalid SCHEDULED_BREAKS: %q is not a HH:MM time", entry)
+		}
+		scheduledBreaks = append(scheduledBreaks, entry)
+	}
+
+	var scheduledChatID int64
+	if value := os.Getenv("SCHEDULED_BREAKS_CHAT_ID"); value != "" {
+		scheduledChatID, err = strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SCHEDULED_BREAKS_CHAT_ID: %w", err)
+		}
+	}
+
+	weeklyDigest := strings.ToLower(os.Getenv("WEEKLY_DIGEST"))
+	switch weeklyDigest {
+	case "":
+		weeklyDigest = DigestOff
+	case DigestOff, DigestAll, DigestAdmins:
+	default:
+		return nil, fmt.Errorf("invalid WEEKLY_DIGEST: %q (use off, all or admins)", weeklyDigest)
+	}
+
+	// The health check endpoint is disabled unless a port is set
+	healthPort := os.Getenv("HEALTH_PORT")
+	if healthPort != "" {
+		if port, err := strconv.Atoi(healthPort); err != nil || port < 1 || port > 65535 {
+			return nil, fmt.Errorf("invalid HEALTH_PORT: %q", healthPort)
+		}
+	}
+
+	// Prometheus metrics are disabled unless a port is set
+	metricsPort := os.Getenv("METRICS_PORT")
+	if metricsPort != "" {
+		if port, err := strconv.Atoi(metricsPort); err != nil || port < 1 || port > 65535 {
+			return nil, fmt.Errorf("invalid METRICS_PORT: %q", metricsPort)
+		}
+	}
+
 	// Default to local timezone
 	loc, err := time.LoadLocation("Local")
 	if err != nil {
@@ -44,19 +325,212 @@ func Load() (*Config, error) {
 	}
 
 	return &Config{
-		TelegramToken: token,
-		DatabasePath:  dbPath,
+		TelegramToken:     token,
+		DatabasePath:      dbPath,
+		CommandPrefix:     commandPrefix,
+		Language:          language,
+		AdminIDs:          adminIDs,
+		RequireApproval:   requireApproval,
+		NotifyDebounce:    notifyDebounce,
+		NoResponseNudge:   noResponseNudge,
+		HandleEdits:       handleEdits,
+		DryRun:            dryRun,
+		WaitForInvitees:   waitForInvitees,
+		ReconnectUpdates:  reconnectUpdates,
+		GroupInvitations:  groupInvitations,
+		InactivityTimeout: inactivityTimeout,
+		SessionTimeout:    sessionTimeout,
+		StartCooldown:     startCooldown,
+		MaxInvitesPerDay:  maxInvitesPerDay,
+		MinParticipants:   minParticipants,
+		ScheduledBreaks:   scheduledBreaks,
+		ScheduledChatID:   scheduledChatID,
+		WeeklyDigest:      weeklyDigest,
+		HealthPort:        healthPort,
+		MetricsPort:       metricsPort,
+		ActionButtonText:  strings.TrimSpace(os.Getenv("ACTION_BUTTON_TEXT")),
+		Activities:        activities,
+		GroupIntro: GroupIntro{
+			Enabled: groupIntroEnabled,
+			Text:    os.Getenv("GROUP_INTRO_TEXT"),
+		},
 		WorkingHours: WorkingHours{
-			StartHour: 9,
-			EndHour:   23,
-			Location:  loc,
+			StartHour:    startHour,
+			EndHour:      endHour,
+			Location:     loc,
+			WorkWeekends: workWeekends,
+			Holidays:     holidays,
 		},
 	}, nil
 }
 
+// String formats the working hours as "09:00 - 23:00"
+func (wh WorkingHours) String() string {
+	return fmt.Sprintf("%02d:00 - %02d:00", wh.StartHour, wh.EndHour)
+}
+
+// IsWorkingDay reports whether t falls on a working day: not a listed
+// holiday, and not a weekend unless weekends are working days
+func (wh WorkingHours) IsWorkingDay(t time.Time) bool {
+	if wh.Holidays[t.Format("2006-01-02")] {
+		return false
+	}
+
+	if wh.WorkWeekends {
+		return true
+	}
+
+	return t.Weekday() != time.Saturday && t.Weekday() != time.Sunday
+}
+
+// NextWorkingTime returns the next time working hours start after now, in
+// now's location: later today if now is before the start, otherwise the
+// start of the next working day, skipping weekends and holidays
+func (wh WorkingHours) NextWorkingTime(now time.Time) time.Time {
+	next := time.Date(now.Year(), now.Month(), now.Day(), wh.StartHour, 0, 0, 0, now.Location())
+	if !now.Before(next) {
+		next = next.AddDate(0, 0, 1)
+	}
+
+	for !wh.IsWorkingDay(next) {
+		next = next.AddDate(0, 0, 1)
+	}
+
+	return next
+}
+
 // IsWorkingHours checks if current time is within working hours
 func (c *Config) IsWorkingHours() bool {
-	now := time.Now().In(c.WorkingHours.Location)
+	return c.isWorkingHoursIn(c.WorkingHours.Location)
+}
+
+// IsWorkingHoursFor checks working hours in the given IANA timezone, falling
+// back to the default location when it is empty or unknown
+func (c *Config) IsWorkingHoursFor(timezone string) bool {
+	if timezone == "" {
+		return c.IsWorkingHours()
+	}
+
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return c.IsWorkingHours()
+	}
+
+	return c.isWorkingHoursIn(loc)
+}
+
+// isWorkingHoursIn checks working hours for the current time in loc
+func (c *Config) isWorkingHoursIn(loc *time.Location) bool {
+	now := time.Now().In(loc)
+	if !c.WorkingHours.IsWorkingDay(now) {
+		return false
+	}
+
 	hour := now.Hour()
 	return hour >= c.WorkingHours.StartHour && hour < c.WorkingHours.EndHour
 }
+
+// IsAdmin checks if the user is listed in ADMIN_IDS
+func (c *Config) IsAdmin(userID int64) bool {
+	for _, id := range c.AdminIDs {
+		if id == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// parseHour reads an hour of the day (0-23) from an environment variable,
+// returning def when it is not set
+func parseHour(name string, def int) (int, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return def, nil
+	}
+
+	hour, err := strconv.Atoi(value)
+	if err != nil || hour < 0 || hour > 23 {
+		return 0, fmt.Errorf("invalid %s: %q is not an hour between 0 and 23", name, value)
+	}
+
+	return hour, nil
+}
+
+// parseLanguage reads the bot language from BOT_LANG, or from LANG when it
+// holds a bare language code such as "en". A system locale such as
+// en_US.UTF-8 in LANG describes the host rather than the bot, so it keeps
+// the default language instead of switching it or failing the start.
+func parseLanguage(botLang, systemLang string) (string, error) {
+	name, value := "BOT_LANG", strings.TrimSpace(botLang)
+	if value == "" {
+		name, value = "LANG", strings.TrimSpace(systemLang)
+		if value == "" || strings.ContainsAny(value, "_.@") ||
+			strings.EqualFold(value, "C") || strings.EqualFold(value, "POSIX") {
+			return locale.DefaultLanguage, nil
+		}
+	}
+
+	lang := strings.ToLower(value)
+	if !locale.IsSupported(lang) {
+		return "", fmt.Errorf("invalid %s: %q, supported languages are %s", name, value, strings.Join(locale.Supported(), ", "))
+	}
+
+	return lang, nil
+}
+
+// parseActivities reads the activity of each language from
+// ACTIVITY_NAME_<LANG>, a comma-separated list of its forms, and
+// ACTIVITY_VERB_<LANG>, e.g. ACTIVITY_NAME_EN=lunch,lunches,a lunch and
+// ACTIVITY_VERB_EN=eat. ACTIVITY_NAME and ACTIVITY_VERB without a suffix
+// apply to the bot language.
+func parseActivities(language string) (map[string]Activity, error) {
+	activities := make(map[string]Activity)
+	for _, lang := range locale.Supported() {
+		nameVar, verbVar := "ACTIVITY_NAME_"+strings.ToUpper(lang), "ACTIVITY_VERB_"+strings.ToUpper(lang)
+		name, verb := os.Getenv(nameVar), os.Getenv(verbVar)
+		if lang == language {
+			if name == "" {
+				nameVar, name = "ACTIVITY_NAME", os.Getenv("ACTIVITY_NAME")
+			}
+			if verb == "" {
+				verb = os.Getenv("ACTIVITY_VERB")
+			}
+		}
+
+		activity := Activity{Verb: strings.TrimSpace(verb)}
+		if strings.TrimSpace(name) != "" {
+			for _, form := range strings.Split(name, ",") {
+				activity.Forms = append(activity.Forms, strings.TrimSpace(form))
+			}
+
+			forms := locale.ActivityForms(lang)
+			if len(activity.Forms) != len(forms) || slices.Contains(activity.Forms, "") {
+				return nil, fmt.Errorf("invalid %s: %q, expected %d comma-separated forms: %s",
+					nameVar, name, len(forms), strings.Join(forms, ", "))
+			}
+		}
+
+		if activity.Forms != nil || activity.Verb != "" {
+			activities[lang] = activity
+		}
+	}
+	return activities, nil
+}
+
+// parseIDList parses a comma-separated list of Telegram IDs
+func parseIDList(value string) ([]int64, error) {
+	var ids []int64
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		id, err := strconv.ParseInt(part, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a valid ID", part)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
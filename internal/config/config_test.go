@@ -0,0 +1,186 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadRequiresToken(t *testing.T) {
+	for _, token := range []string{"", "   "} {
+		t.Run(strings.ReplaceAll(token, " ", "space"), func(t *testing.T) {
+			t.Setenv("TELEGRAM_BOT_TOKEN", token)
+
+			cfg, err := Load()
+			if err == nil {
+				t.Fatal("Load succeeded without a token")
+			}
+			if !strings.Contains(err.Error(), "TELEGRAM_BOT_TOKEN is required") {
+				t.Errorf("Load error = %q, want it to name TELEGRAM_BOT_TOKEN", err)
+			}
+			if cfg != nil {
+				t.Errorf("Load returned a config along with the error")
+			}
+		})
+	}
+}
+
+func TestLoadAcceptsToken(t *testing.T) {
+	t.Setenv("TELEGRAM_BOT_TOKEN", " 123456:secret ")
+	t.Setenv("BOT_LANG", "")
+	t.Setenv("LANG", "")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.TelegramToken != "123456:secret" {
+		t.Errorf("token = %q, want it trimmed", cfg.TelegramToken)
+	}
+}
+
+func TestIsWorkingDay(t *testing.T) {
+	holidays := map[string]bool{"2024-03-08": true} // a Friday
+
+	tests := []struct {
+		name         string
+		day          time.Time
+		workWeekends bool
+		want         bool
+	}{
+		{"tuesday", time.Date(2024, 3, 5, 12, 0, 0, 0, time.UTC), false, true},
+		{"saturday", time.Date(2024, 3, 9, 12, 0, 0, 0, time.UTC), false, false},
+		{"sunday", time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC), false, false},
+		{"saturday with working weekends", time.Date(2024, 3, 9, 12, 0, 0, 0, time.UTC), true, true},
+		{"holiday", time.Date(2024, 3, 8, 12, 0, 0, 0, time.UTC), false, false},
+		{"holiday with working weekends", time.Date(2024, 3, 8, 12, 0, 0, 0, time.UTC), true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wh := WorkingHours{StartHour: 9, EndHour: 18, WorkWeekends: tt.workWeekends, Holidays: holidays}
+			if got := wh.IsWorkingDay(tt.day); got != tt.want {
+				t.Errorf("IsWorkingDay(%s) = %v, want %v", tt.day.Format("Mon 2006-01-02"), got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNextWorkingTime(t *testing.T) {
+	msk := time.FixedZone("MSK", 3*60*60)
+	wh := WorkingHours{
+		StartHour: 9,
+		EndHour:   18,
+		Location:  msk,
+		Holidays:  map[string]bool{"2024-03-08": true}, // a Friday
+	}
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2024, 3, day, hour, minute, 0, 0, msk)
+	}
+
+	tests := []struct {
+		name string
+		now  time.Time
+		want time.Time
+	}{
+		{"before start", at(5, 7, 30), at(5, 9, 0)},
+		{"exactly at start", at(5, 9, 0), at(6, 9, 0)},
+		{"after hours", at(5, 19, 0), at(6, 9, 0)},
+		{"friday evening", at(1, 18, 30), at(4, 9, 0)},
+		{"saturday", at(2, 12, 0), at(4, 9, 0)},
+		{"sunday before start", at(3, 7, 0), at(4, 9, 0)},
+		{"before a holiday", at(7, 19, 0), at(11, 9, 0)},
+		{"holiday morning", at(8, 7, 0), at(11, 9, 0)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := wh.NextWorkingTime(tt.now)
+			if !got.Equal(tt.want) {
+				t.Errorf("NextWorkingTime(%s) = %s, want %s", tt.now.Format(time.DateTime), got.Format(time.DateTime), tt.want.Format(time.DateTime))
+			}
+			if got.Location() != tt.now.Location() {
+				t.Errorf("NextWorkingTime returned %s, want now's location", got.Location())
+			}
+		})
+	}
+}
+
+func TestParseLanguage(t *testing.T) {
+	tests := []struct {
+		name       string
+		botLang    string
+		systemLang string
+		want       string
+		wantErr    bool
+	}{
+		{"unset", "", "", "ru", false},
+		{"BOT_LANG", "en", "", "en", false},
+		{"BOT_LANG over LANG", "ru", "en", "ru", false},
+		{"BOT_LANG case", " EN ", "", "en", false},
+		{"unsupported BOT_LANG", "de", "", "", true},
+		{"LANG code", "", "en", "en", false},
+		{"unsupported LANG code", "", "de", "", true},
+		{"supported system locale", "", "en_US.UTF-8", "ru", false},
+		{"unsupported system locale", "", "de_DE.UTF-8", "ru", false},
+		{"C locale", "", "C", "ru", false},
+		{"C.UTF-8 locale", "", "C.UTF-8", "ru", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseLanguage(tt.botLang, tt.systemLang)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseLanguage(%q, %q) error = %v, wantErr %v", tt.botLang, tt.systemLang, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseLanguage(%q, %q) = %q, want %q", tt.botLang, tt.systemLang, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseActivities(t *testing.T) {
+	t.Setenv("ACTIVITY_NAME", "обед, обеда, обеде, обеды, обедов, обедами, обедах")
+	t.Setenv("ACTIVITY_VERB", "обедать")
+	t.Setenv("ACTIVITY_NAME_EN", "")
+	t.Setenv("ACTIVITY_VERB_EN", "eat")
+
+	activities, err := parseActivities("ru")
+	if err != nil {
+		t.Fatalf("parseActivities: %v", err)
+	}
+
+	ru := activities["ru"]
+	if len(ru.Forms) != 7 || ru.Forms[0] != "обед" || ru.Forms[6] != "обедах" || ru.Verb != "обедать" {
+		t.Errorf("ru activity = %+v, want the trimmed forms and the verb", ru)
+	}
+	if en := activities["en"]; en.Forms != nil || en.Verb != "eat" {
+		t.Errorf("en activity = %+v, want only the verb", en)
+	}
+}
+
+func TestParseActivitiesPrefersLanguageVariables(t *testing.T) {
+	t.Setenv("ACTIVITY_NAME", "coffee,coffees,a coffee")
+	t.Setenv("ACTIVITY_NAME_EN", "lunch,lunches,a lunch")
+
+	activities, err := parseActivities("en")
+	if err != nil {
+		t.Fatalf("parseActivities: %v", err)
+	}
+	if forms := activities["en"].Forms; len(forms) != 3 || forms[0] != "lunch" {
+		t.Errorf("en forms = %q, want the ones of ACTIVITY_NAME_EN", forms)
+	}
+}
+
+func TestParseActivitiesRejectsWrongForms(t *testing.T) {
+	for _, value := range []string{"lunch", "lunch,lunches", "lunch,,a lunch"} {
+		t.Run(value, func(t *testing.T) {
+			t.Setenv("ACTIVITY_NAME_EN", value)
+
+			if _, err := parseActivities("ru"); err == nil {
+				t.Errorf("parseActivities accepted ACTIVITY_NAME_EN=%q", value)
+			}
+		})
+	}
+}
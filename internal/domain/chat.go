@@ -0,0 +1,30 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// Chat represents a Telegram chat the bot has received updates from
+type Chat struct {
+	ID          int64
+	Title       string
+	Type        string
+	PlainNames  bool
+	FirstSeenAt time.Time
+	LastSeenAt  time.Time
+}
+
+// IsGroupChat reports whether a chat ID belongs to a group. Telegram gives
+// private chats the user's ID and groups negative IDs.
+func IsGroupChat(chatID int64) bool {
+	return chatID < 0
+}
+
+// ChatRepository defines the interface for chat storage
+type ChatRepository interface {
+	Upsert(ctx context.Context, chat *Chat) error
+	GetByID(ctx context.Context, id int64) (*Chat, error)
+	GetAll(ctx context.Context) ([]*Chat, error)
+	SetPlainNames(ctx context.Context, chatID int64, plain bool) error
+}
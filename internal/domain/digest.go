@@ -0,0 +1,14 @@
+package domain
+
+import "time"
+
+// Digest summarises the sessions of one week
+type Digest struct {
+	WeekStart         time.Time
+	TotalBreaks       int
+	BusiestDay        time.Weekday
+	BusiestDayBreaks  int
+	TopInitiatorID    int64
+	TopInitiatorCount int
+	AverageAttendees  float64
+}
@@ -1,6 +1,11 @@
 package domain
 
-import "time"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
 
 // SessionStatus represents the current status of a smoking session
 type SessionStatus string
@@ -11,23 +16,131 @@ const (
 	SessionStatusCancelled SessionStatus = "cancelled"
 )
 
+// ErrInvalidTransition is returned when a session can't move to the requested status
+var ErrInvalidTransition = errors.New("invalid session status transition")
+
+// ErrActiveSessionExists is returned when a chat already has an active
+// session, as each chat may only run one at a time
+var ErrActiveSessionExists = errors.New("there is already an active smoking session")
+
+// sessionTransitions lists, for each status, the statuses a session may move to.
+// Statuses missing from the map are final. A completed session may be
+// reopened when the initiator extends it.
+var sessionTransitions = map[SessionStatus][]SessionStatus{
+	SessionStatusActive:    {SessionStatusCompleted, SessionStatusCancelled},
+	SessionStatusCompleted: {SessionStatusActive},
+}
+
+// CanTransition reports whether a session may move from one status to another
+func CanTransition(from, to SessionStatus) bool {
+	for _, allowed := range sessionTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
 // ResponseType represents how a user responded to a smoking invitation
 type ResponseType string
 
 const (
 	ResponseAccepted       ResponseType = "accepted"
 	ResponseAcceptedDelayed ResponseType = "accepted_delayed"
+	ResponseMaybe          ResponseType = "maybe"
 	ResponseDenied         ResponseType = "denied"
 	ResponseRemote         ResponseType = "remote"
+	ResponseVoted          ResponseType = "voted" // picked one of the options of a vote
 )
 
 // Session represents a smoking session
 type Session struct {
-	ID          int64
-	InitiatorID int64
-	Status      SessionStatus
-	CreatedAt   time.Time
-	CompletedAt *time.Time
+	ID              int64
+	InitiatorID     int64
+	ChatID          int64
+	TimeoutMinutes  int
+	Note            string
+	StatusChatID    int64
+	StatusMessageID int
+	Shared          bool // the status message is the invitation posted to a group chat
+	Latitude        *float64
+	Longitude       *float64
+	Status          SessionStatus
+	Failed          bool   // completed with fewer participants than required
+	CancelReason    string // why the initiator cancelled the session, if they said
+	Options         []string // what a vote chooses between; empty for an ordinary break
+	CreatedAt       time.Time
+	CompletedAt     *time.Time
+}
+
+// Happened reports whether the break took place, that is it was neither
+// cancelled nor completed with too few participants
+func (s *Session) Happened() bool {
+	return s.Status != SessionStatusCancelled && !s.Failed
+}
+
+// IsVote reports whether the session asks to choose between options
+// instead of inviting to a break
+func (s *Session) IsVote() bool {
+	return len(s.Options) > 0
+}
+
+// TallyVotes counts the votes for each option, in the order of Options
+func (s *Session) TallyVotes(responses []*SessionResponse) []int {
+	counts := make([]int, len(s.Options))
+	for _, resp := range responses {
+		if resp.Response != ResponseVoted {
+			continue
+		}
+		for i, option := range s.Options {
+			if resp.Option == option {
+				counts[i]++
+				break
+			}
+		}
+	}
+	return counts
+}
+
+// Timeout returns how long the session stays open
+func (s *Session) Timeout() time.Duration {
+	return time.Duration(s.TimeoutMinutes) * time.Minute
+}
+
+// Duration returns how long the session lasted, or has lasted so far if it
+// is still active
+func (s *Session) Duration() time.Duration {
+	end := time.Now()
+	if s.CompletedAt != nil {
+		end = *s.CompletedAt
+	}
+
+	return end.Sub(s.CreatedAt)
+}
+
+// HasLocation reports whether the initiator shared where the break takes place
+func (s *Session) HasLocation() bool {
+	return s.Latitude != nil && s.Longitude != nil
+}
+
+// Transition moves the session to a new status after validating the change.
+// Ending the session records the completion time, reopening it clears it.
+func (s *Session) Transition(to SessionStatus) error {
+	if !CanTransition(s.Status, to) {
+		return fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, s.Status, to)
+	}
+
+	s.Status = to
+
+	if to == SessionStatusActive {
+		s.CompletedAt = nil
+		s.Failed = false
+	} else {
+		now := time.Now()
+		s.CompletedAt = &now
+	}
+
+	return nil
 }
 
 // SessionResponse represents a user's response to a session
@@ -36,21 +149,74 @@ type SessionResponse struct {
 	SessionID  int64
 	UserID     int64
 	Response   ResponseType
+	Option     string // the option voted for, if Response is ResponseVoted
+	Attended   *bool  // nil until the user confirms whether they came
 	CreatedAt  time.Time
 }
 
+// IsAcceptance reports whether the response means the user said they'd come
+func (r *SessionResponse) IsAcceptance() bool {
+	return r.Response == ResponseAccepted || r.Response == ResponseAcceptedDelayed
+}
+
+// IsAttendance reports whether the user accepted and later confirmed they
+// came. Stats, streaks and the digest count only these.
+func (r *SessionResponse) IsAttendance() bool {
+	return r.IsAcceptance() && r.Attended != nil && *r.Attended
+}
+
+// LeaderboardEntry is a user's position in a ranking
+type LeaderboardEntry struct {
+	UserID int64
+	Count  int
+}
+
+// SessionHistoryEntry is a past session with the number of people who came
+type SessionHistoryEntry struct {
+	Session   *Session
+	Attendees int
+}
+
 // SessionRepository defines the interface for session storage
 type SessionRepository interface {
-	Create(session *Session) error
-	GetByID(id int64) (*Session, error)
-	GetActiveSession() (*Session, error)
-	Update(session *Session) error
-	CompleteSession(sessionID int64) error
+	Create(ctx context.Context, session *Session) error
+	// CreateWithResponse creates a session together with a first response,
+	// such as the initiator's, so neither is stored without the other
+	CreateWithResponse(ctx context.Context, session *Session, response *SessionResponse) error
+	GetByID(ctx context.Context, id int64) (*Session, error)
+	GetActiveSessionByChat(ctx context.Context, chatID int64) (*Session, error)
+	// GetActivePrivateSession returns the active session started in any
+	// private chat, as those all invite the same colleagues
+	GetActivePrivateSession(ctx context.Context) (*Session, error)
+	GetLatestSessionByChat(ctx context.Context, chatID int64) (*Session, error)
+	GetLatestSessionByInitiator(ctx context.Context, initiatorID int64) (*Session, error)
+	GetAllActiveSessions(ctx context.Context) ([]*Session, error)
+	GetSessionsForUser(ctx context.Context, userID int64, limit int) ([]*Session, error)
+	GetSessionsBetween(ctx context.Context, from, to time.Time) ([]*Session, error)
+	Update(ctx context.Context, session *Session) error
+	SetStatusMessage(ctx context.Context, sessionID int64, chatID int64, messageID int, shared bool) error
+	SetLocation(ctx context.Context, sessionID int64, latitude, longitude float64) error
+	GetInitiatorCounts(ctx context.Context, since time.Time, limit int) ([]LeaderboardEntry, error)
+	GetAcceptedCounts(ctx context.Context, since time.Time, limit int) ([]LeaderboardEntry, error)
+	GetAcceptedSessionTimes(ctx context.Context, userID int64) ([]time.Time, error)
 	
 	// Response methods
-	AddResponse(response *SessionResponse) error
-	GetResponses(sessionID int64) ([]*SessionResponse, error)
-	GetUserResponse(sessionID int64, userID int64) (*SessionResponse, error)
-	UpdateResponse(response *SessionResponse) error
+	AddResponse(ctx context.Context, response *SessionResponse) error
+	GetResponses(ctx context.Context, sessionID int64) ([]*SessionResponse, error)
+	GetUserResponse(ctx context.Context, sessionID int64, userID int64) (*SessionResponse, error)
+	UpdateResponse(ctx context.Context, response *SessionResponse) error
+	CountUserResponses(ctx context.Context, userID int64, since time.Time) (map[ResponseType]int, error)
+	CountAttendance(ctx context.Context, userID int64, since time.Time) (attended, unconfirmed int, err error)
+	SetAttendance(ctx context.Context, sessionID int64, userID int64, attended bool) error
+	GetLastResponseTime(ctx context.Context, sessionID int64) (*time.Time, error)
+	// Invitation methods
+	AddInvitation(ctx context.Context, sessionID int64, userID int64) error
+	IsInvited(ctx context.Context, sessionID int64, userID int64) (bool, error)
+	CountInvitations(ctx context.Context, userID int64, since time.Time) (int, error)
+	CountAnsweredInvitations(ctx context.Context, userID int64, since time.Time) (int, error)
+	MarkPoked(ctx context.Context, sessionID int64, userID int64) (bool, error)
+
+	GetDueDelayedResponses(ctx context.Context, respondedBefore time.Time) ([]*SessionResponse, error)
+	MarkReminderSent(ctx context.Context, responseID int64) error
 }
 
@@ -0,0 +1,76 @@
+package domain
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTransitionRejectsInvalidChanges(t *testing.T) {
+	tests := []struct {
+		from SessionStatus
+		to   SessionStatus
+	}{
+		{SessionStatusActive, SessionStatusActive},
+		{SessionStatusCompleted, SessionStatusCompleted},
+		{SessionStatusCompleted, SessionStatusCancelled},
+		{SessionStatusCancelled, SessionStatusActive},
+		{SessionStatusCancelled, SessionStatusCompleted},
+		{SessionStatusCancelled, SessionStatusCancelled},
+		{SessionStatusActive, "unknown"},
+		{"unknown", SessionStatusActive},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.from)+"->"+string(tt.to), func(t *testing.T) {
+			completedAt := time.Date(2024, 3, 5, 12, 0, 0, 0, time.UTC)
+			session := &Session{Status: tt.from, CompletedAt: &completedAt}
+
+			err := session.Transition(tt.to)
+			if !errors.Is(err, ErrInvalidTransition) {
+				t.Fatalf("Transition(%s -> %s) error = %v, want ErrInvalidTransition", tt.from, tt.to, err)
+			}
+			if session.Status != tt.from {
+				t.Errorf("status = %s after a rejected transition, want %s", session.Status, tt.from)
+			}
+			if session.CompletedAt != &completedAt {
+				t.Errorf("completion time changed after a rejected transition")
+			}
+		})
+	}
+}
+
+func TestTransitionAllowsValidChanges(t *testing.T) {
+	tests := []struct {
+		from          SessionStatus
+		to            SessionStatus
+		wantCompleted bool
+	}{
+		{SessionStatusActive, SessionStatusCompleted, true},
+		{SessionStatusActive, SessionStatusCancelled, true},
+		{SessionStatusCompleted, SessionStatusActive, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.from)+"->"+string(tt.to), func(t *testing.T) {
+			session := &Session{Status: tt.from, Failed: true}
+			if tt.from != SessionStatusActive {
+				completedAt := time.Now()
+				session.CompletedAt = &completedAt
+			}
+
+			if err := session.Transition(tt.to); err != nil {
+				t.Fatalf("Transition(%s -> %s): %v", tt.from, tt.to, err)
+			}
+			if session.Status != tt.to {
+				t.Errorf("status = %s, want %s", session.Status, tt.to)
+			}
+			if got := session.CompletedAt != nil; got != tt.wantCompleted {
+				t.Errorf("completion time set = %v, want %v", got, tt.wantCompleted)
+			}
+			if tt.to == SessionStatusActive && session.Failed {
+				t.Errorf("reopened session is still marked failed")
+			}
+		})
+	}
+}
@@ -0,0 +1,11 @@
+package domain
+
+import "context"
+
+// StateRepository defines the interface for storing small pieces of bot
+// state, such as the Telegram update offset, that must survive restarts
+type StateRepository interface {
+	// Get returns the value stored under key, or an empty string if none
+	Get(ctx context.Context, key string) (string, error)
+	Set(ctx context.Context, key, value string) error
+}
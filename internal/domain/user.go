@@ -1,27 +1,111 @@
 package domain
 
-import "time"
+import (
+	"context"
+	"time"
+)
+
+// ApprovalStatus tracks whether a user may start sessions when the bot
+// requires admin approval for new initiators
+type ApprovalStatus string
+
+const (
+	ApprovalNone     ApprovalStatus = ""
+	ApprovalPending  ApprovalStatus = "pending"
+	ApprovalApproved ApprovalStatus = "approved"
+	ApprovalRejected ApprovalStatus = "rejected"
+)
 
 // User represents a bot user
 type User struct {
-	ID            int64
-	Username      string
-	FirstName     string
-	LastName      string
-	IsRemoteToday bool
-	RemoteUntil   *time.Time
-	IsHidden      bool
-	CreatedAt     time.Time
-	UpdatedAt     time.Time
+	ID                int64
+	Username          string
+	FirstName         string
+	LastName          string
+	IsRemoteToday     bool
+	RemoteUntil       *time.Time
+	IsHidden          bool
+	SkipOwnSummary    bool
+	Approval          ApprovalStatus
+	WeeklySummary     bool
+	WeeklySummaryWeek string
+	TerseReplies      bool
+	Timezone          string
+	DelayMinutes      int
+	IsMuted           bool
+	IsUnreachable     bool
+	DigestWeek        string
+	QuietFrom         int
+	QuietTo           int
+	Language          string
+	DisplayName       string
+	IsDeleted         bool
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+}
+
+// Display returns the name shown for the user in messages: the nickname
+// they chose, otherwise their username or, lacking one, their first name
+func (u *User) Display() string {
+	if u.DisplayName != "" {
+		return u.DisplayName
+	}
+	if u.Username != "" {
+		return u.Username
+	}
+	return u.FirstName
+}
+
+// Delay returns how long the user takes to join after a delayed response
+func (u *User) Delay() time.Duration {
+	return time.Duration(u.DelayMinutes) * time.Minute
+}
+
+// HasQuietHours reports whether the user set a daily quiet window
+func (u *User) HasQuietHours() bool {
+	return u.QuietFrom != u.QuietTo
+}
+
+// InQuietHours reports whether t falls into the user's quiet window, taken in
+// the user's timezone. QuietFrom and QuietTo are minutes since midnight, and
+// the window may wrap past midnight.
+func (u *User) InQuietHours(t time.Time) bool {
+	if !u.HasQuietHours() {
+		return false
+	}
+
+	if u.Timezone != "" {
+		if loc, err := time.LoadLocation(u.Timezone); err == nil {
+			t = t.In(loc)
+		}
+	}
+
+	minute := t.Hour()*60 + t.Minute()
+	if u.QuietFrom < u.QuietTo {
+		return minute >= u.QuietFrom && minute < u.QuietTo
+	}
+	return minute >= u.QuietFrom || minute < u.QuietTo
 }
 
 // UserRepository defines the interface for user storage
 type UserRepository interface {
-	Create(user *User) error
-	GetByID(id int64) (*User, error)
-	GetAll() ([]*User, error)
-	Update(user *User) error
-	Delete(id int64) error
-	SetRemoteStatus(userID int64, until time.Time) error
-	ClearExpiredRemoteStatus() error
+	Create(ctx context.Context, user *User) error
+	GetByID(ctx context.Context, id int64) (*User, error)
+	GetByUsername(ctx context.Context, username string) (*User, error)
+	// ReleaseUsername clears a username, ignoring case, from every user but
+	// exceptID, as Telegram lets a freed username be taken by someone else
+	ReleaseUsername(ctx context.Context, username string, exceptID int64) error
+	GetAll(ctx context.Context) ([]*User, error)
+	Update(ctx context.Context, user *User) error
+	Delete(ctx context.Context, id int64) error
+	Restore(ctx context.Context, id int64) error
+	SetRemoteStatus(ctx context.Context, userID int64, until time.Time) error
+	ClearExpiredRemoteStatus(ctx context.Context) error
+	ClearAllRemoteStatus(ctx context.Context) (int64, error)
+	SetTimezone(ctx context.Context, userID int64, tz string) error
+	// SetWeeklySummaryWeek records the last week a user got their personal
+	// weekly summary for, leaving the rest of the user as it is
+	SetWeeklySummaryWeek(ctx context.Context, userID int64, week string) error
+	// SetDigestWeek records the last week a user got the team digest for
+	SetDigestWeek(ctx context.Context, userID int64, week string) error
 }
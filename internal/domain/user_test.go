@@ -0,0 +1,23 @@
+package domain
+
+import "testing"
+
+func TestUserDisplay(t *testing.T) {
+	tests := []struct {
+		name string
+		user User
+		want string
+	}{
+		{"nickname", User{DisplayName: "Кеша", Username: "inn", FirstName: "Иннокентий"}, "Кеша"},
+		{"username", User{Username: "inn", FirstName: "Иннокентий"}, "inn"},
+		{"empty username", User{FirstName: "Иннокентий"}, "Иннокентий"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.user.Display(); got != tt.want {
+				t.Errorf("Display() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+// Package health serves the liveness and readiness probe used by deployments.
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// checkTimeout bounds how long a single dependency check may take
+const checkTimeout = 5 * time.Second
+
+// Check reports an error when a dependency is unhealthy
+type Check func(ctx context.Context) error
+
+// Server serves /healthz, answering 200 when every check passes and 503
+// otherwise
+type Server struct {
+	server *http.Server
+	checks map[string]Check
+}
+
+// NewServer creates a health server listening on the given port
+func NewServer(port string, checks map[string]Check) *Server {
+	s := &Server{checks: checks}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", s.handleHealthz)
+
+	s.server = &http.Server{
+		Addr:              ":" + port,
+		Handler:           mux,
+		ReadHeaderTimeout: checkTimeout,
+	}
+
+	return s
+}
+
+// Run serves requests until ctx is cancelled, then shuts the server down
+func (s *Server) Run(ctx context.Context) error {
+	errs := make(chan error, 1)
+	go func() {
+		errs <- s.server.ListenAndServe()
+	}()
+
+	log.Printf("Health check listening on %s/healthz", s.server.Addr)
+
+	select {
+	case err := <-errs:
+		return fmt.Errorf("health server failed: %w", err)
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), checkTimeout)
+	defer cancel()
+
+	if err := s.server.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("failed to shut down health server: %w", err)
+	}
+
+	if err := <-errs; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("health server failed: %w", err)
+	}
+
+	return nil
+}
+
+// handleHealthz runs every check and reports the failing ones
+func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), checkTimeout)
+	defer cancel()
+
+	names := make([]string, 0, len(s.checks))
+	for name := range s.checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var failures []string
+	for _, name := range names {
+		if err := s.checks[name](ctx); err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", name, err))
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+
+	if len(failures) > 0 {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintln(w, strings.Join(failures, "\n"))
+		return
+	}
+
+	fmt.Fprintln(w, "ok")
+}
@@ -0,0 +1,109 @@
+package locale
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// activityForms names the forms of the activity noun each catalog uses, in
+// the order SetActivity takes them. Templates refer to a form as {name}, or
+// as {Name} to capitalize it, and to the verb as {verb}.
+var activityForms = map[string][]string{
+	// Nominative, genitive and prepositional singular, then nominative,
+	// genitive, instrumental and prepositional plural
+	"ru": {"activity", "activity_gen", "activity_prep", "activities", "activities_gen", "activities_ins", "activities_prep"},
+	// Singular, plural and singular with its article
+	"en": {"activity", "activities", "a_activity"},
+}
+
+// defaultActivity is the activity each catalog is written for
+var defaultActivity = map[string]struct {
+	forms []string
+	verb  string
+}{
+	"ru": {[]string{"перекур", "перекура", "перекуре", "перекуры", "перекуров", "перекурами", "перекурах"}, "курить"},
+	"en": {[]string{"break", "breaks", "a break"}, "smoke"},
+}
+
+var (
+	// vocabularies fill the placeholders of each language with the
+	// configured activity
+	vocabularies = defaultVocabularies()
+	// originalVocabularies fill them with the default one
+	originalVocabularies = defaultVocabularies()
+)
+
+// defaultVocabularies builds the placeholder replacers for the default
+// activity of every language
+func defaultVocabularies() map[string]*strings.Replacer {
+	replacers := make(map[string]*strings.Replacer)
+	for lang, activity := range defaultActivity {
+		replacers[lang] = newVocabulary(activityForms[lang], activity.forms, activity.verb)
+	}
+	return replacers
+}
+
+// newVocabulary builds a replacer of the placeholders named by names with
+// the matching forms and the verb
+func newVocabulary(names, forms []string, verb string) *strings.Replacer {
+	pairs := []string{"{verb}", verb, "{Verb}", capitalize(verb)}
+	for i, name := range names {
+		pairs = append(pairs,
+			"{"+name+"}", forms[i],
+			"{"+capitalize(name)+"}", capitalize(forms[i]))
+	}
+	return strings.NewReplacer(pairs...)
+}
+
+// ActivityForms returns the names of the activity forms SetActivity takes
+// for lang, in order. It is nil for unsupported languages.
+func ActivityForms(lang string) []string {
+	return activityForms[lang]
+}
+
+// SetActivity makes the messages in lang talk about another activity than
+// the default one ("перекур" in Russian, "break" in English). forms lists
+// every form of ActivityForms(lang), e.g. "обед", "обеда", "обеде", ... for
+// lunch in Russian; verb replaces the default verb ("курить", "smoke").
+// Empty values keep the defaults. The Russian messages agree with a
+// masculine noun.
+func SetActivity(lang string, forms []string, verb string) error {
+	defaults, ok := defaultActivity[lang]
+	if !ok {
+		return fmt.Errorf("unsupported language %q", lang)
+	}
+
+	if len(forms) == 0 {
+		forms = defaults.forms
+	}
+	if names := activityForms[lang]; len(forms) != len(names) {
+		return fmt.Errorf("%s activity needs %d forms (%s), got %d",
+			lang, len(names), strings.Join(names, ", "), len(forms))
+	}
+	if verb == "" {
+		verb = defaults.verb
+	}
+
+	vocabularies[lang] = newVocabulary(activityForms[lang], forms, verb)
+	return nil
+}
+
+// fillActivity replaces the activity placeholders of a lang template with
+// the words of one of the vocabularies
+func fillActivity(from map[string]*strings.Replacer, lang, template string) string {
+	if vocabulary, ok := from[lang]; ok {
+		return vocabulary.Replace(template)
+	}
+	return template
+}
+
+// capitalize upper-cases the first letter of s
+func capitalize(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	if r == utf8.RuneError {
+		return s
+	}
+	return string(unicode.ToUpper(r)) + s[size:]
+}
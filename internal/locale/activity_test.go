@@ -0,0 +1,105 @@
+package locale
+
+import (
+	"strings"
+	"testing"
+)
+
+// setActivity changes the activity of lang for the rest of the test
+func setActivity(t *testing.T, lang string, forms []string, verb string) {
+	t.Helper()
+
+	if err := SetActivity(lang, forms, verb); err != nil {
+		t.Fatalf("SetActivity(%s): %v", lang, err)
+	}
+	t.Cleanup(func() { SetActivity(lang, nil, "") })
+}
+
+func TestDefaultActivity(t *testing.T) {
+	tests := []struct {
+		lang string
+		key  string
+		want string
+	}{
+		{"ru", "keyboard.smoke", "🚬 Го курить!"},
+		{"ru", "cancel.notify", "❌ Перекур был отменён инициатором"},
+		{"en", "keyboard.smoke", "🚬 Let's go smoke!"},
+		{"en", "smoke.cooldown", "⏳ You just called a break. Wait %d seconds"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.lang+"/"+tt.key, func(t *testing.T) {
+			if got := Tr(tt.lang, tt.key); got != tt.want {
+				t.Errorf("Tr(%s, %s) = %q, want %q", tt.lang, tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetActivity(t *testing.T) {
+	setActivity(t, "ru", []string{"обед", "обеда", "обеде", "обеды", "обедов", "обедами", "обедах"}, "обедать")
+	setActivity(t, "en", []string{"lunch", "lunches", "a lunch"}, "eat")
+
+	tests := []struct {
+		lang string
+		key  string
+		args []any
+		want string
+	}{
+		{"ru", "keyboard.smoke", nil, "🚬 Го обедать!"},
+		{"ru", "cancel.notify", nil, "❌ Обед был отменён инициатором"},
+		{"ru", "smoke.invitation", []any{"@перекурщик"}, "🚬 @перекурщик приглашает вас на обед!\n\nГо обедать?"},
+		{"en", "keyboard.smoke", nil, "🚬 Let's go eat!"},
+		{"en", "smoke.cooldown", []any{30}, "⏳ You just called a lunch. Wait 30 seconds"},
+		{"en", "smoke.outside_hours", []any{"09:00", "1h", "9-18"}, "⏰ Sorry, it's not lunch time right now. Lunches start again at 09:00 (in 1h), working hours are 9-18."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.lang+"/"+tt.key, func(t *testing.T) {
+			if got := Tr(tt.lang, tt.key, tt.args...); got != tt.want {
+				t.Errorf("Tr(%s, %s) = %q, want %q", tt.lang, tt.key, got, tt.want)
+			}
+		})
+	}
+
+	t.Run("commands", func(t *testing.T) {
+		if help := Tr("en", "help.text", "9-18"); !strings.Contains(help, "/smoke - Invite colleagues for a lunch") {
+			t.Errorf("help doesn't keep /smoke: %q", help)
+		}
+	})
+}
+
+func TestSetActivityRejectsWrongForms(t *testing.T) {
+	if err := SetActivity("ru", []string{"обед"}, ""); err == nil {
+		t.Error("SetActivity accepted a single Russian form")
+	}
+	if err := SetActivity("de", nil, ""); err == nil {
+		t.Error("SetActivity accepted an unsupported language")
+	}
+	if got := Tr("ru", "keyboard.smoke"); got != "🚬 Го курить!" {
+		t.Errorf("a rejected activity changed the messages: %q", got)
+	}
+}
+
+func TestMatchesDefaultActivity(t *testing.T) {
+	setActivity(t, "en", []string{"lunch", "lunches", "a lunch"}, "eat")
+
+	for _, text := range []string{"🚬 Let's go eat!", "🚬 Let's go smoke!", "🚬 Го курить!"} {
+		if !Matches("keyboard.smoke", text) {
+			t.Errorf("Matches(keyboard.smoke, %q) = false, want true", text)
+		}
+	}
+	if Matches("keyboard.smoke", "🚬 Let's go {verb}!") {
+		t.Error("Matches accepted the raw template")
+	}
+}
+
+func TestCatalogsFillEveryPlaceholder(t *testing.T) {
+	for lang, messages := range catalogs {
+		for key := range messages {
+			if text := Tr(lang, key); strings.ContainsAny(text, "{}") {
+				t.Errorf("%s %s has an unknown placeholder: %q", lang, key, text)
+			}
+		}
+	}
+}
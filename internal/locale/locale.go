@@ -0,0 +1,125 @@
+// Package locale translates user-facing messages. Each supported language
+// has a catalog in messages/<lang>.json mapping message keys to fmt
+// templates.
+package locale
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// DefaultLanguage is used when no language is configured and for keys
+// missing from another catalog
+const DefaultLanguage = "ru"
+
+//go:embed messages/*.json
+var files embed.FS
+
+var (
+	catalogs = mustLoad()
+	language = DefaultLanguage
+)
+
+// mustLoad parses the embedded catalogs. They are compiled into the
+// binary, so a malformed one is a programming error.
+func mustLoad() map[string]map[string]string {
+	entries, err := files.ReadDir("messages")
+	if err != nil {
+		panic(fmt.Sprintf("locale: failed to read catalogs: %v", err))
+	}
+
+	loaded := make(map[string]map[string]string)
+	for _, entry := range entries {
+		data, err := files.ReadFile(path.Join("messages", entry.Name()))
+		if err != nil {
+			panic(fmt.Sprintf("locale: failed to read %s: %v", entry.Name(), err))
+		}
+
+		var messages map[string]string
+		if err := json.Unmarshal(data, &messages); err != nil {
+			panic(fmt.Sprintf("locale: failed to parse %s: %v", entry.Name(), err))
+		}
+
+		loaded[strings.TrimSuffix(entry.Name(), ".json")] = messages
+	}
+
+	return loaded
+}
+
+// Supported returns the codes of all languages with a catalog, sorted
+func Supported() []string {
+	var langs []string
+	for lang := range catalogs {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// IsSupported reports whether a language has a catalog
+func IsSupported(lang string) bool {
+	_, ok := catalogs[lang]
+	return ok
+}
+
+// SetLanguage sets the language T translates into
+func SetLanguage(lang string) error {
+	if !IsSupported(lang) {
+		return fmt.Errorf("unsupported language %q, expected one of %s", lang, strings.Join(Supported(), ", "))
+	}
+	language = lang
+	return nil
+}
+
+// Language returns the language T translates into
+func Language() string {
+	return language
+}
+
+// T translates a message into the configured language, formatting it with
+// args like fmt.Sprintf
+func T(key string, args ...any) string {
+	return Tr(language, key, args...)
+}
+
+// Tr translates a message into the given language. Keys missing from that
+// catalog fall back to DefaultLanguage, unknown keys are returned as is.
+// The activity placeholders are filled in before args, so user text such as
+// notes stays untouched.
+func Tr(lang, key string, args ...any) string {
+	template, ok := catalogs[lang][key]
+	if !ok {
+		lang = DefaultLanguage
+		template, ok = catalogs[lang][key]
+	}
+	if !ok {
+		return key
+	}
+	template = fillActivity(vocabularies, lang, template)
+
+	if len(args) == 0 {
+		return template
+	}
+	return fmt.Sprintf(template, args...)
+}
+
+// Matches reports whether text is the translation of a message in any
+// supported language, e.g. a keyboard button sent back by the user. The
+// text matches with both the configured and the default activity, so
+// keyboards sent before the activity changed keep working.
+func Matches(key, text string) bool {
+	for lang, messages := range catalogs {
+		template, ok := messages[key]
+		if !ok {
+			continue
+		}
+		if fillActivity(vocabularies, lang, template) == text || fillActivity(originalVocabularies, lang, template) == text {
+			return true
+		}
+	}
+	return false
+}
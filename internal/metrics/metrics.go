@@ -0,0 +1,106 @@
+// Package metrics exposes Prometheus metrics about sessions and responses.
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// shutdownTimeout bounds how long the server waits for in-flight scrapes
+const shutdownTimeout = 5 * time.Second
+
+var (
+	// SessionsStarted counts sessions started by users or by the schedule
+	SessionsStarted = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "smokebot_sessions_started_total",
+		Help: "Number of sessions started.",
+	})
+
+	// SessionsCancelled counts sessions cancelled before they ended
+	SessionsCancelled = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "smokebot_sessions_cancelled_total",
+		Help: "Number of sessions cancelled.",
+	})
+
+	// SessionsCompleted counts sessions completed manually or automatically
+	SessionsCompleted = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "smokebot_sessions_completed_total",
+		Help: "Number of sessions completed.",
+	})
+
+	// Responses counts invitation responses, labelled by response type
+	Responses = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "smokebot_responses_total",
+		Help: "Number of responses to invitations, by response type.",
+	}, []string{"response"})
+)
+
+// RegisterActiveSessions exposes the number of active sessions, read from
+// count on every scrape so it stays correct across restarts
+func RegisterActiveSessions(count func() (int, error)) {
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "smokebot_active_sessions",
+		Help: "Number of sessions currently active.",
+	}, func() float64 {
+		n, err := count()
+		if err != nil {
+			log.Printf("Error counting active sessions: %v", err)
+			return 0
+		}
+		return float64(n)
+	})
+}
+
+// Server serves the metrics on /metrics
+type Server struct {
+	server *http.Server
+}
+
+// NewServer creates a metrics server listening on the given port
+func NewServer(port string) *Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+
+	return &Server{server: &http.Server{
+		Addr:              ":" + port,
+		Handler:           mux,
+		ReadHeaderTimeout: shutdownTimeout,
+	}}
+}
+
+// Run serves requests until ctx is cancelled, then shuts the server down
+func (s *Server) Run(ctx context.Context) error {
+	errs := make(chan error, 1)
+	go func() {
+		errs <- s.server.ListenAndServe()
+	}()
+
+	log.Printf("Metrics listening on %s/metrics", s.server.Addr)
+
+	select {
+	case err := <-errs:
+		return fmt.Errorf("metrics server failed: %w", err)
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := s.server.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("failed to shut down metrics server: %w", err)
+	}
+
+	if err := <-errs; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("metrics server failed: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,90 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/glebk/smoke-bot/internal/domain"
+)
+
+// ChatRepository implements domain.ChatRepository in memory
+type ChatRepository struct {
+	mu    sync.RWMutex
+	chats map[int64]*domain.Chat
+}
+
+// NewChatRepository creates a new ChatRepository
+func NewChatRepository() *ChatRepository {
+	return &ChatRepository{chats: make(map[int64]*domain.Chat)}
+}
+
+// Upsert records a chat, refreshing its title, type and last seen time if it
+// is already known
+func (r *ChatRepository) Upsert(ctx context.Context, chat *domain.Chat) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	chat.LastSeenAt = now
+
+	existing, ok := r.chats[chat.ID]
+	if !ok {
+		stored := *chat
+		stored.FirstSeenAt = now
+		stored.PlainNames = false
+		r.chats[chat.ID] = &stored
+		return nil
+	}
+
+	existing.Title = chat.Title
+	existing.Type = chat.Type
+	existing.LastSeenAt = now
+
+	return nil
+}
+
+// GetByID retrieves a chat by ID
+func (r *ChatRepository) GetByID(ctx context.Context, id int64) (*domain.Chat, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	chat, ok := r.chats[id]
+	if !ok {
+		return nil, nil
+	}
+
+	c := *chat
+	return &c, nil
+}
+
+// GetAll retrieves all known chats
+func (r *ChatRepository) GetAll(ctx context.Context) ([]*domain.Chat, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	chats := make([]*domain.Chat, 0, len(r.chats))
+	for _, chat := range r.chats {
+		c := *chat
+		chats = append(chats, &c)
+	}
+
+	sort.Slice(chats, func(i, j int) bool {
+		return chats[i].Title < chats[j].Title
+	})
+
+	return chats, nil
+}
+
+// SetPlainNames sets whether names are rendered without @-mentions in a chat
+func (r *ChatRepository) SetPlainNames(ctx context.Context, chatID int64, plain bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if chat, ok := r.chats[chatID]; ok {
+		chat.PlainNames = plain
+	}
+
+	return nil
+}
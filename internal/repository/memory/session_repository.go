@@ -0,0 +1,749 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/glebk/smoke-bot/internal/domain"
+)
+
+// invitationKey identifies an invitation of one user to one session
+type invitationKey struct {
+	sessionID int64
+	userID    int64
+}
+
+// SessionRepository implements domain.SessionRepository in memory. It needs
+// the user repository to leave hidden users out of rankings, the same way
+// the SQLite implementation joins the users table.
+type SessionRepository struct {
+	mu    sync.RWMutex
+	users *UserRepository
+
+	sessions      map[int64]*domain.Session
+	responses     map[int64]*domain.SessionResponse
+	remindersSent map[int64]bool
+	invitations   map[invitationKey]time.Time
+	poked         map[invitationKey]bool
+
+	nextSessionID  int64
+	nextResponseID int64
+}
+
+// NewSessionRepository creates a new SessionRepository
+func NewSessionRepository(users *UserRepository) *SessionRepository {
+	return &SessionRepository{
+		users:         users,
+		sessions:      make(map[int64]*domain.Session),
+		responses:     make(map[int64]*domain.SessionResponse),
+		remindersSent: make(map[int64]bool),
+		invitations:   make(map[invitationKey]time.Time),
+		poked:         make(map[invitationKey]bool),
+	}
+}
+
+// Create creates a new session. Like the SQLite unique indexes, it refuses
+// a second active session in the same group, or in any private chat.
+// A CreatedAt already set is kept, so tests can place sessions in the past.
+func (r *SessionRepository) Create(ctx context.Context, session *domain.Session) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.create(session)
+}
+
+// CreateWithResponse creates a session and adds a first response to it
+// under one lock
+func (r *SessionRepository) CreateWithResponse(ctx context.Context, session *domain.Session, response *domain.SessionResponse) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.create(session); err != nil {
+		return err
+	}
+
+	response.SessionID = session.ID
+	r.addResponse(response)
+	return nil
+}
+
+// create stores a session. The caller must hold the write lock.
+func (r *SessionRepository) create(session *domain.Session) error {
+	if session.Status == domain.SessionStatusActive && r.hasActiveSession(session.ChatID, 0) {
+		return domain.ErrActiveSessionExists
+	}
+
+	r.nextSessionID++
+	session.ID = r.nextSessionID
+	if session.CreatedAt.IsZero() {
+		session.CreatedAt = time.Now()
+	}
+	r.sessions[session.ID] = copySession(session)
+
+	return nil
+}
+
+// GetByID retrieves a session by ID
+func (r *SessionRepository) GetByID(ctx context.Context, id int64) (*domain.Session, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	session, ok := r.sessions[id]
+	if !ok {
+		return nil, nil
+	}
+
+	return copySession(session), nil
+}
+
+// GetActiveSessionByChat retrieves the active session started in a chat
+func (r *SessionRepository) GetActiveSessionByChat(ctx context.Context, chatID int64) (*domain.Session, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var latest *domain.Session
+	for _, session := range r.sessions {
+		if session.Status != domain.SessionStatusActive || session.ChatID != chatID {
+			continue
+		}
+		if latest == nil || session.CreatedAt.After(latest.CreatedAt) {
+			latest = session
+		}
+	}
+
+	if latest == nil {
+		return nil, nil
+	}
+
+	return copySession(latest), nil
+}
+
+// GetActivePrivateSession retrieves the active session started in a private chat
+func (r *SessionRepository) GetActivePrivateSession(ctx context.Context) (*domain.Session, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var latest *domain.Session
+	for _, session := range r.sessions {
+		if session.Status != domain.SessionStatusActive || domain.IsGroupChat(session.ChatID) {
+			continue
+		}
+		if latest == nil || session.CreatedAt.After(latest.CreatedAt) {
+			latest = session
+		}
+	}
+
+	if latest == nil {
+		return nil, nil
+	}
+
+	return copySession(latest), nil
+}
+
+// GetLatestSessionByChat retrieves the most recent session of a chat,
+// whatever its status
+func (r *SessionRepository) GetLatestSessionByChat(ctx context.Context, chatID int64) (*domain.Session, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var latest *domain.Session
+	for _, session := range r.sessions {
+		if session.ChatID != chatID {
+			continue
+		}
+		if latest == nil || session.CreatedAt.After(latest.CreatedAt) {
+			latest = session
+		}
+	}
+
+	if latest == nil {
+		return nil, nil
+	}
+
+	return copySession(latest), nil
+}
+
+// GetLatestSessionByInitiator retrieves the most recent session a user
+// started, whatever its status
+func (r *SessionRepository) GetLatestSessionByInitiator(ctx context.Context, initiatorID int64) (*domain.Session, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var latest *domain.Session
+	for _, session := range r.sessions {
+		if session.InitiatorID != initiatorID {
+			continue
+		}
+		if latest == nil || session.CreatedAt.After(latest.CreatedAt) {
+			latest = session
+		}
+	}
+
+	if latest == nil {
+		return nil, nil
+	}
+
+	return copySession(latest), nil
+}
+
+// GetAllActiveSessions retrieves every active session, oldest first
+func (r *SessionRepository) GetAllActiveSessions(ctx context.Context) ([]*domain.Session, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var sessions []*domain.Session
+	for _, session := range r.sessions {
+		if session.Status == domain.SessionStatusActive {
+			sessions = append(sessions, copySession(session))
+		}
+	}
+
+	sortSessions(sessions)
+
+	return sessions, nil
+}
+
+// GetSessionsForUser retrieves the latest sessions a user started or joined,
+// newest first
+func (r *SessionRepository) GetSessionsForUser(ctx context.Context, userID int64, limit int) ([]*domain.Session, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	joined := make(map[int64]bool)
+	for _, resp := range r.responses {
+		if resp.UserID == userID && isAcceptance(resp.Response) {
+			joined[resp.SessionID] = true
+		}
+	}
+
+	var sessions []*domain.Session
+	for _, session := range r.sessions {
+		if session.InitiatorID == userID || joined[session.ID] {
+			sessions = append(sessions, copySession(session))
+		}
+	}
+
+	sortSessions(sessions)
+	for i, j := 0, len(sessions)-1; i < j; i, j = i+1, j-1 {
+		sessions[i], sessions[j] = sessions[j], sessions[i]
+	}
+
+	if len(sessions) > limit {
+		sessions = sessions[:limit]
+	}
+
+	return sessions, nil
+}
+
+// SetStatusMessage remembers the message that shows the live status of a
+// session. A shared one is the invitation posted to a group chat.
+func (r *SessionRepository) SetStatusMessage(ctx context.Context, sessionID int64, chatID int64, messageID int, shared bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if session, ok := r.sessions[sessionID]; ok {
+		session.StatusChatID = chatID
+		session.StatusMessageID = messageID
+		session.Shared = shared
+	}
+
+	return nil
+}
+
+// SetLocation stores where the break of a session takes place
+func (r *SessionRepository) SetLocation(ctx context.Context, sessionID int64, latitude, longitude float64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if session, ok := r.sessions[sessionID]; ok {
+		session.Latitude = &latitude
+		session.Longitude = &longitude
+	}
+
+	return nil
+}
+
+// GetSessionsBetween retrieves sessions started in [from, to), oldest first
+func (r *SessionRepository) GetSessionsBetween(ctx context.Context, from, to time.Time) ([]*domain.Session, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var sessions []*domain.Session
+	for _, session := range r.sessions {
+		if !session.CreatedAt.Before(from) && session.CreatedAt.Before(to) {
+			sessions = append(sessions, copySession(session))
+		}
+	}
+
+	sortSessions(sessions)
+
+	return sessions, nil
+}
+
+// GetAcceptedSessionTimes returns the start times of the sessions a user
+// confirmed attending, newest first. Cancelled and failed sessions are ignored.
+func (r *SessionRepository) GetAcceptedSessionTimes(ctx context.Context, userID int64) ([]time.Time, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var times []time.Time
+	for _, resp := range r.responses {
+		if resp.UserID != userID || !resp.IsAttendance() {
+			continue
+		}
+		if session, ok := r.sessions[resp.SessionID]; ok && session.Happened() {
+			times = append(times, session.CreatedAt)
+		}
+	}
+
+	sort.Slice(times, func(i, j int) bool {
+		return times[i].After(times[j])
+	})
+
+	return times, nil
+}
+
+// Update updates a session
+func (r *SessionRepository) Update(ctx context.Context, session *domain.Session) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if session.Status == domain.SessionStatusActive && r.hasActiveSession(session.ChatID, session.ID) {
+		return domain.ErrActiveSessionExists
+	}
+
+	if existing, ok := r.sessions[session.ID]; ok {
+		existing.Status = session.Status
+		existing.Failed = session.Failed
+		existing.CancelReason = session.CancelReason
+		existing.CompletedAt = session.CompletedAt
+		existing.TimeoutMinutes = session.TimeoutMinutes
+	}
+
+	return nil
+}
+
+// GetInitiatorCounts counts sessions started per user since the given time,
+// ignoring cancelled sessions and hidden users
+func (r *SessionRepository) GetInitiatorCounts(ctx context.Context, since time.Time, limit int) ([]domain.LeaderboardEntry, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ranking := newRanking()
+	for _, session := range r.sessions {
+		if session.Status == domain.SessionStatusCancelled || session.CreatedAt.Before(since) {
+			continue
+		}
+		if r.users.isHidden(session.InitiatorID) {
+			continue
+		}
+		ranking.add(session.InitiatorID, session.CreatedAt)
+	}
+
+	return ranking.entries(limit), nil
+}
+
+// GetAcceptedCounts counts sessions each user confirmed attending since the
+// given time, ignoring cancelled and failed sessions and hidden users. Ties
+// go to whoever joined first.
+func (r *SessionRepository) GetAcceptedCounts(ctx context.Context, since time.Time, limit int) ([]domain.LeaderboardEntry, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ranking := newRanking()
+	for _, resp := range r.responses {
+		if !resp.IsAttendance() || resp.CreatedAt.Before(since) {
+			continue
+		}
+		if r.users.isHidden(resp.UserID) || !r.happened(resp.SessionID) {
+			continue
+		}
+		ranking.add(resp.UserID, resp.CreatedAt)
+	}
+
+	return ranking.entries(limit), nil
+}
+
+// AddResponse adds a user response to a session, replacing an earlier
+// response of the same user
+func (r *SessionRepository) AddResponse(ctx context.Context, response *domain.SessionResponse) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.addResponse(response)
+	return nil
+}
+
+// addResponse stores or replaces a response. The caller must hold the
+// write lock.
+func (r *SessionRepository) addResponse(response *domain.SessionResponse) {
+	now := time.Now()
+	response.CreatedAt = now
+
+	for _, existing := range r.responses {
+		if existing.SessionID == response.SessionID && existing.UserID == response.UserID {
+			existing.Response = response.Response
+			existing.Option = response.Option
+			existing.Attended = nil
+			existing.CreatedAt = now
+			r.remindersSent[existing.ID] = false
+			response.ID = existing.ID
+			return
+		}
+	}
+
+	r.nextResponseID++
+	response.ID = r.nextResponseID
+	stored := *response
+	r.responses[response.ID] = &stored
+}
+
+// GetResponses retrieves all responses for a session
+func (r *SessionRepository) GetResponses(ctx context.Context, sessionID int64) ([]*domain.SessionResponse, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var responses []*domain.SessionResponse
+	for _, resp := range r.responses {
+		if resp.SessionID == sessionID {
+			c := *resp
+			responses = append(responses, &c)
+		}
+	}
+
+	sortResponses(responses)
+
+	return responses, nil
+}
+
+// GetUserResponse retrieves a specific user's response to a session
+func (r *SessionRepository) GetUserResponse(ctx context.Context, sessionID int64, userID int64) (*domain.SessionResponse, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, resp := range r.responses {
+		if resp.SessionID == sessionID && resp.UserID == userID {
+			c := *resp
+			return &c, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// UpdateResponse updates a user's response
+func (r *SessionRepository) UpdateResponse(ctx context.Context, response *domain.SessionResponse) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	if existing, ok := r.responses[response.ID]; ok {
+		existing.Response = response.Response
+		existing.Attended = nil
+		existing.CreatedAt = now
+	}
+	response.CreatedAt = now
+
+	return nil
+}
+
+// CountUserResponses counts a user's responses per type since the given
+// time, ignoring cancelled sessions
+func (r *SessionRepository) CountUserResponses(ctx context.Context, userID int64, since time.Time) (map[domain.ResponseType]int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	counts := make(map[domain.ResponseType]int)
+	for _, resp := range r.responses {
+		if resp.UserID == userID && !resp.CreatedAt.Before(since) && !r.isCancelled(resp.SessionID) {
+			counts[resp.Response]++
+		}
+	}
+
+	return counts, nil
+}
+
+// CountAttendance counts the sessions since the given time a user accepted
+// and confirmed attending, and those they accepted but haven't confirmed.
+// Cancelled sessions are ignored.
+func (r *SessionRepository) CountAttendance(ctx context.Context, userID int64, since time.Time) (attended, unconfirmed int, err error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, resp := range r.responses {
+		if resp.UserID != userID || !isAcceptance(resp.Response) || resp.CreatedAt.Before(since) || r.isCancelled(resp.SessionID) {
+			continue
+		}
+		switch {
+		case resp.Attended == nil:
+			unconfirmed++
+		case *resp.Attended:
+			attended++
+		}
+	}
+
+	return attended, unconfirmed, nil
+}
+
+// SetAttendance records whether a user actually came to a session
+func (r *SessionRepository) SetAttendance(ctx context.Context, sessionID int64, userID int64, attended bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, resp := range r.responses {
+		if resp.SessionID == sessionID && resp.UserID == userID {
+			resp.Attended = &attended
+		}
+	}
+
+	return nil
+}
+
+// hasActiveSession reports whether a chat has an active session other than
+// the given one. All private chats count as one. Callers must hold the lock.
+func (r *SessionRepository) hasActiveSession(chatID, exceptID int64) bool {
+	for _, session := range r.sessions {
+		if session.ID == exceptID || session.Status != domain.SessionStatusActive {
+			continue
+		}
+		if session.ChatID == chatID || !domain.IsGroupChat(session.ChatID) && !domain.IsGroupChat(chatID) {
+			return true
+		}
+	}
+	return false
+}
+
+// isCancelled reports whether a session was cancelled. Callers must hold
+// the lock.
+func (r *SessionRepository) isCancelled(sessionID int64) bool {
+	session, ok := r.sessions[sessionID]
+	return ok && session.Status == domain.SessionStatusCancelled
+}
+
+// happened reports whether a session took place, see domain.Session.Happened.
+// Callers must hold the lock.
+func (r *SessionRepository) happened(sessionID int64) bool {
+	session, ok := r.sessions[sessionID]
+	return !ok || session.Happened()
+}
+
+// GetLastResponseTime returns when the latest response to a session was
+// given, or nil if nobody has responded yet
+func (r *SessionRepository) GetLastResponseTime(ctx context.Context, sessionID int64) (*time.Time, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var last *time.Time
+	for _, resp := range r.responses {
+		if resp.SessionID != sessionID {
+			continue
+		}
+		if last == nil || resp.CreatedAt.After(*last) {
+			t := resp.CreatedAt
+			last = &t
+		}
+	}
+
+	return last, nil
+}
+
+// AddInvitation records that a user was invited to a session
+func (r *SessionRepository) AddInvitation(ctx context.Context, sessionID int64, userID int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := invitationKey{sessionID: sessionID, userID: userID}
+	if _, ok := r.invitations[key]; !ok {
+		r.invitations[key] = time.Now()
+	}
+
+	return nil
+}
+
+// CountInvitations counts invitations a user received since the given time
+func (r *SessionRepository) CountInvitations(ctx context.Context, userID int64, since time.Time) (int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	count := 0
+	for key, invitedAt := range r.invitations {
+		if key.userID == userID && !invitedAt.Before(since) {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+// CountAnsweredInvitations counts invitations since the given time that the
+// user responded to
+func (r *SessionRepository) CountAnsweredInvitations(ctx context.Context, userID int64, since time.Time) (int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	answered := make(map[int64]bool)
+	for _, resp := range r.responses {
+		if resp.UserID == userID {
+			answered[resp.SessionID] = true
+		}
+	}
+
+	count := 0
+	for key, invitedAt := range r.invitations {
+		if key.userID == userID && !invitedAt.Before(since) && answered[key.sessionID] {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+// IsInvited reports whether a user was already invited to a session
+func (r *SessionRepository) IsInvited(ctx context.Context, sessionID int64, userID int64) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	_, ok := r.invitations[invitationKey{sessionID: sessionID, userID: userID}]
+	return ok, nil
+}
+
+// MarkPoked records a reminder to a user who hasn't answered an invitation.
+// It reports false if the user was already poked in this session.
+func (r *SessionRepository) MarkPoked(ctx context.Context, sessionID int64, userID int64) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := invitationKey{sessionID: sessionID, userID: userID}
+	if r.poked[key] {
+		return false, nil
+	}
+
+	if _, ok := r.invitations[key]; !ok {
+		r.invitations[key] = time.Now()
+	}
+	r.poked[key] = true
+
+	return true, nil
+}
+
+// GetDueDelayedResponses retrieves delayed responses in active sessions that
+// were given before the cutoff and haven't been reminded yet
+func (r *SessionRepository) GetDueDelayedResponses(ctx context.Context, respondedBefore time.Time) ([]*domain.SessionResponse, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var responses []*domain.SessionResponse
+	for _, resp := range r.responses {
+		if resp.Response != domain.ResponseAcceptedDelayed || r.remindersSent[resp.ID] {
+			continue
+		}
+		if resp.CreatedAt.After(respondedBefore) {
+			continue
+		}
+		session, ok := r.sessions[resp.SessionID]
+		if !ok || session.Status != domain.SessionStatusActive {
+			continue
+		}
+
+		c := *resp
+		responses = append(responses, &c)
+	}
+
+	sortResponses(responses)
+
+	return responses, nil
+}
+
+// MarkReminderSent flags a delayed response as already reminded
+func (r *SessionRepository) MarkReminderSent(ctx context.Context, responseID int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.remindersSent[responseID] = true
+
+	return nil
+}
+
+// ranking accumulates per-user counts for leaderboard queries
+type ranking struct {
+	counts map[int64]int
+	first  map[int64]time.Time
+}
+
+// newRanking creates an empty ranking
+func newRanking() *ranking {
+	return &ranking{counts: make(map[int64]int), first: make(map[int64]time.Time)}
+}
+
+// add counts one event of a user at the given time
+func (rk *ranking) add(userID int64, at time.Time) {
+	rk.counts[userID]++
+	if first, ok := rk.first[userID]; !ok || at.Before(first) {
+		rk.first[userID] = at
+	}
+}
+
+// entries returns the top entries, highest count first and earliest first
+// on ties
+func (rk *ranking) entries(limit int) []domain.LeaderboardEntry {
+	var entries []domain.LeaderboardEntry
+	for userID, count := range rk.counts {
+		entries = append(entries, domain.LeaderboardEntry{UserID: userID, Count: count})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Count != entries[j].Count {
+			return entries[i].Count > entries[j].Count
+		}
+		return rk.first[entries[i].UserID].Before(rk.first[entries[j].UserID])
+	})
+
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+
+	return entries
+}
+
+// isAcceptance reports whether a response means the user joined the session
+func isAcceptance(response domain.ResponseType) bool {
+	return response == domain.ResponseAccepted || response == domain.ResponseAcceptedDelayed
+}
+
+// sortSessions orders sessions oldest first
+func sortSessions(sessions []*domain.Session) {
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].CreatedAt.Before(sessions[j].CreatedAt)
+	})
+}
+
+// sortResponses orders responses oldest first
+func sortResponses(responses []*domain.SessionResponse) {
+	sort.Slice(responses, func(i, j int) bool {
+		return responses[i].CreatedAt.Before(responses[j].CreatedAt)
+	})
+}
+
+// copySession returns a copy so callers can't modify stored sessions in place
+func copySession(session *domain.Session) *domain.Session {
+	c := *session
+	if session.CompletedAt != nil {
+		completedAt := *session.CompletedAt
+		c.CompletedAt = &completedAt
+	}
+	if session.HasLocation() {
+		latitude, longitude := *session.Latitude, *session.Longitude
+		c.Latitude, c.Longitude = &latitude, &longitude
+	}
+	c.Options = append([]string(nil), session.Options...)
+	return &c
+}
+
+// Compile-time checks that the memory repositories are drop-in replacements
+var (
+	_ domain.UserRepository    = (*UserRepository)(nil)
+	_ domain.SessionRepository = (*SessionRepository)(nil)
+	_ domain.ChatRepository    = (*ChatRepository)(nil)
+)
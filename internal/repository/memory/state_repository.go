@@ -0,0 +1,34 @@
+package memory
+
+import (
+	"context"
+	"sync"
+)
+
+// StateRepository implements domain.StateRepository in memory
+type StateRepository struct {
+	mu     sync.RWMutex
+	values map[string]string
+}
+
+// NewStateRepository creates a new StateRepository
+func NewStateRepository() *StateRepository {
+	return &StateRepository{values: make(map[string]string)}
+}
+
+// Get returns the value stored under key, or an empty string if none
+func (r *StateRepository) Get(ctx context.Context, key string) (string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.values[key], nil
+}
+
+// Set stores a value under key, replacing the previous one
+func (r *StateRepository) Set(ctx context.Context, key, value string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.values[key] = value
+	return nil
+}
@@ -0,0 +1,258 @@
+// Package memory provides in-memory implementations of the domain
+// repositories. They behave like the SQLite ones and are meant for tests and
+// local experiments where a database file gets in the way.
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/glebk/smoke-bot/internal/domain"
+)
+
+// UserRepository implements domain.UserRepository in memory
+type UserRepository struct {
+	mu    sync.RWMutex
+	users map[int64]*domain.User
+}
+
+// NewUserRepository creates a new UserRepository
+func NewUserRepository() *UserRepository {
+	return &UserRepository{users: make(map[int64]*domain.User)}
+}
+
+// Create creates a new user
+func (r *UserRepository) Create(ctx context.Context, user *domain.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.users[user.ID]; ok {
+		return fmt.Errorf("failed to create user: user %d already exists", user.ID)
+	}
+
+	now := time.Now()
+	user.CreatedAt = now
+	user.UpdatedAt = now
+	r.users[user.ID] = copyUser(user)
+
+	return nil
+}
+
+// GetByID retrieves a user by ID
+func (r *UserRepository) GetByID(ctx context.Context, id int64) (*domain.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	user, ok := r.users[id]
+	if !ok {
+		return nil, nil
+	}
+
+	return copyUser(user), nil
+}
+
+// GetByUsername retrieves a user by their Telegram username, ignoring case.
+// It returns nil if there is no such user.
+func (r *UserRepository) GetByUsername(ctx context.Context, username string) (*domain.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, user := range r.users {
+		if strings.EqualFold(user.Username, username) {
+			return copyUser(user), nil
+		}
+	}
+
+	return nil, nil
+}
+
+// ReleaseUsername clears a username, ignoring case, from every user but
+// exceptID
+func (r *UserRepository) ReleaseUsername(ctx context.Context, username string, exceptID int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, user := range r.users {
+		if user.ID != exceptID && strings.EqualFold(user.Username, username) {
+			user.Username = ""
+			user.UpdatedAt = time.Now()
+		}
+	}
+
+	return nil
+}
+
+// GetAll retrieves all users that haven't been deleted
+func (r *UserRepository) GetAll(ctx context.Context) ([]*domain.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	users := make([]*domain.User, 0, len(r.users))
+	for _, user := range r.users {
+		if !user.IsDeleted {
+			users = append(users, copyUser(user))
+		}
+	}
+
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].Username < users[j].Username
+	})
+
+	return users, nil
+}
+
+// Update updates a user
+func (r *UserRepository) Update(ctx context.Context, user *domain.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing, ok := r.users[user.ID]
+	if !ok {
+		return nil
+	}
+
+	// Only Delete and Restore change whether a user is deleted
+	user.IsDeleted = existing.IsDeleted
+	user.CreatedAt = existing.CreatedAt
+	user.UpdatedAt = time.Now()
+	r.users[user.ID] = copyUser(user)
+
+	return nil
+}
+
+// Delete marks a user as deleted. The user is kept so their sessions and
+// responses still count in the history, and GetByID still finds them.
+func (r *UserRepository) Delete(ctx context.Context, id int64) error {
+	return r.setDeleted(id, true)
+}
+
+// Restore brings back a deleted user
+func (r *UserRepository) Restore(ctx context.Context, id int64) error {
+	return r.setDeleted(id, false)
+}
+
+// setDeleted flags or unflags a user as deleted
+func (r *UserRepository) setDeleted(id int64, deleted bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if user, ok := r.users[id]; ok {
+		user.IsDeleted = deleted
+		user.UpdatedAt = time.Now()
+	}
+
+	return nil
+}
+
+// SetRemoteStatus sets the remote status for a user
+func (r *UserRepository) SetRemoteStatus(ctx context.Context, userID int64, until time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if user, ok := r.users[userID]; ok {
+		user.IsRemoteToday = true
+		user.RemoteUntil = &until
+		user.UpdatedAt = time.Now()
+	}
+
+	return nil
+}
+
+// ClearExpiredRemoteStatus clears remote status for users where the time has expired
+func (r *UserRepository) ClearExpiredRemoteStatus(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for _, user := range r.users {
+		if user.IsRemoteToday && user.RemoteUntil != nil && user.RemoteUntil.Before(now) {
+			user.IsRemoteToday = false
+			user.RemoteUntil = nil
+			user.UpdatedAt = now
+		}
+	}
+
+	return nil
+}
+
+// ClearAllRemoteStatus clears remote status for every user and returns how
+// many users were affected
+func (r *UserRepository) ClearAllRemoteStatus(ctx context.Context) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var affected int64
+	now := time.Now()
+	for _, user := range r.users {
+		if user.IsRemoteToday {
+			user.IsRemoteToday = false
+			user.RemoteUntil = nil
+			user.UpdatedAt = now
+			affected++
+		}
+	}
+
+	return affected, nil
+}
+
+// SetTimezone sets the IANA timezone used for a user's working hours
+func (r *UserRepository) SetTimezone(ctx context.Context, userID int64, tz string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if user, ok := r.users[userID]; ok {
+		user.Timezone = tz
+		user.UpdatedAt = time.Now()
+	}
+
+	return nil
+}
+
+// SetWeeklySummaryWeek records the last week a user got their weekly summary for
+func (r *UserRepository) SetWeeklySummaryWeek(ctx context.Context, userID int64, week string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if user, ok := r.users[userID]; ok {
+		user.WeeklySummaryWeek = week
+		user.UpdatedAt = time.Now()
+	}
+
+	return nil
+}
+
+// SetDigestWeek records the last week a user got the team digest for
+func (r *UserRepository) SetDigestWeek(ctx context.Context, userID int64, week string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if user, ok := r.users[userID]; ok {
+		user.DigestWeek = week
+		user.UpdatedAt = time.Now()
+	}
+
+	return nil
+}
+
+// isHidden reports whether a user exists and is hidden
+func (r *UserRepository) isHidden(userID int64) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	user, ok := r.users[userID]
+	return ok && user.IsHidden
+}
+
+// copyUser returns a copy so callers can't modify stored users in place
+func copyUser(user *domain.User) *domain.User {
+	c := *user
+	if user.RemoteUntil != nil {
+		until := *user.RemoteUntil
+		c.RemoteUntil = &until
+	}
+	return &c
+}
@@ -0,0 +1,130 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/glebk/smoke-bot/internal/domain"
+)
+
+// ChatRepository implements domain.ChatRepository using SQLite
+type ChatRepository struct {
+	db *Database
+}
+
+// NewChatRepository creates a new ChatRepository
+func NewChatRepository(db *Database) *ChatRepository {
+	return &ChatRepository{db: db}
+}
+
+// Upsert records a chat, refreshing its title, type and last seen time if it
+// is already known
+func (r *ChatRepository) Upsert(ctx context.Context, chat *domain.Chat) error {
+	query := `
+		INSERT INTO chats (id, title, type, first_seen_at, last_seen_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET title = excluded.title, type = excluded.type, last_seen_at = excluded.last_seen_at
+	`
+
+	now := time.Now()
+	_, err := r.db.GetDB().ExecContext(ctx, query,
+		chat.ID,
+		chat.Title,
+		chat.Type,
+		now,
+		now,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to upsert chat: %w", err)
+	}
+
+	chat.LastSeenAt = now
+
+	return nil
+}
+
+// GetByID retrieves a chat by ID
+func (r *ChatRepository) GetByID(ctx context.Context, id int64) (*domain.Chat, error) {
+	query := `
+		SELECT id, title, type, plain_names, first_seen_at, last_seen_at
+		FROM chats
+		WHERE id = ?
+	`
+
+	chat := &domain.Chat{}
+	var plainNames int
+
+	err := r.db.GetDB().QueryRowContext(ctx, query, id).Scan(
+		&chat.ID,
+		&chat.Title,
+		&chat.Type,
+		&plainNames,
+		&chat.FirstSeenAt,
+		&chat.LastSeenAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chat: %w", err)
+	}
+
+	chat.PlainNames = intToBool(plainNames)
+
+	return chat, nil
+}
+
+// GetAll retrieves all known chats
+func (r *ChatRepository) GetAll(ctx context.Context) ([]*domain.Chat, error) {
+	query := `
+		SELECT id, title, type, plain_names, first_seen_at, last_seen_at
+		FROM chats
+		ORDER BY title
+	`
+
+	rows, err := r.db.GetDB().QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get all chats: %w", err)
+	}
+	defer rows.Close()
+
+	var chats []*domain.Chat
+
+	for rows.Next() {
+		chat := &domain.Chat{}
+		var plainNames int
+
+		err := rows.Scan(
+			&chat.ID,
+			&chat.Title,
+			&chat.Type,
+			&plainNames,
+			&chat.FirstSeenAt,
+			&chat.LastSeenAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan chat: %w", err)
+		}
+
+		chat.PlainNames = intToBool(plainNames)
+
+		chats = append(chats, chat)
+	}
+
+	return chats, nil
+}
+
+// SetPlainNames sets whether names are rendered without @-mentions in a chat
+func (r *ChatRepository) SetPlainNames(ctx context.Context, chatID int64, plain bool) error {
+	query := `UPDATE chats SET plain_names = ? WHERE id = ?`
+
+	if _, err := r.db.GetDB().ExecContext(ctx, query, boolToInt(plain), chatID); err != nil {
+		return fmt.Errorf("failed to set plain names: %w", err)
+	}
+
+	return nil
+}
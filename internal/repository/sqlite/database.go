@@ -1,10 +1,17 @@
 package sqlite
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
 
-	_ "modernc.org/sqlite"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
 )
 
 // Database wraps the SQL database connection
@@ -12,76 +19,90 @@ type Database struct {
 	db *sql.DB
 }
 
-// New creates a new database connection and initializes the schema
+// New creates a new database connection and applies pending migrations. The
+// directory holding the database file is created if it doesn't exist yet.
+// Besides file paths, dbPath may be ":memory:" for a fresh private database
+// or a shared-cache URI such as "file::memory:?cache=shared".
 func New(dbPath string) (*Database, error) {
-	db, err := sql.Open("sqlite", dbPath)
+	dsn := dbPath
+	if dbPath == ":memory:" {
+		// Every pooled connection to ":memory:" would get its own empty
+		// database, so name a shared-cache one private to this handle
+		dsn = fmt.Sprintf("file:memdb%d?mode=memory&cache=shared", memoryDatabases.Add(1))
+	} else if !isInMemory(dbPath) {
+		dir := filepath.Dir(filePath(dbPath))
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create database directory %s: %w", dir, err)
+		}
+	}
+
+	db, err := sql.Open("sqlite", withPragmas(dsn))
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
-	// Enable foreign keys
-	if _, err := db.Exec("PRAGMA foreign_keys = ON"); err != nil {
-		return nil, fmt.Errorf("failed to enable foreign keys: %w", err)
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
 	database := &Database{db: db}
 
-	if err := database.initSchema(); err != nil {
-		return nil, fmt.Errorf("failed to initialize schema: %w", err)
+	if err := database.migrate(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate database: %w", err)
 	}
 
 	return database, nil
 }
 
+// memoryDatabases counts the private in-memory databases opened so far
+var memoryDatabases atomic.Int64
+
+// isInMemory reports whether a database path refers to an in-memory
+// database rather than a file
+func isInMemory(dbPath string) bool {
+	return dbPath == ":memory:" ||
+		strings.HasPrefix(dbPath, "file::memory:") ||
+		strings.Contains(dbPath, "mode=memory")
+}
+
+// filePath strips the URI scheme and query parameters from a database path
+func filePath(dbPath string) string {
+	path, _, _ := strings.Cut(strings.TrimPrefix(dbPath, "file:"), "?")
+	return path
+}
+
+// withPragmas enables foreign keys on every connection the pool opens, a
+// PRAGMA statement would only reach one of them. Connections also wait for
+// each other's locks for a while instead of failing at once with
+// SQLITE_BUSY when several writes happen at the same time.
+func withPragmas(dsn string) string {
+	separator := "?"
+	if strings.Contains(dsn, "?") {
+		separator = "&"
+	}
+	return dsn + separator + "_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)"
+}
+
+// isUniqueViolation reports whether a statement failed because it would
+// break a unique constraint or index
+func isUniqueViolation(err error) bool {
+	var sqliteErr *sqlite.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE
+}
+
 // Close closes the database connection
 func (d *Database) Close() error {
 	return d.db.Close()
 }
 
+// Ping checks that the database is still reachable
+func (d *Database) Ping(ctx context.Context) error {
+	return d.db.PingContext(ctx)
+}
+
 // GetDB returns the underlying database connection
 func (d *Database) GetDB() *sql.DB {
 	return d.db
 }
-
-// initSchema creates the database tables
-func (d *Database) initSchema() error {
-	schema := `
-	CREATE TABLE IF NOT EXISTS users (
-		id INTEGER PRIMARY KEY,
-		username TEXT NOT NULL,
-		first_name TEXT NOT NULL,
-		last_name TEXT,
-		is_remote_today INTEGER DEFAULT 0,
-		remote_until DATETIME,
-		is_hidden INTEGER DEFAULT 0,
-		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
-	);
-	
-	CREATE TABLE IF NOT EXISTS sessions (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		initiator_id INTEGER NOT NULL,
-		status TEXT NOT NULL,
-		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-		completed_at DATETIME,
-		FOREIGN KEY (initiator_id) REFERENCES users(id)
-	);
-	
-	CREATE TABLE IF NOT EXISTS session_responses (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		session_id INTEGER NOT NULL,
-		user_id INTEGER NOT NULL,
-		response TEXT NOT NULL,
-		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-		FOREIGN KEY (session_id) REFERENCES sessions(id) ON DELETE CASCADE,
-		FOREIGN KEY (user_id) REFERENCES users(id),
-		UNIQUE(session_id, user_id)
-	);
-	
-	CREATE INDEX IF NOT EXISTS idx_sessions_status ON sessions(status);
-	CREATE INDEX IF NOT EXISTS idx_session_responses_session ON session_responses(session_id);
-	`
-
-	_, err := d.db.Exec(schema)
-	return err
-}
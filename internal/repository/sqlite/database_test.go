@@ -0,0 +1,52 @@
+package sqlite
+
+import (
+	"context"
+	"testing"
+
+	"github.com/glebk/smoke-bot/internal/domain"
+)
+
+// newTestDB opens a migrated in-memory database that is closed when the
+// test ends. ":memory:" gets a shared-cache database of its own, so every
+// pooled connection sees the same data.
+func newTestDB(t *testing.T) *Database {
+	t.Helper()
+
+	db, err := New(":memory:")
+	if err != nil {
+		t.Fatalf("opening test database: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("closing test database: %v", err)
+		}
+	})
+
+	return db
+}
+
+// createUsers stores users with the given IDs
+func createUsers(t *testing.T, repo *UserRepository, ids ...int64) {
+	t.Helper()
+
+	for _, id := range ids {
+		if err := repo.Create(context.Background(), &domain.User{ID: id, FirstName: "User"}); err != nil {
+			t.Fatalf("creating user %d: %v", id, err)
+		}
+	}
+}
+
+func TestNewDatabasesAreIndependent(t *testing.T) {
+	first := NewUserRepository(newTestDB(t))
+	second := NewUserRepository(newTestDB(t))
+	createUsers(t, first, 1)
+
+	user, err := second.GetByID(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if user != nil {
+		t.Error("a user created in one in-memory database shows up in another")
+	}
+}
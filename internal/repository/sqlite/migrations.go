@@ -0,0 +1,270 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// migration is a single versioned schema change
+type migration struct {
+	version     int
+	description string
+	up          func(tx *sql.Tx) error
+}
+
+// migrations lists every schema change in the order it is applied. Append new
+// migrations to the end and never change the version of an existing one.
+//
+// Databases created before versioning already have some of the added
+// columns, so the early column migrations skip columns that exist.
+var migrations = []migration{
+	{1, "initial schema", execMigration(`
+	CREATE TABLE IF NOT EXISTS users (
+		id INTEGER PRIMARY KEY,
+		username TEXT NOT NULL,
+		first_name TEXT NOT NULL,
+		last_name TEXT,
+		is_remote_today INTEGER DEFAULT 0,
+		remote_until DATETIME,
+		is_hidden INTEGER DEFAULT 0,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	
+	CREATE TABLE IF NOT EXISTS sessions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		initiator_id INTEGER NOT NULL,
+		status TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		completed_at DATETIME,
+		FOREIGN KEY (initiator_id) REFERENCES users(id)
+	);
+	
+	CREATE TABLE IF NOT EXISTS session_responses (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		session_id INTEGER NOT NULL,
+		user_id INTEGER NOT NULL,
+		response TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (session_id) REFERENCES sessions(id) ON DELETE CASCADE,
+		FOREIGN KEY (user_id) REFERENCES users(id),
+		UNIQUE(session_id, user_id)
+	);
+	
+	CREATE TABLE IF NOT EXISTS session_invitations (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		session_id INTEGER NOT NULL,
+		user_id INTEGER NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (session_id) REFERENCES sessions(id) ON DELETE CASCADE,
+		FOREIGN KEY (user_id) REFERENCES users(id),
+		UNIQUE(session_id, user_id)
+	);
+	
+	CREATE TABLE IF NOT EXISTS chats (
+		id INTEGER PRIMARY KEY,
+		title TEXT NOT NULL DEFAULT '',
+		type TEXT NOT NULL,
+		first_seen_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		last_seen_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	
+	CREATE INDEX IF NOT EXISTS idx_sessions_status ON sessions(status);
+	CREATE INDEX IF NOT EXISTS idx_session_responses_session ON session_responses(session_id);
+	CREATE INDEX IF NOT EXISTS idx_session_responses_user ON session_responses(user_id, created_at);
+	CREATE INDEX IF NOT EXISTS idx_session_invitations_user ON session_invitations(user_id, created_at);
+	`)},
+	{2, "users.skip_own_summary", addColumnMigration("users", "skip_own_summary", "INTEGER DEFAULT 0")},
+	{3, "session_responses.reminder_sent", addColumnMigration("session_responses", "reminder_sent", "INTEGER DEFAULT 0")},
+	{4, "users.approval_status", addColumnMigration("users", "approval_status", "TEXT NOT NULL DEFAULT ''")},
+	{5, "users.weekly_summary", addColumnMigration("users", "weekly_summary", "INTEGER DEFAULT 0")},
+	{6, "users.weekly_summary_week", addColumnMigration("users", "weekly_summary_week", "TEXT NOT NULL DEFAULT ''")},
+	{7, "chats.plain_names", addColumnMigration("chats", "plain_names", "INTEGER DEFAULT 0")},
+	{8, "users.terse_replies", addColumnMigration("users", "terse_replies", "INTEGER DEFAULT 0")},
+	{9, "users.timezone", addColumnMigration("users", "timezone", "TEXT NOT NULL DEFAULT ''")},
+	{10, "sessions.chat_id", addColumnMigration("sessions", "chat_id", "INTEGER NOT NULL DEFAULT 0")},
+	{11, "users.delay_minutes", addColumnMigration("users", "delay_minutes", "INTEGER NOT NULL DEFAULT 5")},
+	{12, "sessions.timeout_minutes", addColumnMigration("sessions", "timeout_minutes", "INTEGER NOT NULL DEFAULT 15")},
+	{13, "session_invitations.poked", addColumnMigration("session_invitations", "poked", "INTEGER DEFAULT 0")},
+	{14, "users.is_muted", addColumnMigration("users", "is_muted", "INTEGER DEFAULT 0")},
+	{15, "sessions chat index", execMigration(`CREATE INDEX IF NOT EXISTS idx_sessions_chat ON sessions(chat_id, status)`)},
+	{16, "users username index", execMigration(`CREATE INDEX IF NOT EXISTS idx_users_username ON users(username COLLATE NOCASE)`)},
+	{17, "users.digest_week", addColumnMigration("users", "digest_week", "TEXT NOT NULL DEFAULT ''")},
+	{18, "sessions.note", addColumnMigration("sessions", "note", "TEXT NOT NULL DEFAULT ''")},
+	{19, "users.quiet_from", addColumnMigration("users", "quiet_from", "INTEGER NOT NULL DEFAULT 0")},
+	{20, "users.quiet_to", addColumnMigration("users", "quiet_to", "INTEGER NOT NULL DEFAULT 0")},
+	{21, "sessions.status_chat_id", addColumnMigration("sessions", "status_chat_id", "INTEGER NOT NULL DEFAULT 0")},
+	{22, "sessions.status_message_id", addColumnMigration("sessions", "status_message_id", "INTEGER NOT NULL DEFAULT 0")},
+	{23, "sessions.latitude", addColumnMigration("sessions", "latitude", "REAL")},
+	{24, "sessions.longitude", addColumnMigration("sessions", "longitude", "REAL")},
+	{25, "users.language", addColumnMigration("users", "language", "TEXT NOT NULL DEFAULT ''")},
+	{26, "session_responses.attended", addColumnMigration("session_responses", "attended", "INTEGER")},
+	// Races used to let a chat end up with several active sessions, keep
+	// the newest one before enforcing a single active session per chat
+	{28, "one active session per chat", execMigration(`
+	UPDATE sessions SET status = 'completed', completed_at = CURRENT_TIMESTAMP
+	WHERE status = 'active'
+		AND id NOT IN (SELECT MAX(id) FROM sessions WHERE status = 'active' GROUP BY chat_id);
+	
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_sessions_active_chat ON sessions(chat_id) WHERE status = 'active';
+	`)},
+	{29, "users.display_name", addColumnMigration("users", "display_name", "TEXT NOT NULL DEFAULT ''")},
+	{30, "users.is_deleted", addColumnMigration("users", "is_deleted", "INTEGER DEFAULT 0")},
+	{31, "sessions.failed", addColumnMigration("sessions", "failed", "INTEGER NOT NULL DEFAULT 0")},
+	{32, "bot_state", execMigration(`
+	CREATE TABLE IF NOT EXISTS bot_state (
+		key TEXT PRIMARY KEY,
+		value TEXT NOT NULL,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	`)},
+	{33, "sessions.shared", addColumnMigration("sessions", "shared", "INTEGER NOT NULL DEFAULT 0")},
+	{34, "sessions.cancel_reason", addColumnMigration("sessions", "cancel_reason", "TEXT NOT NULL DEFAULT ''")},
+	{35, "sessions.options", addColumnMigration("sessions", "options", "TEXT NOT NULL DEFAULT ''")},
+	{36, "session_responses.option", addColumnMigration("session_responses", "option", "TEXT NOT NULL DEFAULT ''")},
+	// Private chats invite the same colleagues, so they share one active
+	// session; the index is on a constant so it allows a single row
+	{37, "one active session across private chats", execMigration(`
+	UPDATE sessions SET status = 'completed', completed_at = CURRENT_TIMESTAMP
+	WHERE status = 'active' AND chat_id >= 0
+		AND id <> (SELECT MAX(id) FROM sessions WHERE status = 'active' AND chat_id >= 0);
+	
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_sessions_active_private ON sessions((chat_id >= 0)) WHERE status = 'active' AND chat_id >= 0;
+	`)},
+	{38, "users.is_unreachable", addColumnMigration("users", "is_unreachable", "INTEGER NOT NULL DEFAULT 0")},
+}
+
+// migrate creates the schema_migrations table and applies every migration
+// that hasn't been applied yet
+func (d *Database) migrate() error {
+	_, err := d.db.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			applied_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+
+	applied, err := d.appliedMigrations()
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if applied[m.version] {
+			continue
+		}
+
+		if err := d.applyMigration(m); err != nil {
+			return fmt.Errorf("migration %d (%s): %w", m.version, m.description, err)
+		}
+	}
+
+	return nil
+}
+
+// appliedMigrations returns the versions recorded in schema_migrations
+func (d *Database) appliedMigrations() (map[int]bool, error) {
+	rows, err := d.db.Query(`SELECT version FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get applied migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]bool)
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			return nil, fmt.Errorf("failed to scan migration version: %w", err)
+		}
+		applied[version] = true
+	}
+
+	return applied, rows.Err()
+}
+
+// applyMigration runs a migration and records its version in one
+// transaction, so a failed migration leaves no trace
+func (d *Database) applyMigration(m migration) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := m.up(tx); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)`, m.version, time.Now()); err != nil {
+		return fmt.Errorf("failed to record migration: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit migration: %w", err)
+	}
+
+	return nil
+}
+
+// execMigration returns a migration step that executes the given SQL
+func execMigration(query string) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		if _, err := tx.Exec(query); err != nil {
+			return fmt.Errorf("failed to execute migration: %w", err)
+		}
+		return nil
+	}
+}
+
+// addColumnMigration returns a migration step that adds a column to a table
+// unless it already exists
+func addColumnMigration(table, column, definition string) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		exists, err := columnExists(tx, table, column)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return nil
+		}
+
+		query := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition)
+		if _, err := tx.Exec(query); err != nil {
+			return fmt.Errorf("failed to add column %s.%s: %w", table, column, err)
+		}
+
+		return nil
+	}
+}
+
+// columnExists checks whether a table already has the given column
+func columnExists(tx *sql.Tx, table, column string) (bool, error) {
+	rows, err := tx.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return false, fmt.Errorf("failed to inspect table %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid        int
+			name       string
+			colType    string
+			notNull    int
+			defaultVal sql.NullString
+			primaryKey int
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultVal, &primaryKey); err != nil {
+			return false, fmt.Errorf("failed to scan table info: %w", err)
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+
+	return false, rows.Err()
+}
@@ -1,8 +1,10 @@
 package sqlite
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"strings"
 	"time"
 	
 	"github.com/glebk/smoke-bot/internal/domain"
@@ -18,20 +20,69 @@ func NewSessionRepository(db *Database) *SessionRepository {
 	return &SessionRepository{db: db}
 }
 
+// optionSeparator joins the options of a vote in the options column. Options
+// are single-line, so a newline never occurs inside one.
+const optionSeparator = "\n"
+
+// sessionColumns lists the sessions table columns in the order scanSession expects
+const sessionColumns = `id, initiator_id, chat_id, timeout_minutes, note, status_chat_id, status_message_id, shared, latitude, longitude, status, failed, cancel_reason, options, created_at, completed_at`
+
+// execer runs statements on the database or within a transaction
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
 // Create creates a new session
-func (r *SessionRepository) Create(session *domain.Session) error {
+func (r *SessionRepository) Create(ctx context.Context, session *domain.Session) error {
+	return createSession(ctx, r.db.GetDB(), session)
+}
+
+// CreateWithResponse creates a session and adds a first response to it in
+// one transaction
+func (r *SessionRepository) CreateWithResponse(ctx context.Context, session *domain.Session, response *domain.SessionResponse) error {
+	tx, err := r.db.GetDB().BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	
+	if err := createSession(ctx, tx, session); err != nil {
+		return err
+	}
+	
+	response.SessionID = session.ID
+	if err := addResponse(ctx, tx, response); err != nil {
+		return err
+	}
+	
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit session: %w", err)
+	}
+	
+	return nil
+}
+
+// createSession inserts a session and sets its ID and creation time
+func createSession(ctx context.Context, db execer, session *domain.Session) error {
 	query := `
-		INSERT INTO sessions (initiator_id, status, created_at)
-		VALUES (?, ?, ?)
+		INSERT INTO sessions (initiator_id, chat_id, timeout_minutes, note, options, status, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
 	`
 	
 	now := time.Now()
-	result, err := r.db.GetDB().Exec(query,
+	result, err := db.ExecContext(ctx, query,
 		session.InitiatorID,
+		session.ChatID,
+		session.TimeoutMinutes,
+		session.Note,
+		strings.Join(session.Options, optionSeparator),
 		session.Status,
 		now,
 	)
 	
+	if isUniqueViolation(err) {
+		return domain.ErrActiveSessionExists
+	}
 	if err != nil {
 		return fmt.Errorf("failed to create session: %w", err)
 	}
@@ -48,87 +99,257 @@ func (r *SessionRepository) Create(session *domain.Session) error {
 }
 
 // GetByID retrieves a session by ID
-func (r *SessionRepository) GetByID(id int64) (*domain.Session, error) {
+func (r *SessionRepository) GetByID(ctx context.Context, id int64) (*domain.Session, error) {
+	query := `SELECT ` + sessionColumns + ` FROM sessions WHERE id = ?`
+	
+	session, err := scanSession(r.db.GetDB().QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+	
+	return session, nil
+}
+
+// GetActiveSessionByChat retrieves the active session started in a chat
+func (r *SessionRepository) GetActiveSessionByChat(ctx context.Context, chatID int64) (*domain.Session, error) {
 	query := `
-		SELECT id, initiator_id, status, created_at, completed_at
+		SELECT ` + sessionColumns + `
 		FROM sessions
-		WHERE id = ?
+		WHERE status = ? AND chat_id = ?
+		ORDER BY created_at DESC
+		LIMIT 1
 	`
 	
-	session := &domain.Session{}
-	var completedAt sql.NullTime
+	session, err := scanSession(r.db.GetDB().QueryRowContext(ctx, query, domain.SessionStatusActive, chatID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get active session: %w", err)
+	}
 	
-	err := r.db.GetDB().QueryRow(query, id).Scan(
-		&session.ID,
-		&session.InitiatorID,
-		&session.Status,
-		&session.CreatedAt,
-		&completedAt,
-	)
+	return session, nil
+}
+
+// GetActivePrivateSession retrieves the active session started in a private
+// chat. Sessions from before chats were tracked have chat ID 0 and count as
+// private.
+func (r *SessionRepository) GetActivePrivateSession(ctx context.Context) (*domain.Session, error) {
+	query := `
+		SELECT ` + sessionColumns + `
+		FROM sessions
+		WHERE status = ? AND chat_id >= 0
+		ORDER BY created_at DESC
+		LIMIT 1
+	`
 	
+	session, err := scanSession(r.db.GetDB().QueryRowContext(ctx, query, domain.SessionStatusActive))
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to get session: %w", err)
+		return nil, fmt.Errorf("failed to get active session: %w", err)
 	}
 	
-	if completedAt.Valid {
-		session.CompletedAt = &completedAt.Time
+	return session, nil
+}
+
+// GetLatestSessionByChat retrieves the most recent session of a chat,
+// whatever its status
+func (r *SessionRepository) GetLatestSessionByChat(ctx context.Context, chatID int64) (*domain.Session, error) {
+	query := `
+		SELECT ` + sessionColumns + `
+		FROM sessions
+		WHERE chat_id = ?
+		ORDER BY created_at DESC
+		LIMIT 1
+	`
+	
+	session, err := scanSession(r.db.GetDB().QueryRowContext(ctx, query, chatID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest session: %w", err)
 	}
 	
 	return session, nil
 }
 
-// GetActiveSession retrieves the current active session
-func (r *SessionRepository) GetActiveSession() (*domain.Session, error) {
+// GetLatestSessionByInitiator retrieves the most recent session a user
+// started, whatever its status
+func (r *SessionRepository) GetLatestSessionByInitiator(ctx context.Context, initiatorID int64) (*domain.Session, error) {
 	query := `
-		SELECT id, initiator_id, status, created_at, completed_at
+		SELECT ` + sessionColumns + `
 		FROM sessions
-		WHERE status = ?
+		WHERE initiator_id = ?
 		ORDER BY created_at DESC
 		LIMIT 1
 	`
 	
-	session := &domain.Session{}
-	var completedAt sql.NullTime
+	session, err := scanSession(r.db.GetDB().QueryRowContext(ctx, query, initiatorID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest session: %w", err)
+	}
 	
-	err := r.db.GetDB().QueryRow(query, domain.SessionStatusActive).Scan(
-		&session.ID,
-		&session.InitiatorID,
-		&session.Status,
-		&session.CreatedAt,
-		&completedAt,
+	return session, nil
+}
+
+// GetAllActiveSessions retrieves every active session, oldest first
+func (r *SessionRepository) GetAllActiveSessions(ctx context.Context) ([]*domain.Session, error) {
+	query := `
+		SELECT ` + sessionColumns + `
+		FROM sessions
+		WHERE status = ?
+		ORDER BY created_at
+	`
+	
+	rows, err := r.db.GetDB().QueryContext(ctx, query, domain.SessionStatusActive)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get active sessions: %w", err)
+	}
+	defer rows.Close()
+	
+	var sessions []*domain.Session
+	
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan session: %w", err)
+		}
+		
+		sessions = append(sessions, session)
+	}
+	
+	return sessions, nil
+}
+
+// GetSessionsForUser retrieves the latest sessions a user started or joined,
+// newest first
+func (r *SessionRepository) GetSessionsForUser(ctx context.Context, userID int64, limit int) ([]*domain.Session, error) {
+	query := `
+		SELECT ` + sessionColumns + `
+		FROM sessions
+		WHERE initiator_id = ? OR id IN (
+			SELECT session_id FROM session_responses
+			WHERE user_id = ? AND response IN (?, ?)
+		)
+		ORDER BY created_at DESC
+		LIMIT ?
+	`
+	
+	rows, err := r.db.GetDB().QueryContext(ctx, query,
+		userID,
+		userID,
+		domain.ResponseAccepted,
+		domain.ResponseAcceptedDelayed,
+		limit,
 	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user sessions: %w", err)
+	}
+	defer rows.Close()
 	
-	if err == sql.ErrNoRows {
-		return nil, nil
+	var sessions []*domain.Session
+	
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan session: %w", err)
+		}
+		
+		sessions = append(sessions, session)
 	}
+	
+	return sessions, nil
+}
+
+// GetSessionsBetween retrieves sessions started in [from, to), oldest first
+func (r *SessionRepository) GetSessionsBetween(ctx context.Context, from, to time.Time) ([]*domain.Session, error) {
+	query := `
+		SELECT ` + sessionColumns + `
+		FROM sessions
+		WHERE created_at >= ? AND created_at < ?
+		ORDER BY created_at
+	`
+	
+	rows, err := r.db.GetDB().QueryContext(ctx, query, from, to)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get active session: %w", err)
+		return nil, fmt.Errorf("failed to get sessions: %w", err)
 	}
+	defer rows.Close()
 	
-	if completedAt.Valid {
-		session.CompletedAt = &completedAt.Time
+	var sessions []*domain.Session
+	
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan session: %w", err)
+		}
+		
+		sessions = append(sessions, session)
 	}
 	
-	return session, nil
+	return sessions, nil
+}
+
+// GetAcceptedSessionTimes returns the start times of the sessions a user
+// confirmed attending, newest first. Cancelled and failed sessions are ignored.
+func (r *SessionRepository) GetAcceptedSessionTimes(ctx context.Context, userID int64) ([]time.Time, error) {
+	query := `
+		SELECT s.created_at
+		FROM sessions s
+		JOIN session_responses sr ON sr.session_id = s.id
+		WHERE sr.user_id = ? AND sr.response IN (?, ?) AND sr.attended = 1 AND s.status != ? AND s.failed = 0
+		ORDER BY s.created_at DESC
+	`
+	
+	rows, err := r.db.GetDB().QueryContext(ctx, query, userID, domain.ResponseAccepted, domain.ResponseAcceptedDelayed, domain.SessionStatusCancelled)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get accepted sessions: %w", err)
+	}
+	defer rows.Close()
+	
+	var times []time.Time
+	
+	for rows.Next() {
+		var createdAt time.Time
+		if err := rows.Scan(&createdAt); err != nil {
+			return nil, fmt.Errorf("failed to scan session time: %w", err)
+		}
+		
+		times = append(times, createdAt)
+	}
+	
+	return times, nil
 }
 
 // Update updates a session
-func (r *SessionRepository) Update(session *domain.Session) error {
+func (r *SessionRepository) Update(ctx context.Context, session *domain.Session) error {
 	query := `
 		UPDATE sessions
-		SET status = ?, completed_at = ?
+		SET status = ?, failed = ?, cancel_reason = ?, completed_at = ?, timeout_minutes = ?
 		WHERE id = ?
 	`
 	
-	_, err := r.db.GetDB().Exec(query,
+	_, err := r.db.GetDB().ExecContext(ctx, query,
 		session.Status,
+		boolToInt(session.Failed),
+		session.CancelReason,
 		session.CompletedAt,
+		session.TimeoutMinutes,
 		session.ID,
 	)
 	
+	if isUniqueViolation(err) {
+		return domain.ErrActiveSessionExists
+	}
 	if err != nil {
 		return fmt.Errorf("failed to update session: %w", err)
 	}
@@ -136,43 +357,135 @@ func (r *SessionRepository) Update(session *domain.Session) error {
 	return nil
 }
 
-// CompleteSession marks a session as completed
-func (r *SessionRepository) CompleteSession(sessionID int64) error {
+// SetStatusMessage remembers the message that shows the live status of a
+// session. A shared one is the invitation posted to a group chat.
+func (r *SessionRepository) SetStatusMessage(ctx context.Context, sessionID int64, chatID int64, messageID int, shared bool) error {
 	query := `
 		UPDATE sessions
-		SET status = ?, completed_at = ?
+		SET status_chat_id = ?, status_message_id = ?, shared = ?
 		WHERE id = ?
 	`
 	
-	now := time.Now()
-	_, err := r.db.GetDB().Exec(query,
-		domain.SessionStatusCompleted,
-		now,
-		sessionID,
-	)
+	_, err := r.db.GetDB().ExecContext(ctx, query, chatID, messageID, boolToInt(shared), sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to set status message: %w", err)
+	}
+	
+	return nil
+}
+
+// SetLocation stores where the break of a session takes place
+func (r *SessionRepository) SetLocation(ctx context.Context, sessionID int64, latitude, longitude float64) error {
+	query := `
+		UPDATE sessions
+		SET latitude = ?, longitude = ?
+		WHERE id = ?
+	`
 	
+	_, err := r.db.GetDB().ExecContext(ctx, query, latitude, longitude, sessionID)
 	if err != nil {
-		return fmt.Errorf("failed to complete session: %w", err)
+		return fmt.Errorf("failed to set session location: %w", err)
 	}
 	
 	return nil
 }
 
+// GetInitiatorCounts counts sessions started per user since the given time,
+// ignoring cancelled sessions and hidden users
+func (r *SessionRepository) GetInitiatorCounts(ctx context.Context, since time.Time, limit int) ([]domain.LeaderboardEntry, error) {
+	query := `
+		SELECT s.initiator_id, COUNT(*) AS total
+		FROM sessions s
+		JOIN users u ON u.id = s.initiator_id
+		WHERE s.status != ? AND s.created_at >= ? AND u.is_hidden = 0
+		GROUP BY s.initiator_id
+		ORDER BY total DESC, MIN(s.created_at)
+		LIMIT ?
+	`
+	
+	rows, err := r.db.GetDB().QueryContext(ctx, query, domain.SessionStatusCancelled, since, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get initiator counts: %w", err)
+	}
+	defer rows.Close()
+	
+	var entries []domain.LeaderboardEntry
+	
+	for rows.Next() {
+		var entry domain.LeaderboardEntry
+		if err := rows.Scan(&entry.UserID, &entry.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan initiator count: %w", err)
+		}
+		
+		entries = append(entries, entry)
+	}
+	
+	return entries, nil
+}
+
+// GetAcceptedCounts counts sessions each user confirmed attending since the
+// given time, ignoring cancelled and failed sessions and hidden users. Ties
+// go to whoever joined first.
+func (r *SessionRepository) GetAcceptedCounts(ctx context.Context, since time.Time, limit int) ([]domain.LeaderboardEntry, error) {
+	query := `
+		SELECT sr.user_id, COUNT(*) AS total
+		FROM session_responses sr
+		JOIN users u ON u.id = sr.user_id
+		JOIN sessions s ON s.id = sr.session_id
+		WHERE sr.response IN (?, ?) AND sr.attended = 1 AND sr.created_at >= ? AND u.is_hidden = 0 AND s.status != ? AND s.failed = 0
+		GROUP BY sr.user_id
+		ORDER BY total DESC, MIN(sr.created_at)
+		LIMIT ?
+	`
+	
+	rows, err := r.db.GetDB().QueryContext(ctx, query,
+		domain.ResponseAccepted,
+		domain.ResponseAcceptedDelayed,
+		since,
+		domain.SessionStatusCancelled,
+		limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get accepted counts: %w", err)
+	}
+	defer rows.Close()
+	
+	var entries []domain.LeaderboardEntry
+	
+	for rows.Next() {
+		var entry domain.LeaderboardEntry
+		if err := rows.Scan(&entry.UserID, &entry.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan accepted count: %w", err)
+		}
+		
+		entries = append(entries, entry)
+	}
+	
+	return entries, nil
+}
+
 // AddResponse adds a user response to a session
-func (r *SessionRepository) AddResponse(response *domain.SessionResponse) error {
+func (r *SessionRepository) AddResponse(ctx context.Context, response *domain.SessionResponse) error {
+	return addResponse(ctx, r.db.GetDB(), response)
+}
+
+// addResponse inserts or replaces a response and sets its ID and time
+func addResponse(ctx context.Context, db execer, response *domain.SessionResponse) error {
 	query := `
-		INSERT INTO session_responses (session_id, user_id, response, created_at)
-		VALUES (?, ?, ?, ?)
-		ON CONFLICT(session_id, user_id) DO UPDATE SET response = ?, created_at = ?
+		INSERT INTO session_responses (session_id, user_id, response, option, created_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(session_id, user_id) DO UPDATE SET response = ?, option = ?, created_at = ?, reminder_sent = 0, attended = NULL
 	`
 	
 	now := time.Now()
-	result, err := r.db.GetDB().Exec(query,
+	result, err := db.ExecContext(ctx, query,
 		response.SessionID,
 		response.UserID,
 		response.Response,
+		response.Option,
 		now,
 		response.Response,
+		response.Option,
 		now,
 	)
 	
@@ -194,15 +507,15 @@ func (r *SessionRepository) AddResponse(response *domain.SessionResponse) error
 }
 
 // GetResponses retrieves all responses for a session
-func (r *SessionRepository) GetResponses(sessionID int64) ([]*domain.SessionResponse, error) {
+func (r *SessionRepository) GetResponses(ctx context.Context, sessionID int64) ([]*domain.SessionResponse, error) {
 	query := `
-		SELECT id, session_id, user_id, response, created_at
+		SELECT id, session_id, user_id, response, option, attended, created_at
 		FROM session_responses
 		WHERE session_id = ?
 		ORDER BY created_at
 	`
 	
-	rows, err := r.db.GetDB().Query(query, sessionID)
+	rows, err := r.db.GetDB().QueryContext(ctx, query, sessionID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get responses: %w", err)
 	}
@@ -211,15 +524,7 @@ func (r *SessionRepository) GetResponses(sessionID int64) ([]*domain.SessionResp
 	var responses []*domain.SessionResponse
 	
 	for rows.Next() {
-		response := &domain.SessionResponse{}
-		
-		err := rows.Scan(
-			&response.ID,
-			&response.SessionID,
-			&response.UserID,
-			&response.Response,
-			&response.CreatedAt,
-		)
+		response, err := scanResponse(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan response: %w", err)
 		}
@@ -231,22 +536,14 @@ func (r *SessionRepository) GetResponses(sessionID int64) ([]*domain.SessionResp
 }
 
 // GetUserResponse retrieves a specific user's response to a session
-func (r *SessionRepository) GetUserResponse(sessionID int64, userID int64) (*domain.SessionResponse, error) {
+func (r *SessionRepository) GetUserResponse(ctx context.Context, sessionID int64, userID int64) (*domain.SessionResponse, error) {
 	query := `
-		SELECT id, session_id, user_id, response, created_at
+		SELECT id, session_id, user_id, response, option, attended, created_at
 		FROM session_responses
 		WHERE session_id = ? AND user_id = ?
 	`
 	
-	response := &domain.SessionResponse{}
-	
-	err := r.db.GetDB().QueryRow(query, sessionID, userID).Scan(
-		&response.ID,
-		&response.SessionID,
-		&response.UserID,
-		&response.Response,
-		&response.CreatedAt,
-	)
+	response, err := scanResponse(r.db.GetDB().QueryRowContext(ctx, query, sessionID, userID))
 	
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -259,15 +556,15 @@ func (r *SessionRepository) GetUserResponse(sessionID int64, userID int64) (*dom
 }
 
 // UpdateResponse updates a user's response
-func (r *SessionRepository) UpdateResponse(response *domain.SessionResponse) error {
+func (r *SessionRepository) UpdateResponse(ctx context.Context, response *domain.SessionResponse) error {
 	query := `
 		UPDATE session_responses
-		SET response = ?, created_at = ?
+		SET response = ?, created_at = ?, attended = NULL
 		WHERE id = ?
 	`
 	
 	now := time.Now()
-	_, err := r.db.GetDB().Exec(query,
+	_, err := r.db.GetDB().ExecContext(ctx, query,
 		response.Response,
 		now,
 		response.ID,
@@ -282,3 +579,297 @@ func (r *SessionRepository) UpdateResponse(response *domain.SessionResponse) err
 	return nil
 }
 
+// CountUserResponses counts a user's responses per type since the given
+// time, ignoring cancelled sessions
+func (r *SessionRepository) CountUserResponses(ctx context.Context, userID int64, since time.Time) (map[domain.ResponseType]int, error) {
+	query := `
+		SELECT sr.response, COUNT(*)
+		FROM session_responses sr
+		JOIN sessions s ON s.id = sr.session_id
+		WHERE sr.user_id = ? AND sr.created_at >= ? AND s.status != ?
+		GROUP BY sr.response
+	`
+	
+	rows, err := r.db.GetDB().QueryContext(ctx, query, userID, since, domain.SessionStatusCancelled)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count user responses: %w", err)
+	}
+	defer rows.Close()
+	
+	counts := make(map[domain.ResponseType]int)
+	
+	for rows.Next() {
+		var response domain.ResponseType
+		var count int
+		if err := rows.Scan(&response, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan response count: %w", err)
+		}
+		counts[response] = count
+	}
+	
+	return counts, nil
+}
+
+// CountAttendance counts the sessions since the given time a user accepted
+// and confirmed attending, and those they accepted but haven't confirmed.
+// Cancelled sessions are ignored.
+func (r *SessionRepository) CountAttendance(ctx context.Context, userID int64, since time.Time) (attended, unconfirmed int, err error) {
+	query := `
+		SELECT
+			COUNT(CASE WHEN sr.attended = 1 THEN 1 END),
+			COUNT(CASE WHEN sr.attended IS NULL THEN 1 END)
+		FROM session_responses sr
+		JOIN sessions s ON s.id = sr.session_id
+		WHERE sr.user_id = ? AND sr.response IN (?, ?) AND sr.created_at >= ? AND s.status != ?
+	`
+	
+	err = r.db.GetDB().QueryRowContext(ctx, query,
+		userID,
+		domain.ResponseAccepted,
+		domain.ResponseAcceptedDelayed,
+		since,
+		domain.SessionStatusCancelled,
+	).Scan(&attended, &unconfirmed)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to count attendance: %w", err)
+	}
+	
+	return attended, unconfirmed, nil
+}
+
+// SetAttendance records whether a user actually came to a session
+func (r *SessionRepository) SetAttendance(ctx context.Context, sessionID int64, userID int64, attended bool) error {
+	query := `UPDATE session_responses SET attended = ? WHERE session_id = ? AND user_id = ?`
+	
+	if _, err := r.db.GetDB().ExecContext(ctx, query, attended, sessionID, userID); err != nil {
+		return fmt.Errorf("failed to set attendance: %w", err)
+	}
+	
+	return nil
+}
+
+// GetLastResponseTime returns when the latest response to a session was
+// given, or nil if nobody has responded yet
+func (r *SessionRepository) GetLastResponseTime(ctx context.Context, sessionID int64) (*time.Time, error) {
+	query := `
+		SELECT created_at
+		FROM session_responses
+		WHERE session_id = ?
+		ORDER BY created_at DESC
+		LIMIT 1
+	`
+	
+	var lastResponse time.Time
+	err := r.db.GetDB().QueryRowContext(ctx, query, sessionID).Scan(&lastResponse)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get last response time: %w", err)
+	}
+	
+	return &lastResponse, nil
+}
+
+// AddInvitation records that a user was invited to a session
+func (r *SessionRepository) AddInvitation(ctx context.Context, sessionID int64, userID int64) error {
+	query := `
+		INSERT INTO session_invitations (session_id, user_id, created_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT(session_id, user_id) DO NOTHING
+	`
+	
+	if _, err := r.db.GetDB().ExecContext(ctx, query, sessionID, userID, time.Now()); err != nil {
+		return fmt.Errorf("failed to add invitation: %w", err)
+	}
+	
+	return nil
+}
+
+// IsInvited reports whether a user was already invited to a session
+func (r *SessionRepository) IsInvited(ctx context.Context, sessionID int64, userID int64) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM session_invitations WHERE session_id = ? AND user_id = ?)`
+	
+	var invited bool
+	if err := r.db.GetDB().QueryRowContext(ctx, query, sessionID, userID).Scan(&invited); err != nil {
+		return false, fmt.Errorf("failed to check invitation: %w", err)
+	}
+	
+	return invited, nil
+}
+
+// CountInvitations counts invitations a user received since the given time
+func (r *SessionRepository) CountInvitations(ctx context.Context, userID int64, since time.Time) (int, error) {
+	query := `
+		SELECT COUNT(*)
+		FROM session_invitations
+		WHERE user_id = ? AND created_at >= ?
+	`
+	
+	var count int
+	if err := r.db.GetDB().QueryRowContext(ctx, query, userID, since).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count invitations: %w", err)
+	}
+	
+	return count, nil
+}
+
+// CountAnsweredInvitations counts invitations since the given time that the
+// user responded to
+func (r *SessionRepository) CountAnsweredInvitations(ctx context.Context, userID int64, since time.Time) (int, error) {
+	query := `
+		SELECT COUNT(*)
+		FROM session_invitations i
+		JOIN session_responses sr ON sr.session_id = i.session_id AND sr.user_id = i.user_id
+		WHERE i.user_id = ? AND i.created_at >= ?
+	`
+	
+	var count int
+	if err := r.db.GetDB().QueryRowContext(ctx, query, userID, since).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count answered invitations: %w", err)
+	}
+	
+	return count, nil
+}
+
+// MarkPoked records a reminder to a user who hasn't answered an invitation.
+// It reports false if the user was already poked in this session.
+func (r *SessionRepository) MarkPoked(ctx context.Context, sessionID int64, userID int64) (bool, error) {
+	query := `
+		INSERT INTO session_invitations (session_id, user_id, created_at, poked)
+		VALUES (?, ?, ?, 1)
+		ON CONFLICT(session_id, user_id) DO UPDATE SET poked = 1 WHERE poked = 0
+	`
+	
+	result, err := r.db.GetDB().ExecContext(ctx, query, sessionID, userID, time.Now())
+	if err != nil {
+		return false, fmt.Errorf("failed to mark poked: %w", err)
+	}
+	
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to check poke: %w", err)
+	}
+	
+	return affected > 0, nil
+}
+
+// GetDueDelayedResponses retrieves delayed responses in active sessions that
+// were given before the cutoff and haven't been reminded yet
+func (r *SessionRepository) GetDueDelayedResponses(ctx context.Context, respondedBefore time.Time) ([]*domain.SessionResponse, error) {
+	query := `
+		SELECT sr.id, sr.session_id, sr.user_id, sr.response, sr.option, sr.attended, sr.created_at
+		FROM session_responses sr
+		JOIN sessions s ON s.id = sr.session_id
+		WHERE s.status = ? AND sr.response = ? AND sr.reminder_sent = 0 AND sr.created_at <= ?
+		ORDER BY sr.created_at
+	`
+	
+	rows, err := r.db.GetDB().QueryContext(ctx, query,
+		domain.SessionStatusActive,
+		domain.ResponseAcceptedDelayed,
+		respondedBefore,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get due delayed responses: %w", err)
+	}
+	defer rows.Close()
+	
+	var responses []*domain.SessionResponse
+	
+	for rows.Next() {
+		response, err := scanResponse(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan response: %w", err)
+		}
+		
+		responses = append(responses, response)
+	}
+	
+	return responses, nil
+}
+
+// MarkReminderSent flags a delayed response as already reminded
+func (r *SessionRepository) MarkReminderSent(ctx context.Context, responseID int64) error {
+	query := `UPDATE session_responses SET reminder_sent = 1 WHERE id = ?`
+	
+	if _, err := r.db.GetDB().ExecContext(ctx, query, responseID); err != nil {
+		return fmt.Errorf("failed to mark reminder sent: %w", err)
+	}
+	
+	return nil
+}
+
+// scanResponse scans a row of session_responses columns into a SessionResponse
+func scanResponse(row rowScanner) (*domain.SessionResponse, error) {
+	response := &domain.SessionResponse{}
+	var attended sql.NullBool
+	
+	err := row.Scan(
+		&response.ID,
+		&response.SessionID,
+		&response.UserID,
+		&response.Response,
+		&response.Option,
+		&attended,
+		&response.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	
+	if attended.Valid {
+		response.Attended = &attended.Bool
+	}
+	
+	return response, nil
+}
+
+// scanSession scans a row selected with sessionColumns into a Session
+func scanSession(row rowScanner) (*domain.Session, error) {
+	session := &domain.Session{}
+	var completedAt sql.NullTime
+	var latitude, longitude sql.NullFloat64
+	var failed int
+	var shared int
+	var options string
+	
+	err := row.Scan(
+		&session.ID,
+		&session.InitiatorID,
+		&session.ChatID,
+		&session.TimeoutMinutes,
+		&session.Note,
+		&session.StatusChatID,
+		&session.StatusMessageID,
+		&shared,
+		&latitude,
+		&longitude,
+		&session.Status,
+		&failed,
+		&session.CancelReason,
+		&options,
+		&session.CreatedAt,
+		&completedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	
+	session.Failed = intToBool(failed)
+	session.Shared = intToBool(shared)
+	if options != "" {
+		session.Options = strings.Split(options, optionSeparator)
+	}
+	
+	if completedAt.Valid {
+		session.CompletedAt = &completedAt.Time
+	}
+	
+	if latitude.Valid && longitude.Valid {
+		session.Latitude = &latitude.Float64
+		session.Longitude = &longitude.Float64
+	}
+	
+	return session, nil
+}
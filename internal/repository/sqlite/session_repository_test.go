@@ -0,0 +1,127 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/glebk/smoke-bot/internal/domain"
+)
+
+// newTestRepositories opens a test database with a few users in it
+func newTestRepositories(t *testing.T) (*UserRepository, *SessionRepository) {
+	t.Helper()
+
+	db := newTestDB(t)
+	users := NewUserRepository(db)
+	createUsers(t, users, 1, 2, 3)
+
+	return users, NewSessionRepository(db)
+}
+
+func TestAddResponseReplacesEarlierResponse(t *testing.T) {
+	ctx := context.Background()
+	_, sessions := newTestRepositories(t)
+
+	session := &domain.Session{InitiatorID: 1, ChatID: 1, Status: domain.SessionStatusActive}
+	if err := sessions.Create(ctx, session); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	accepted := &domain.SessionResponse{SessionID: session.ID, UserID: 2, Response: domain.ResponseAccepted}
+	if err := sessions.AddResponse(ctx, accepted); err != nil {
+		t.Fatalf("AddResponse: %v", err)
+	}
+	if err := sessions.SetAttendance(ctx, session.ID, 2, true); err != nil {
+		t.Fatalf("SetAttendance: %v", err)
+	}
+
+	denied := &domain.SessionResponse{SessionID: session.ID, UserID: 2, Response: domain.ResponseDenied}
+	if err := sessions.AddResponse(ctx, denied); err != nil {
+		t.Fatalf("AddResponse: %v", err)
+	}
+
+	responses, err := sessions.GetResponses(ctx, session.ID)
+	if err != nil {
+		t.Fatalf("GetResponses: %v", err)
+	}
+	if len(responses) != 1 {
+		t.Fatalf("got %d responses, want the second to replace the first", len(responses))
+	}
+	if responses[0].Response != domain.ResponseDenied {
+		t.Errorf("response = %s, want %s", responses[0].Response, domain.ResponseDenied)
+	}
+	if responses[0].Attended != nil {
+		t.Errorf("attendance = %v, want it reset by the new response", *responses[0].Attended)
+	}
+}
+
+func TestCreateRefusesSecondActiveSession(t *testing.T) {
+	tests := []struct {
+		name       string
+		firstChat  int64
+		secondChat int64
+		wantErr    error
+	}{
+		{"same group", -100, -100, domain.ErrActiveSessionExists},
+		{"another group", -100, -200, nil},
+		{"another private chat", 1, 2, domain.ErrActiveSessionExists},
+		{"group and private chat", -100, 1, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			_, sessions := newTestRepositories(t)
+
+			first := &domain.Session{InitiatorID: 1, ChatID: tt.firstChat, Status: domain.SessionStatusActive}
+			if err := sessions.Create(ctx, first); err != nil {
+				t.Fatalf("first Create: %v", err)
+			}
+
+			second := &domain.Session{InitiatorID: 2, ChatID: tt.secondChat, Status: domain.SessionStatusActive}
+			if err := sessions.Create(ctx, second); !errors.Is(err, tt.wantErr) {
+				t.Errorf("second Create error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCreateWithResponse(t *testing.T) {
+	ctx := context.Background()
+	_, sessions := newTestRepositories(t)
+
+	session := &domain.Session{InitiatorID: 1, ChatID: 1, Status: domain.SessionStatusActive}
+	response := &domain.SessionResponse{UserID: 1, Response: domain.ResponseAccepted}
+	if err := sessions.CreateWithResponse(ctx, session, response); err != nil {
+		t.Fatalf("CreateWithResponse: %v", err)
+	}
+
+	responses, err := sessions.GetResponses(ctx, session.ID)
+	if err != nil {
+		t.Fatalf("GetResponses: %v", err)
+	}
+	if len(responses) != 1 || responses[0].UserID != 1 || responses[0].ID != response.ID {
+		t.Errorf("responses = %+v, want the initiator's", responses)
+	}
+}
+
+func TestCreateWithResponseRollsBackOnFailure(t *testing.T) {
+	ctx := context.Background()
+	_, sessions := newTestRepositories(t)
+
+	// User 99 doesn't exist, so the response violates its foreign key
+	session := &domain.Session{InitiatorID: 1, ChatID: 1, Status: domain.SessionStatusActive}
+	response := &domain.SessionResponse{UserID: 99, Response: domain.ResponseAccepted}
+	if err := sessions.CreateWithResponse(ctx, session, response); err == nil {
+		t.Fatal("CreateWithResponse succeeded with an unknown user")
+	}
+
+	stored, err := sessions.GetByID(ctx, session.ID)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if stored != nil {
+		t.Errorf("session #%d was kept after the response failed", stored.ID)
+	}
+}
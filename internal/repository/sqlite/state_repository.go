@@ -0,0 +1,50 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// StateRepository implements domain.StateRepository using SQLite
+type StateRepository struct {
+	db *Database
+}
+
+// NewStateRepository creates a new StateRepository
+func NewStateRepository(db *Database) *StateRepository {
+	return &StateRepository{db: db}
+}
+
+// Get returns the value stored under key, or an empty string if none
+func (r *StateRepository) Get(ctx context.Context, key string) (string, error) {
+	query := `SELECT value FROM bot_state WHERE key = ?`
+
+	var value string
+	err := r.db.GetDB().QueryRowContext(ctx, query, key).Scan(&value)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get bot state: %w", err)
+	}
+
+	return value, nil
+}
+
+// Set stores a value under key, replacing the previous one
+func (r *StateRepository) Set(ctx context.Context, key, value string) error {
+	query := `
+		INSERT INTO bot_state (key, value, updated_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT(key) DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at
+	`
+
+	_, err := r.db.GetDB().ExecContext(ctx, query, key, value, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to set bot state: %w", err)
+	}
+
+	return nil
+}
@@ -1,6 +1,7 @@
 package sqlite
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"time"
@@ -8,6 +9,14 @@ import (
 	"github.com/glebk/smoke-bot/internal/domain"
 )
 
+// userColumns lists the users table columns in the order scanUser expects
+const userColumns = `id, username, first_name, last_name, is_remote_today, remote_until, is_hidden, skip_own_summary, approval_status, weekly_summary, weekly_summary_week, terse_replies, timezone, delay_minutes, is_muted, digest_week, quiet_from, quiet_to, language, display_name, is_unreachable, is_deleted, created_at, updated_at`
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
 // UserRepository implements domain.UserRepository using SQLite
 type UserRepository struct {
 	db *Database
@@ -19,20 +28,15 @@ func NewUserRepository(db *Database) *UserRepository {
 }
 
 // Create creates a new user
-func (r *UserRepository) Create(user *domain.User) error {
+func (r *UserRepository) Create(ctx context.Context, user *domain.User) error {
 	query := `
-		INSERT INTO users (id, username, first_name, last_name, is_remote_today, remote_until, is_hidden, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		INSERT INTO users (id, username, first_name, last_name, is_remote_today, remote_until, is_hidden, skip_own_summary, approval_status, weekly_summary, weekly_summary_week, terse_replies, timezone, delay_minutes, is_muted, digest_week, quiet_from, quiet_to, language, display_name, is_unreachable, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
 	now := time.Now()
 
-	// Auto-hide user "eyerise"
-	if user.Username == "eyerise" {
-		user.IsHidden = true
-	}
-
-	_, err := r.db.GetDB().Exec(query,
+	_, err := r.db.GetDB().ExecContext(ctx, query,
 		user.ID,
 		user.Username,
 		user.FirstName,
@@ -40,6 +44,20 @@ func (r *UserRepository) Create(user *domain.User) error {
 		boolToInt(user.IsRemoteToday),
 		user.RemoteUntil,
 		boolToInt(user.IsHidden),
+		boolToInt(user.SkipOwnSummary),
+		user.Approval,
+		boolToInt(user.WeeklySummary),
+		user.WeeklySummaryWeek,
+		boolToInt(user.TerseReplies),
+		user.Timezone,
+		user.DelayMinutes,
+		boolToInt(user.IsMuted),
+		user.DigestWeek,
+		user.QuietFrom,
+		user.QuietTo,
+		user.Language,
+		user.DisplayName,
+		boolToInt(user.IsUnreachable),
 		now,
 		now,
 	)
@@ -55,31 +73,10 @@ func (r *UserRepository) Create(user *domain.User) error {
 }
 
 // GetByID retrieves a user by ID
-func (r *UserRepository) GetByID(id int64) (*domain.User, error) {
-	query := `
-		SELECT id, username, first_name, last_name, is_remote_today, remote_until, is_hidden, created_at, updated_at
-		FROM users
-		WHERE id = ?
-	`
-
-	user := &domain.User{}
-	var isRemote int
-	var isHidden int
-	var remoteUntil sql.NullTime
-	var lastName sql.NullString
-
-	err := r.db.GetDB().QueryRow(query, id).Scan(
-		&user.ID,
-		&user.Username,
-		&user.FirstName,
-		&lastName,
-		&isRemote,
-		&remoteUntil,
-		&isHidden,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
+func (r *UserRepository) GetByID(ctx context.Context, id int64) (*domain.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE id = ?`
 
+	user, err := scanUser(r.db.GetDB().QueryRowContext(ctx, query, id))
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -87,27 +84,43 @@ func (r *UserRepository) GetByID(id int64) (*domain.User, error) {
 		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
 
-	user.IsRemoteToday = intToBool(isRemote)
-	user.IsHidden = intToBool(isHidden)
-	if remoteUntil.Valid {
-		user.RemoteUntil = &remoteUntil.Time
+	return user, nil
+}
+
+// GetByUsername retrieves a user by their Telegram username, ignoring case.
+// It returns nil if there is no such user.
+func (r *UserRepository) GetByUsername(ctx context.Context, username string) (*domain.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE username = ? COLLATE NOCASE LIMIT 1`
+
+	user, err := scanUser(r.db.GetDB().QueryRowContext(ctx, query, username))
+	if err == sql.ErrNoRows {
+		return nil, nil
 	}
-	if lastName.Valid {
-		user.LastName = lastName.String
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user by username: %w", err)
 	}
 
 	return user, nil
 }
 
-// GetAll retrieves all users
-func (r *UserRepository) GetAll() ([]*domain.User, error) {
-	query := `
-		SELECT id, username, first_name, last_name, is_remote_today, remote_until, is_hidden, created_at, updated_at
-		FROM users
-		ORDER BY username
-	`
+// ReleaseUsername clears a username, ignoring case, from every user but
+// exceptID
+func (r *UserRepository) ReleaseUsername(ctx context.Context, username string, exceptID int64) error {
+	query := `UPDATE users SET username = '', updated_at = ? WHERE username = ? COLLATE NOCASE AND id != ?`
 
-	rows, err := r.db.GetDB().Query(query)
+	_, err := r.db.GetDB().ExecContext(ctx, query, time.Now(), username, exceptID)
+	if err != nil {
+		return fmt.Errorf("failed to release username: %w", err)
+	}
+
+	return nil
+}
+
+// GetAll retrieves all users that haven't been deleted
+func (r *UserRepository) GetAll(ctx context.Context) ([]*domain.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE is_deleted = 0 ORDER BY username`
+
+	rows, err := r.db.GetDB().QueryContext(ctx, query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get all users: %w", err)
 	}
@@ -116,36 +129,11 @@ func (r *UserRepository) GetAll() ([]*domain.User, error) {
 	var users []*domain.User
 
 	for rows.Next() {
-		user := &domain.User{}
-		var isRemote int
-		var isHidden int
-		var remoteUntil sql.NullTime
-		var lastName sql.NullString
-
-		err := rows.Scan(
-			&user.ID,
-			&user.Username,
-			&user.FirstName,
-			&lastName,
-			&isRemote,
-			&remoteUntil,
-			&isHidden,
-			&user.CreatedAt,
-			&user.UpdatedAt,
-		)
+		user, err := scanUser(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan user: %w", err)
 		}
 
-		user.IsRemoteToday = intToBool(isRemote)
-		user.IsHidden = intToBool(isHidden)
-		if remoteUntil.Valid {
-			user.RemoteUntil = &remoteUntil.Time
-		}
-		if lastName.Valid {
-			user.LastName = lastName.String
-		}
-
 		users = append(users, user)
 	}
 
@@ -153,26 +141,35 @@ func (r *UserRepository) GetAll() ([]*domain.User, error) {
 }
 
 // Update updates a user
-func (r *UserRepository) Update(user *domain.User) error {
+func (r *UserRepository) Update(ctx context.Context, user *domain.User) error {
 	query := `
 		UPDATE users
-		SET username = ?, first_name = ?, last_name = ?, is_remote_today = ?, remote_until = ?, is_hidden = ?, updated_at = ?
+		SET username = ?, first_name = ?, last_name = ?, is_remote_today = ?, remote_until = ?, is_hidden = ?, skip_own_summary = ?, approval_status = ?, weekly_summary = ?, weekly_summary_week = ?, terse_replies = ?, timezone = ?, delay_minutes = ?, is_muted = ?, digest_week = ?, quiet_from = ?, quiet_to = ?, language = ?, display_name = ?, is_unreachable = ?, updated_at = ?
 		WHERE id = ?
 	`
 
-	// Auto-hide user "eyerise"
-	if user.Username == "eyerise" {
-		user.IsHidden = true
-	}
-
 	now := time.Now()
-	_, err := r.db.GetDB().Exec(query,
+	_, err := r.db.GetDB().ExecContext(ctx, query,
 		user.Username,
 		user.FirstName,
 		user.LastName,
 		boolToInt(user.IsRemoteToday),
 		user.RemoteUntil,
 		boolToInt(user.IsHidden),
+		boolToInt(user.SkipOwnSummary),
+		user.Approval,
+		boolToInt(user.WeeklySummary),
+		user.WeeklySummaryWeek,
+		boolToInt(user.TerseReplies),
+		user.Timezone,
+		user.DelayMinutes,
+		boolToInt(user.IsMuted),
+		user.DigestWeek,
+		user.QuietFrom,
+		user.QuietTo,
+		user.Language,
+		user.DisplayName,
+		boolToInt(user.IsUnreachable),
 		now,
 		user.ID,
 	)
@@ -186,11 +183,12 @@ func (r *UserRepository) Update(user *domain.User) error {
 	return nil
 }
 
-// Delete deletes a user
-func (r *UserRepository) Delete(id int64) error {
-	query := `DELETE FROM users WHERE id = ?`
+// Delete marks a user as deleted. The row is kept so their sessions and
+// responses still count in the history, and GetByID still finds them.
+func (r *UserRepository) Delete(ctx context.Context, id int64) error {
+	query := `UPDATE users SET is_deleted = 1, updated_at = ? WHERE id = ?`
 
-	_, err := r.db.GetDB().Exec(query, id)
+	_, err := r.db.GetDB().ExecContext(ctx, query, time.Now(), id)
 	if err != nil {
 		return fmt.Errorf("failed to delete user: %w", err)
 	}
@@ -198,15 +196,27 @@ func (r *UserRepository) Delete(id int64) error {
 	return nil
 }
 
+// Restore brings back a deleted user
+func (r *UserRepository) Restore(ctx context.Context, id int64) error {
+	query := `UPDATE users SET is_deleted = 0, updated_at = ? WHERE id = ?`
+
+	_, err := r.db.GetDB().ExecContext(ctx, query, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("failed to restore user: %w", err)
+	}
+
+	return nil
+}
+
 // SetRemoteStatus sets the remote status for a user
-func (r *UserRepository) SetRemoteStatus(userID int64, until time.Time) error {
+func (r *UserRepository) SetRemoteStatus(ctx context.Context, userID int64, until time.Time) error {
 	query := `
 		UPDATE users
 		SET is_remote_today = 1, remote_until = ?, updated_at = ?
 		WHERE id = ?
 	`
 
-	_, err := r.db.GetDB().Exec(query, until, time.Now(), userID)
+	_, err := r.db.GetDB().ExecContext(ctx, query, until, time.Now(), userID)
 	if err != nil {
 		return fmt.Errorf("failed to set remote status: %w", err)
 	}
@@ -214,8 +224,56 @@ func (r *UserRepository) SetRemoteStatus(userID int64, until time.Time) error {
 	return nil
 }
 
+// SetTimezone sets the IANA timezone used for a user's working hours
+func (r *UserRepository) SetTimezone(ctx context.Context, userID int64, tz string) error {
+	query := `
+		UPDATE users
+		SET timezone = ?, updated_at = ?
+		WHERE id = ?
+	`
+
+	_, err := r.db.GetDB().ExecContext(ctx, query, tz, time.Now(), userID)
+	if err != nil {
+		return fmt.Errorf("failed to set timezone: %w", err)
+	}
+
+	return nil
+}
+
+// SetWeeklySummaryWeek records the last week a user got their weekly summary for
+func (r *UserRepository) SetWeeklySummaryWeek(ctx context.Context, userID int64, week string) error {
+	query := `
+		UPDATE users
+		SET weekly_summary_week = ?, updated_at = ?
+		WHERE id = ?
+	`
+
+	_, err := r.db.GetDB().ExecContext(ctx, query, week, time.Now(), userID)
+	if err != nil {
+		return fmt.Errorf("failed to set weekly summary week: %w", err)
+	}
+
+	return nil
+}
+
+// SetDigestWeek records the last week a user got the team digest for
+func (r *UserRepository) SetDigestWeek(ctx context.Context, userID int64, week string) error {
+	query := `
+		UPDATE users
+		SET digest_week = ?, updated_at = ?
+		WHERE id = ?
+	`
+
+	_, err := r.db.GetDB().ExecContext(ctx, query, week, time.Now(), userID)
+	if err != nil {
+		return fmt.Errorf("failed to set digest week: %w", err)
+	}
+
+	return nil
+}
+
 // ClearExpiredRemoteStatus clears remote status for users where the time has expired
-func (r *UserRepository) ClearExpiredRemoteStatus() error {
+func (r *UserRepository) ClearExpiredRemoteStatus(ctx context.Context) error {
 	query := `
 		UPDATE users
 		SET is_remote_today = 0, remote_until = NULL, updated_at = ?
@@ -223,7 +281,7 @@ func (r *UserRepository) ClearExpiredRemoteStatus() error {
 	`
 
 	now := time.Now()
-	_, err := r.db.GetDB().Exec(query, now, now)
+	_, err := r.db.GetDB().ExecContext(ctx, query, now, now)
 	if err != nil {
 		return fmt.Errorf("failed to clear expired remote status: %w", err)
 	}
@@ -231,6 +289,90 @@ func (r *UserRepository) ClearExpiredRemoteStatus() error {
 	return nil
 }
 
+// ClearAllRemoteStatus clears remote status for every user and returns how
+// many users were affected
+func (r *UserRepository) ClearAllRemoteStatus(ctx context.Context) (int64, error) {
+	query := `
+		UPDATE users
+		SET is_remote_today = 0, remote_until = NULL, updated_at = ?
+		WHERE is_remote_today = 1
+	`
+
+	result, err := r.db.GetDB().ExecContext(ctx, query, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("failed to clear remote status: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count cleared users: %w", err)
+	}
+
+	return affected, nil
+}
+
+// scanUser scans a row selected with userColumns into a User
+func scanUser(row rowScanner) (*domain.User, error) {
+	user := &domain.User{}
+	var isRemote int
+	var isHidden int
+	var skipOwnSummary int
+	var weeklySummary int
+	var terseReplies int
+	var isMuted int
+	var isUnreachable int
+	var isDeleted int
+	var remoteUntil sql.NullTime
+	var lastName sql.NullString
+
+	err := row.Scan(
+		&user.ID,
+		&user.Username,
+		&user.FirstName,
+		&lastName,
+		&isRemote,
+		&remoteUntil,
+		&isHidden,
+		&skipOwnSummary,
+		&user.Approval,
+		&weeklySummary,
+		&user.WeeklySummaryWeek,
+		&terseReplies,
+		&user.Timezone,
+		&user.DelayMinutes,
+		&isMuted,
+		&user.DigestWeek,
+		&user.QuietFrom,
+		&user.QuietTo,
+		&user.Language,
+		&user.DisplayName,
+		&isUnreachable,
+		&isDeleted,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	user.IsRemoteToday = intToBool(isRemote)
+	user.IsHidden = intToBool(isHidden)
+	user.SkipOwnSummary = intToBool(skipOwnSummary)
+	user.WeeklySummary = intToBool(weeklySummary)
+	user.TerseReplies = intToBool(terseReplies)
+	user.IsMuted = intToBool(isMuted)
+	user.IsUnreachable = intToBool(isUnreachable)
+	user.IsDeleted = intToBool(isDeleted)
+	if remoteUntil.Valid {
+		user.RemoteUntil = &remoteUntil.Time
+	}
+	if lastName.Valid {
+		user.LastName = lastName.String
+	}
+
+	return user, nil
+}
+
 // Helper functions
 func boolToInt(b bool) int {
 	if b {
@@ -0,0 +1,73 @@
+package sqlite
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestClearExpiredRemoteStatus(t *testing.T) {
+	ctx := context.Background()
+	repo := NewUserRepository(newTestDB(t))
+	createUsers(t, repo, 1, 2, 3)
+
+	if err := repo.SetRemoteStatus(ctx, 1, time.Now().Add(-time.Minute)); err != nil {
+		t.Fatalf("SetRemoteStatus: %v", err)
+	}
+	if err := repo.SetRemoteStatus(ctx, 2, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("SetRemoteStatus: %v", err)
+	}
+
+	if err := repo.ClearExpiredRemoteStatus(ctx); err != nil {
+		t.Fatalf("ClearExpiredRemoteStatus: %v", err)
+	}
+
+	tests := []struct {
+		id         int64
+		wantRemote bool
+	}{
+		{1, false}, // expired
+		{2, true},  // still remote
+		{3, false}, // never remote
+	}
+
+	for _, tt := range tests {
+		user, err := repo.GetByID(ctx, tt.id)
+		if err != nil {
+			t.Fatalf("GetByID(%d): %v", tt.id, err)
+		}
+		if user.IsRemoteToday != tt.wantRemote {
+			t.Errorf("user %d remote = %v, want %v", tt.id, user.IsRemoteToday, tt.wantRemote)
+		}
+		if !tt.wantRemote && user.RemoteUntil != nil {
+			t.Errorf("user %d still has remote_until %s", tt.id, user.RemoteUntil)
+		}
+	}
+}
+
+func TestSetDigestWeekKeepsOtherFields(t *testing.T) {
+	ctx := context.Background()
+	repo := NewUserRepository(newTestDB(t))
+	createUsers(t, repo, 1)
+
+	if err := repo.SetRemoteStatus(ctx, 1, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("SetRemoteStatus: %v", err)
+	}
+	if err := repo.SetDigestWeek(ctx, 1, "2024-W10"); err != nil {
+		t.Fatalf("SetDigestWeek: %v", err)
+	}
+	if err := repo.SetWeeklySummaryWeek(ctx, 1, "2024-W11"); err != nil {
+		t.Fatalf("SetWeeklySummaryWeek: %v", err)
+	}
+
+	user, err := repo.GetByID(ctx, 1)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if user.DigestWeek != "2024-W10" || user.WeeklySummaryWeek != "2024-W11" {
+		t.Errorf("weeks = %q, %q, want 2024-W10, 2024-W11", user.DigestWeek, user.WeeklySummaryWeek)
+	}
+	if !user.IsRemoteToday {
+		t.Error("setting the weeks cleared the remote status")
+	}
+}
@@ -1,23 +1,143 @@
 package service
 
 import (
+	"context"
+	"encoding/csv"
+	"errors"
 	"fmt"
+	"io"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/glebk/smoke-bot/internal/domain"
+	"github.com/glebk/smoke-bot/internal/locale"
+	"github.com/glebk/smoke-bot/internal/metrics"
 )
 
+// Bounds and default for how many minutes a user who answered "later" takes
+// to arrive
+const (
+	DefaultDelayMinutes = 5
+	MinDelayMinutes     = 1
+	MaxDelayMinutes     = 15
+)
+
+// SystemUserID is the initiator of sessions the bot starts on its own
+const SystemUserID int64 = 0
+
+// Bounds for a session lifetime the initiator may ask for
+const (
+	MinSessionTimeoutMinutes = 1
+	MaxSessionTimeoutMinutes = 60
+)
+
+// ExtendGracePeriod is how long after completion a session may still be
+// reopened by its initiator
+const ExtendGracePeriod = 5 * time.Minute
+
+// MaxNoteLength limits how many characters of the initiator's note are kept
+const MaxNoteLength = 100
+
+// MaxDisplayNameLength limits how many characters of a nickname are kept
+const MaxDisplayNameLength = 32
+
+// MaxCancelReasonLength limits how many characters of a cancellation reason
+// are kept
+const MaxCancelReasonLength = 100
+
+// Bounds for the options of a vote
+const (
+	MinVoteOptions      = 2
+	MaxVoteOptions      = 8
+	MaxVoteOptionLength = 40
+)
+
+// queryTimeout bounds how long a service call may wait on the repositories,
+// so a stuck database lock can't block the bot forever
+const queryTimeout = 5 * time.Second
+
+// queryContext returns a context for the repository calls of one service call.
+// Calls that loop over sessions take a fresh one for every query in the loop,
+// so the timeout doesn't grow into a limit on the number of sessions.
+func queryContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), queryTimeout)
+}
+
 // SmokeService handles business logic for smoking sessions
 type SmokeService struct {
 	userRepo    domain.UserRepository
 	sessionRepo domain.SessionRepository
+	chatRepo    domain.ChatRepository
+	stateRepo   domain.StateRepository
+
+	// sessionTimeout is the lifetime of sessions started without one
+	sessionTimeout time.Duration
+
+	// startCooldown is how long a user must wait between starting sessions
+	startCooldown time.Duration
+
+	// location is the timezone the working day is counted in
+	location *time.Location
+
+	// waitForInvitees keeps sessions nobody can be invited to
+	waitForInvitees bool
+
+	// now returns the current time; tests replace it to control the clock
+	now func() time.Time
+}
+
+// Errors returned by the service that callers can check with errors.Is
+var (
+	// ErrActiveSessionExists is returned when a chat already has an active session
+	ErrActiveSessionExists = domain.ErrActiveSessionExists
+
+	// ErrNoActiveUsers is returned when nobody could be invited to a new session
+	ErrNoActiveUsers = errors.New("no active users to invite")
+
+	// ErrNoActiveSession is returned when a chat has no active session to act on
+	ErrNoActiveSession = errors.New("no active session")
+
+	// ErrNotInitiator is returned when someone other than the initiator
+	// tries to change a session
+	ErrNotInitiator = errors.New("only the initiator can change the session")
+
+	// ErrInvalidVoteOptions is returned when a vote has too few or too many
+	// distinct options
+	ErrInvalidVoteOptions = errors.New("invalid vote options")
+
+	// ErrVoteSession is returned when a vote is answered like an invitation
+	// instead of by choosing an option
+	ErrVoteSession = errors.New("the session is a vote")
+)
+
+// CooldownError is returned when a user starts sessions too often
+type CooldownError struct {
+	Remaining time.Duration
 }
 
-// NewSmokeService creates a new SmokeService
-func NewSmokeService(userRepo domain.UserRepository, sessionRepo domain.SessionRepository) *SmokeService {
+func (e *CooldownError) Error() string {
+	return fmt.Sprintf("session started too recently, wait %s", e.Remaining)
+}
+
+// NewSmokeService creates a new SmokeService. Unless waitForInvitees is set,
+// sessions nobody could be invited to are refused with ErrNoActiveUsers.
+// The start cooldown only counts sessions started the same day in location.
+func NewSmokeService(userRepo domain.UserRepository, sessionRepo domain.SessionRepository, chatRepo domain.ChatRepository, stateRepo domain.StateRepository, sessionTimeout, startCooldown time.Duration, location *time.Location, waitForInvitees bool) *SmokeService {
+	if location == nil {
+		location = time.Local
+	}
+
 	service := &SmokeService{
-		userRepo:    userRepo,
-		sessionRepo: sessionRepo,
+		userRepo:        userRepo,
+		sessionRepo:     sessionRepo,
+		chatRepo:        chatRepo,
+		stateRepo:       stateRepo,
+		sessionTimeout:  sessionTimeout,
+		startCooldown:   startCooldown,
+		location:        location,
+		waitForInvitees: waitForInvitees,
+		now:             time.Now,
 	}
 
 	// Clean up any old active sessions from previous runs
@@ -26,42 +146,176 @@ func NewSmokeService(userRepo domain.UserRepository, sessionRepo domain.SessionR
 	return service
 }
 
-// CleanupOldSessions completes any active sessions older than 1 hour
+// CleanupOldSessions completes any active sessions that outlived their
+// timeout while the bot was down
 func (s *SmokeService) CleanupOldSessions() {
-	session, err := s.sessionRepo.GetActiveSession()
-	if err != nil || session == nil {
+	ctx, cancel := queryContext()
+	defer cancel()
+
+	sessions, err := s.sessionRepo.GetAllActiveSessions(ctx)
+	if err != nil {
 		return
 	}
 
-	// If session is older than 1 hour, complete it
-	if time.Since(session.CreatedAt) > time.Hour {
-		_ = s.CompleteSession(session.ID)
+	for _, session := range sessions {
+		if s.now().Sub(session.CreatedAt) > session.Timeout() {
+			_ = s.CompleteSession(session.ID)
+		}
 	}
 }
 
-// AutoCompleteOldSessions automatically completes sessions older than 15 minutes
-func (s *SmokeService) AutoCompleteOldSessions() (*domain.Session, error) {
-	session, err := s.sessionRepo.GetActiveSession()
-	if err != nil || session == nil {
+// AutoCompleteOldSessions automatically completes sessions older than their
+// timeout and returns them. A non-zero inactivity also completes sessions
+// that got no new responses for that long, whichever comes first. With a
+// non-zero minParticipants, sessions fewer colleagues accepted are marked
+// failed.
+func (s *SmokeService) AutoCompleteOldSessions(inactivity time.Duration, minParticipants int) ([]*domain.Session, error) {
+	ctx, cancel := queryContext()
+	defer cancel()
+
+	sessions, err := s.sessionRepo.GetAllActiveSessions(ctx)
+	if err != nil {
 		return nil, err
 	}
 
-	// If session is older than 15 minutes, complete it
-	if time.Since(session.CreatedAt) > 15*time.Minute {
-		if err := s.CompleteSession(session.ID); err != nil {
-			return nil, err
+	var completed []*domain.Session
+	for _, session := range sessions {
+		expired, err := s.sessionExpired(session, inactivity)
+		if err != nil {
+			return completed, err
+		}
+		if !expired {
+			continue
+		}
+
+		failed, err := s.tooFewParticipants(ctx, session, minParticipants)
+		if err != nil {
+			return completed, err
+		}
+
+		if err := s.completeSession(session.ID, failed); err != nil {
+			return completed, err
+		}
+		session.Failed = failed
+		completed = append(completed, session)
+	}
+
+	return completed, nil
+}
+
+// tooFewParticipants reports whether fewer than minParticipants people
+// besides the initiator accepted a session. A zero minParticipants never
+// fails a session, and neither does a vote, as nobody accepts one.
+func (s *SmokeService) tooFewParticipants(ctx context.Context, session *domain.Session, minParticipants int) (bool, error) {
+	if minParticipants <= 0 || session.IsVote() {
+		return false, nil
+	}
+
+	responses, err := s.sessionRepo.GetResponses(ctx, session.ID)
+	if err != nil {
+		return false, fmt.Errorf("failed to get responses: %w", err)
+	}
+
+	accepted := 0
+	for _, resp := range responses {
+		if resp.UserID != session.InitiatorID && resp.IsAcceptance() {
+			accepted++
+		}
+	}
+
+	return accepted < minParticipants, nil
+}
+
+// sessionExpired reports whether a session ran out of time or, with a
+// non-zero inactivity, went without responses for that long. A session never
+// expires while a delayed participant is still on their way.
+func (s *SmokeService) sessionExpired(session *domain.Session, inactivity time.Duration) (bool, error) {
+	ctx, cancel := queryContext()
+	defer cancel()
+
+	expired := s.now().Sub(session.CreatedAt) > session.Timeout()
+
+	if !expired && inactivity > 0 {
+		lastActivity := session.CreatedAt
+		lastResponse, err := s.sessionRepo.GetLastResponseTime(ctx, session.ID)
+		if err != nil {
+			return false, err
+		}
+		if lastResponse != nil && lastResponse.After(lastActivity) {
+			lastActivity = *lastResponse
+		}
+
+		expired = s.now().Sub(lastActivity) > inactivity
+	}
+
+	if !expired {
+		return false, nil
+	}
+
+	arriving, err := s.hasArrivingParticipants(session.ID)
+	if err != nil {
+		return false, err
+	}
+
+	return !arriving, nil
+}
+
+// hasArrivingParticipants reports whether someone who answered "later" is
+// still within their delay
+func (s *SmokeService) hasArrivingParticipants(sessionID int64) (bool, error) {
+	ctx, cancel := queryContext()
+	defer cancel()
+
+	responses, err := s.sessionRepo.GetResponses(ctx, sessionID)
+	if err != nil {
+		return false, fmt.Errorf("failed to get responses: %w", err)
+	}
+
+	for _, resp := range responses {
+		if resp.Response != domain.ResponseAcceptedDelayed {
+			continue
+		}
+
+		user, err := s.userRepo.GetByID(ctx, resp.UserID)
+		if err != nil || user == nil {
+			continue
+		}
+
+		if s.now().Before(resp.CreatedAt.Add(user.Delay())) {
+			return true, nil
 		}
-		return session, nil
 	}
 
-	return nil, nil
+	return false, nil
 }
 
-// RegisterUser registers a new user or updates existing one
-func (s *SmokeService) RegisterUser(id int64, username, firstName, lastName string) error {
-	existingUser, err := s.userRepo.GetByID(id)
+// RegisterUser registers a new user or updates existing one. It reports
+// whether the user was new.
+func (s *SmokeService) RegisterUser(id int64, username, firstName, lastName string) (bool, error) {
+	ctx, cancel := queryContext()
+	defer cancel()
+
+	username = normalizeUsername(username)
+
+	existingUser, err := s.userRepo.GetByID(ctx, id)
 	if err != nil {
-		return fmt.Errorf("failed to check user: %w", err)
+		return false, fmt.Errorf("failed to check user: %w", err)
+	}
+
+	// Nothing to store for a user seen before with the same profile, unless
+	// they were unreachable: writing to the bot again means they unblocked it
+	if existingUser != nil && existingUser.Username == username &&
+		existingUser.FirstName == firstName && existingUser.LastName == lastName &&
+		!existingUser.IsUnreachable {
+		return false, nil
+	}
+
+	// Whoever had this username before has since renamed themselves, so it
+	// must only point to this user
+	if username != "" && (existingUser == nil || !strings.EqualFold(existingUser.Username, username)) {
+		if err := s.userRepo.ReleaseUsername(ctx, username, id); err != nil {
+			return false, err
+		}
 	}
 
 	if existingUser != nil {
@@ -69,65 +323,330 @@ func (s *SmokeService) RegisterUser(id int64, username, firstName, lastName stri
 		existingUser.Username = username
 		existingUser.FirstName = firstName
 		existingUser.LastName = lastName
-		return s.userRepo.Update(existingUser)
+		existingUser.IsUnreachable = false
+		return false, s.userRepo.Update(ctx, existingUser)
 	}
 
 	// Create new user
 	user := &domain.User{
-		ID:        id,
-		Username:  username,
-		FirstName: firstName,
-		LastName:  lastName,
+		ID:           id,
+		Username:     username,
+		FirstName:    firstName,
+		LastName:     lastName,
+		DelayMinutes: DefaultDelayMinutes,
+	}
+
+	if err := s.userRepo.Create(ctx, user); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// TrackChat records a chat the bot has received an update from
+func (s *SmokeService) TrackChat(id int64, title, chatType string) error {
+	ctx, cancel := queryContext()
+	defer cancel()
+
+	chat := &domain.Chat{
+		ID:    id,
+		Title: title,
+		Type:  chatType,
+	}
+
+	return s.chatRepo.Upsert(ctx, chat)
+}
+
+// GetChat returns a known chat by ID
+func (s *SmokeService) GetChat(chatID int64) (*domain.Chat, error) {
+	ctx, cancel := queryContext()
+	defer cancel()
+
+	return s.chatRepo.GetByID(ctx, chatID)
+}
+
+// SetChatPlainNames sets whether a chat sees plain names instead of @-mentions
+func (s *SmokeService) SetChatPlainNames(chatID int64, plain bool) error {
+	ctx, cancel := queryContext()
+	defer cancel()
+
+	return s.chatRepo.SetPlainNames(ctx, chatID, plain)
+}
+
+// UsesPlainNames reports whether a chat prefers plain names over
+// @-mentions. Unknown chats use the default @-mentions.
+func (s *SmokeService) UsesPlainNames(chatID int64) bool {
+	ctx, cancel := queryContext()
+	defer cancel()
+
+	chat, err := s.chatRepo.GetByID(ctx, chatID)
+	if err != nil || chat == nil {
+		return false
+	}
+	return chat.PlainNames
+}
+
+// updateOffsetKey is the bot state key of the next Telegram update to fetch
+const updateOffsetKey = "update_offset"
+
+// GetUpdateOffset returns the offset of the next Telegram update to process,
+// or zero if none was saved yet
+func (s *SmokeService) GetUpdateOffset() (int, error) {
+	ctx, cancel := queryContext()
+	defer cancel()
+
+	value, err := s.stateRepo.Get(ctx, updateOffsetKey)
+	if err != nil {
+		return 0, err
+	}
+
+	if value == "" {
+		return 0, nil
+	}
+
+	offset, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid update offset %q: %w", value, err)
+	}
+
+	return offset, nil
+}
+
+// SetUpdateOffset saves the offset of the next Telegram update to process so
+// a restart resumes after the last handled update
+func (s *SmokeService) SetUpdateOffset(offset int) error {
+	ctx, cancel := queryContext()
+	defer cancel()
+
+	return s.stateRepo.Set(ctx, updateOffsetKey, strconv.Itoa(offset))
+}
+
+// Mention renders a user as an @-mention or, when plainNames is set, as
+// plain text that doesn't notify them. Users who chose a nickname or have
+// no username are always shown by name.
+func Mention(user *domain.User, plainNames bool) string {
+	if plainNames || user.DisplayName != "" || user.Username == "" {
+		return user.Display()
+	}
+	return "@" + user.Username
+}
+
+// StartSession starts a new smoking session in a chat. A zero timeoutMinutes
+// uses the configured session timeout. The note, if any, is shown in the
+// invitations.
+func (s *SmokeService) StartSession(initiatorID int64, chatID int64, timeoutMinutes int, note string) (*domain.Session, error) {
+	return s.startSession(initiatorID, chatID, timeoutMinutes, note, nil)
+}
+
+// StartVote starts a session in a chat that asks colleagues to choose
+// between options instead of inviting them to a break. Options are cleaned
+// up and deduplicated; ErrInvalidVoteOptions is returned unless between
+// MinVoteOptions and MaxVoteOptions remain.
+func (s *SmokeService) StartVote(initiatorID int64, chatID int64, options []string) (*domain.Session, error) {
+	options = cleanVoteOptions(options)
+	if len(options) < MinVoteOptions || len(options) > MaxVoteOptions {
+		return nil, ErrInvalidVoteOptions
 	}
 
-	return s.userRepo.Create(user)
+	return s.startSession(initiatorID, chatID, 0, "", options)
 }
 
-// StartSession starts a new smoking session
-func (s *SmokeService) StartSession(initiatorID int64) (*domain.Session, error) {
-	// Check if there's already an active session
-	activeSession, err := s.sessionRepo.GetActiveSession()
+// cleanVoteOptions cleans each option like a note, dropping empty ones and
+// repeats that differ only in case
+func cleanVoteOptions(options []string) []string {
+	var cleaned []string
+	seen := make(map[string]bool)
+	for _, option := range options {
+		option = cleanText(option, MaxVoteOptionLength)
+		key := strings.ToLower(option)
+		if option == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		cleaned = append(cleaned, option)
+	}
+	return cleaned
+}
+
+// startSession creates an active session, a vote when options are given
+func (s *SmokeService) startSession(initiatorID int64, chatID int64, timeoutMinutes int, note string, options []string) (*domain.Session, error) {
+	ctx, cancel := queryContext()
+	defer cancel()
+
+	if timeoutMinutes == 0 {
+		timeoutMinutes = int(s.sessionTimeout / time.Minute)
+	}
+
+	// Check if there's already an active session in this chat
+	activeSession, err := s.activeSession(ctx, chatID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to check active session: %w", err)
 	}
 
 	if activeSession != nil {
-		return nil, fmt.Errorf("there is already an active smoking session")
+		return nil, ErrActiveSessionExists
+	}
+
+	// Users may not start sessions back to back, scheduled ones are exempt.
+	// Yesterday's last break never holds up today's first one.
+	if s.startCooldown > 0 && initiatorID != SystemUserID {
+		last, err := s.sessionRepo.GetLatestSessionByInitiator(ctx, initiatorID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get last session: %w", err)
+		}
+
+		now := s.now()
+		if last != nil && dayKey(last.CreatedAt, s.location) == dayKey(now, s.location) {
+			if elapsed := now.Sub(last.CreatedAt); elapsed < s.startCooldown {
+				return nil, &CooldownError{Remaining: s.startCooldown - elapsed}
+			}
+		}
+	}
+
+	// Don't open a session nobody can be invited to
+	if !s.waitForInvitees {
+		activeUsers, err := s.GetActiveUsers(initiatorID)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(activeUsers) == 0 {
+			return nil, ErrNoActiveUsers
+		}
 	}
 
 	// Create new session
 	session := &domain.Session{
-		InitiatorID: initiatorID,
-		Status:      domain.SessionStatusActive,
+		InitiatorID:    initiatorID,
+		ChatID:         chatID,
+		TimeoutMinutes: timeoutMinutes,
+		Note:           cleanNote(note),
+		Options:        options,
+		Status:         domain.SessionStatusActive,
+	}
+
+	// The initiator is going too, so they count as a participant. The bot
+	// itself never attends its scheduled breaks, and in a vote the
+	// initiator picks an option like everyone else. The response is stored
+	// with the session, so a failure can't leave an active session behind.
+	if initiatorID != SystemUserID && !session.IsVote() {
+		err = s.sessionRepo.CreateWithResponse(ctx, session, &domain.SessionResponse{
+			UserID:   initiatorID,
+			Response: domain.ResponseAccepted,
+		})
+	} else {
+		err = s.sessionRepo.Create(ctx, session)
 	}
 
-	if err := s.sessionRepo.Create(session); err != nil {
+	// A concurrent start may have won the race since the check above; the
+	// repository refuses a second active session in the same chat
+	if errors.Is(err, ErrActiveSessionExists) {
+		return nil, ErrActiveSessionExists
+	}
+	if err != nil {
 		return nil, fmt.Errorf("failed to create session: %w", err)
 	}
 
+	metrics.SessionsStarted.Inc()
+
 	return session, nil
 }
 
-// RespondToSession records a user's response to a session
-func (s *SmokeService) RespondToSession(sessionID int64, userID int64, responseType domain.ResponseType) error {
+// StartScheduledSession starts a session on behalf of the bot itself
+func (s *SmokeService) StartScheduledSession(chatID int64) (*domain.Session, error) {
+	if err := s.ensureSystemUser(); err != nil {
+		return nil, err
+	}
+
+	return s.StartSession(SystemUserID, chatID, 0, "")
+}
+
+// cleanNote strips Markdown control characters and extra whitespace from a
+// session note and cuts it to MaxNoteLength characters
+func cleanNote(note string) string {
+	return cleanText(note, MaxNoteLength)
+}
+
+// cleanText strips Markdown control characters and extra whitespace from
+// user-provided text and cuts it to maxLength characters
+func cleanText(text string, maxLength int) string {
+	text = strings.Map(func(r rune) rune {
+		if strings.ContainsRune("*_`[]", r) {
+			return -1
+		}
+		return r
+	}, text)
+	text = strings.Join(strings.Fields(text), " ")
+
+	if runes := []rune(text); len(runes) > maxLength {
+		text = strings.TrimSpace(string(runes[:maxLength])) + "…"
+	}
+
+	return text
+}
+
+// ensureSystemUser creates the hidden user that owns bot-initiated sessions
+func (s *SmokeService) ensureSystemUser() error {
+	ctx, cancel := queryContext()
+	defer cancel()
+
+	user, err := s.userRepo.GetByID(ctx, SystemUserID)
+	if err != nil {
+		return fmt.Errorf("failed to check system user: %w", err)
+	}
+
+	if user != nil {
+		return nil
+	}
+
+	// Hidden users never receive messages and stay out of stats
+	return s.userRepo.Create(ctx, &domain.User{
+		ID:           SystemUserID,
+		Username:     "smoke_bot",
+		FirstName:    "Расписание",
+		IsHidden:     true,
+		DelayMinutes: DefaultDelayMinutes,
+	})
+}
+
+// RespondToSession records a user's response to a session. It reports
+// whether the response differs from the user's previous one, repeating the
+// same answer changes nothing.
+func (s *SmokeService) RespondToSession(sessionID int64, userID int64, responseType domain.ResponseType) (bool, error) {
+	ctx, cancel := queryContext()
+	defer cancel()
+
 	// Verify session exists and is active
-	session, err := s.sessionRepo.GetByID(sessionID)
+	session, err := s.sessionRepo.GetByID(ctx, sessionID)
 	if err != nil {
-		return fmt.Errorf("failed to get session: %w", err)
+		return false, fmt.Errorf("failed to get session: %w", err)
 	}
 
 	if session == nil {
-		return fmt.Errorf("session not found")
+		return false, fmt.Errorf("session not found")
 	}
 
 	if session.Status != domain.SessionStatusActive {
-		return fmt.Errorf("session is not active")
+		return false, fmt.Errorf("session is not active")
+	}
+
+	if session.IsVote() {
+		return false, ErrVoteSession
+	}
+
+	previous, err := s.sessionRepo.GetUserResponse(ctx, sessionID, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to get previous response: %w", err)
+	}
+
+	if previous != nil && previous.Response == responseType {
+		return false, nil
 	}
 
 	// Handle "I am remote" response
 	if responseType == domain.ResponseRemote {
 		if err := s.SetRemoteStatus(userID); err != nil {
-			return fmt.Errorf("failed to set remote status: %w", err)
+			return false, fmt.Errorf("failed to set remote status: %w", err)
 		}
 	}
 
@@ -138,22 +657,94 @@ func (s *SmokeService) RespondToSession(sessionID int64, userID int64, responseT
 		Response:  responseType,
 	}
 
-	return s.sessionRepo.AddResponse(response)
+	if err := s.sessionRepo.AddResponse(ctx, response); err != nil {
+		return false, err
+	}
+
+	metrics.Responses.WithLabelValues(string(responseType)).Inc()
+
+	return true, nil
+}
+
+// Vote records the option a user chose in a vote, by its index in the
+// session's options. It returns the option and whether it differs from the
+// user's previous choice.
+func (s *SmokeService) Vote(sessionID int64, userID int64, option int) (string, bool, error) {
+	ctx, cancel := queryContext()
+	defer cancel()
+
+	session, err := s.sessionRepo.GetByID(ctx, sessionID)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to get session: %w", err)
+	}
+
+	if session == nil {
+		return "", false, fmt.Errorf("session not found")
+	}
+
+	if session.Status != domain.SessionStatusActive {
+		return "", false, fmt.Errorf("session is not active")
+	}
+
+	if option < 0 || option >= len(session.Options) {
+		return "", false, fmt.Errorf("session %d has no option %d", sessionID, option)
+	}
+	choice := session.Options[option]
+
+	previous, err := s.sessionRepo.GetUserResponse(ctx, sessionID, userID)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to get previous response: %w", err)
+	}
+
+	if previous != nil && previous.Response == domain.ResponseVoted && previous.Option == choice {
+		return choice, false, nil
+	}
+
+	response := &domain.SessionResponse{
+		SessionID: sessionID,
+		UserID:    userID,
+		Response:  domain.ResponseVoted,
+		Option:    choice,
+	}
+
+	if err := s.sessionRepo.AddResponse(ctx, response); err != nil {
+		return "", false, err
+	}
+
+	metrics.Responses.WithLabelValues(string(domain.ResponseVoted)).Inc()
+
+	return choice, true, nil
 }
 
-// GetSessionSummary returns a formatted summary of session responses
-func (s *SmokeService) GetSessionSummary(sessionID int64) (string, error) {
-	responses, err := s.sessionRepo.GetResponses(sessionID)
+// GetSessionSummary returns a formatted summary of session responses in the
+// given language
+func (s *SmokeService) GetSessionSummary(sessionID int64, lang string, plainNames bool) (string, error) {
+	ctx, cancel := queryContext()
+	defer cancel()
+
+	session, err := s.sessionRepo.GetByID(ctx, sessionID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get session: %w", err)
+	}
+
+	if session == nil {
+		return "", fmt.Errorf("session not found")
+	}
+
+	responses, err := s.sessionRepo.GetResponses(ctx, sessionID)
 	if err != nil {
 		return "", fmt.Errorf("failed to get responses: %w", err)
 	}
 
 	var accepted []string
 	var acceptedDelayed []string
+	var delayMinutes []int
+	var maybe []string
 	var denied []string
+	votes := make(map[string][]string)
 
 	for _, resp := range responses {
-		user, err := s.userRepo.GetByID(resp.UserID)
+		user, err := s.userRepo.GetByID(ctx, resp.UserID)
 		if err != nil {
 			continue
 		}
@@ -163,87 +754,780 @@ func (s *SmokeService) GetSessionSummary(sessionID int64) (string, error) {
 			continue
 		}
 
-		displayName := user.Username
-		if displayName == "" {
-			displayName = user.FirstName
-		}
+		displayName := Mention(user, plainNames)
 
 		switch resp.Response {
 		case domain.ResponseAccepted:
 			accepted = append(accepted, displayName)
 		case domain.ResponseAcceptedDelayed:
 			acceptedDelayed = append(acceptedDelayed, displayName)
+			delayMinutes = append(delayMinutes, user.DelayMinutes)
+		case domain.ResponseMaybe:
+			maybe = append(maybe, displayName)
 		case domain.ResponseDenied:
 			denied = append(denied, displayName)
+		case domain.ResponseVoted:
+			votes[resp.Option] = append(votes[resp.Option], displayName)
 		}
 	}
 
-	summary := "📊 *Статус перекура:*\n\n"
+	summary := locale.Tr(lang, "status.title")
 
 	if len(accepted) > 0 {
-		summary += "✅ *Идут сейчас:*\n"
+		summary += locale.Tr(lang, "status.going")
 		for _, name := range accepted {
-			summary += fmt.Sprintf("  • @%s\n", name)
+			summary += fmt.Sprintf("  • %s\n", name)
 		}
 		summary += "\n"
 	}
 
 	if len(acceptedDelayed) > 0 {
-		summary += "⏱ *Придут в течение 5 минут:*\n"
-		for _, name := range acceptedDelayed {
-			summary += fmt.Sprintf("  • @%s\n", name)
+		summary += locale.Tr(lang, "status.later")
+		for i, name := range acceptedDelayed {
+			summary += locale.Tr(lang, "status.later_entry", name, delayMinutes[i])
+		}
+		summary += "\n"
+	}
+
+	if len(maybe) > 0 {
+		summary += locale.Tr(lang, "status.maybe")
+		for _, name := range maybe {
+			summary += fmt.Sprintf("  • %s\n", name)
 		}
 		summary += "\n"
 	}
 
 	if len(denied) > 0 {
-		summary += "❌ *Не идут:*\n"
+		summary += locale.Tr(lang, "status.declined")
 		for _, name := range denied {
-			summary += fmt.Sprintf("  • @%s\n", name)
+			summary += fmt.Sprintf("  • %s\n", name)
+		}
+	}
+
+	if len(votes) > 0 {
+		summary += locale.Tr(lang, "status.votes")
+		for _, option := range session.Options {
+			summary += fmt.Sprintf("  • %s — %d", option, len(votes[option]))
+			if len(votes[option]) > 0 {
+				summary += fmt.Sprintf(" (%s)", strings.Join(votes[option], ", "))
+			}
+			summary += "\n"
 		}
 	}
 
-	if len(accepted) == 0 && len(acceptedDelayed) == 0 && len(denied) == 0 {
-		summary = "Пока никто не ответил"
+	if len(accepted) == 0 && len(acceptedDelayed) == 0 && len(maybe) == 0 && len(denied) == 0 && len(votes) == 0 {
+		summary = locale.Tr(lang, "group.no_answers")
+	}
+
+	summary = strings.TrimRight(summary, "\n") + "\n\n"
+	minutes := int(session.Duration().Round(time.Minute).Minutes())
+	if session.CompletedAt == nil {
+		summary += locale.Tr(lang, "status.running", minutes)
+	} else {
+		summary += locale.Tr(lang, "status.lasted", minutes)
 	}
 
 	return summary, nil
 }
 
-// GetActiveUsers returns all users who are not in remote status
-func (s *SmokeService) GetActiveUsers(excludeUserID int64) ([]*domain.User, error) {
-	// Clear expired remote statuses first
-	if err := s.userRepo.ClearExpiredRemoteStatus(); err != nil {
-		return nil, fmt.Errorf("failed to clear expired remote status: %w", err)
-	}
+// GetInitiatorLeaderboard returns users ranked by the number of sessions
+// they started since the given time
+func (s *SmokeService) GetInitiatorLeaderboard(since time.Time, limit int) ([]domain.LeaderboardEntry, error) {
+	ctx, cancel := queryContext()
+	defer cancel()
 
-	allUsers, err := s.userRepo.GetAll()
+	entries, err := s.sessionRepo.GetInitiatorCounts(ctx, since, limit)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get users: %w", err)
+		return nil, fmt.Errorf("failed to get initiator counts: %w", err)
 	}
 
-	var activeUsers []*domain.User
-	for _, user := range allUsers {
-		// Exclude the initiator, remote users, and hidden users
-		if user.ID != excludeUserID && !user.IsRemoteToday && !user.IsHidden {
-			activeUsers = append(activeUsers, user)
-		}
-	}
-
-	return activeUsers, nil
+	return entries, nil
 }
 
-// SetRemoteStatus sets a user as remote until end of day (23:59)
+// GetLeaderboard returns users ranked by the number of sessions they joined
+// since the given time
+func (s *SmokeService) GetLeaderboard(since time.Time, limit int) ([]domain.LeaderboardEntry, error) {
+	ctx, cancel := queryContext()
+	defer cancel()
+
+	entries, err := s.sessionRepo.GetAcceptedCounts(ctx, since, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get accepted counts: %w", err)
+	}
+
+	return entries, nil
+}
+
+// GetUserHistory returns the latest sessions a user started or joined along
+// with how many people confirmed they came to each
+func (s *SmokeService) GetUserHistory(userID int64, limit int) ([]domain.SessionHistoryEntry, error) {
+	ctx, cancel := queryContext()
+	defer cancel()
+
+	sessions, err := s.sessionRepo.GetSessionsForUser(ctx, userID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sessions: %w", err)
+	}
+
+	entries := make([]domain.SessionHistoryEntry, 0, len(sessions))
+	for _, session := range sessions {
+		responses, err := s.GetSessionResponses(session.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get responses: %w", err)
+		}
+
+		attendees := 0
+		for _, resp := range responses {
+			if resp.IsAttendance() {
+				attendees++
+			}
+		}
+
+		entries = append(entries, domain.SessionHistoryEntry{Session: session, Attendees: attendees})
+	}
+
+	return entries, nil
+}
+
+// GetUserStats counts how a user responded to invitations since the given time
+func (s *SmokeService) GetUserStats(userID int64, since time.Time) (accepted, delayed, denied int, err error) {
+	ctx, cancel := queryContext()
+	defer cancel()
+
+	counts, err := s.sessionRepo.CountUserResponses(ctx, userID, since)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("failed to count responses: %w", err)
+	}
+
+	return counts[domain.ResponseAccepted], counts[domain.ResponseAcceptedDelayed], counts[domain.ResponseDenied], nil
+}
+
+// GetAttendanceStats counts the sessions a user accepted since the given
+// time, split into those they confirmed attending and those still unconfirmed
+func (s *SmokeService) GetAttendanceStats(userID int64, since time.Time) (attended, unconfirmed int, err error) {
+	ctx, cancel := queryContext()
+	defer cancel()
+
+	attended, unconfirmed, err = s.sessionRepo.CountAttendance(ctx, userID, since)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to count attendance: %w", err)
+	}
+
+	return attended, unconfirmed, nil
+}
+
+// ConfirmAttendance records whether a user who accepted a session actually
+// came once it ended
+func (s *SmokeService) ConfirmAttendance(sessionID, userID int64, attended bool) error {
+	ctx, cancel := queryContext()
+	defer cancel()
+
+	session, err := s.sessionRepo.GetByID(ctx, sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to get session: %w", err)
+	}
+
+	if session == nil || session.Status != domain.SessionStatusCompleted {
+		return fmt.Errorf("session is not completed")
+	}
+
+	response, err := s.sessionRepo.GetUserResponse(ctx, sessionID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to get response: %w", err)
+	}
+
+	if response == nil || !response.IsAcceptance() {
+		return fmt.Errorf("user did not accept the session")
+	}
+
+	if err := s.sessionRepo.SetAttendance(ctx, sessionID, userID, attended); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// WorkCalendar tells working days from weekends and holidays.
+// config.WorkingHours implements it.
+type WorkCalendar interface {
+	IsWorkingDay(t time.Time) bool
+}
+
+// GetCurrentStreak returns how many working days in a row the user came to
+// at least one session. Days are taken in loc and the calendar decides which
+// are working days. The streak may end today or on the previous working
+// day, and weekends and holidays neither count nor break it.
+func (s *SmokeService) GetCurrentStreak(userID int64, calendar WorkCalendar, loc *time.Location) (int, error) {
+	ctx, cancel := queryContext()
+	defer cancel()
+
+	times, err := s.sessionRepo.GetAcceptedSessionTimes(ctx, userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get accepted sessions: %w", err)
+	}
+
+	days := make(map[string]bool)
+	for _, t := range times {
+		days[dayKey(t, loc)] = true
+	}
+
+	day := s.now().In(loc)
+	if !calendar.IsWorkingDay(day) || !days[dayKey(day, loc)] {
+		day = previousWorkingDay(calendar, day)
+	}
+
+	streak := 0
+	for days[dayKey(day, loc)] {
+		streak++
+		day = previousWorkingDay(calendar, day)
+	}
+
+	return streak, nil
+}
+
+// dayKey identifies the calendar day of a time in loc
+func dayKey(t time.Time, loc *time.Location) string {
+	return t.In(loc).Format("2006-01-02")
+}
+
+// previousWorkingDay returns the same time on the closest earlier working day
+func previousWorkingDay(calendar WorkCalendar, t time.Time) time.Time {
+	t = t.AddDate(0, 0, -1)
+	for !calendar.IsWorkingDay(t) {
+		t = t.AddDate(0, 0, -1)
+	}
+	return t
+}
+
+// RecordInvitation remembers that a user was sent an invitation
+func (s *SmokeService) RecordInvitation(sessionID int64, userID int64) error {
+	ctx, cancel := queryContext()
+	defer cancel()
+
+	return s.sessionRepo.AddInvitation(ctx, sessionID, userID)
+}
+
+// GetUninvitedSessions returns the active sessions, other than their own,
+// that a user was neither invited to nor responded to, for example because
+// they were remote or muted when the sessions started
+func (s *SmokeService) GetUninvitedSessions(userID int64) ([]*domain.Session, error) {
+	ctx, cancel := queryContext()
+	defer cancel()
+
+	sessions, err := s.sessionRepo.GetAllActiveSessions(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get active sessions: %w", err)
+	}
+
+	var uninvited []*domain.Session
+	for _, session := range sessions {
+		if session.InitiatorID == userID {
+			continue
+		}
+
+		invited, err := s.sessionRepo.IsInvited(ctx, session.ID, userID)
+		if err != nil {
+			return nil, err
+		}
+		if invited {
+			continue
+		}
+
+		response, err := s.sessionRepo.GetUserResponse(ctx, session.ID, userID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get response: %w", err)
+		}
+		if response == nil {
+			uninvited = append(uninvited, session)
+		}
+	}
+
+	return uninvited, nil
+}
+
+// CountInvitationsSince returns how many invitations a user received since
+// the given time
+func (s *SmokeService) CountInvitationsSince(userID int64, since time.Time) (int, error) {
+	ctx, cancel := queryContext()
+	defer cancel()
+
+	return s.sessionRepo.CountInvitations(ctx, userID, since)
+}
+
+// GetNotificationStats returns how many invitations a user received since the
+// given time and how many of them they answered
+func (s *SmokeService) GetNotificationStats(userID int64, since time.Time) (invitations, answered int, err error) {
+	ctx, cancel := queryContext()
+	defer cancel()
+
+	invitations, err = s.sessionRepo.CountInvitations(ctx, userID, since)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	answered, err = s.sessionRepo.CountAnsweredInvitations(ctx, userID, since)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return invitations, answered, nil
+}
+
+// GetActiveUsers returns all users who are not in remote status
+func (s *SmokeService) GetActiveUsers(excludeUserID int64) ([]*domain.User, error) {
+	ctx, cancel := queryContext()
+	defer cancel()
+
+	// Clear expired remote statuses first
+	if err := s.userRepo.ClearExpiredRemoteStatus(ctx); err != nil {
+		return nil, fmt.Errorf("failed to clear expired remote status: %w", err)
+	}
+
+	allUsers, err := s.userRepo.GetAll(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get users: %w", err)
+	}
+
+	now := s.now()
+
+	var activeUsers []*domain.User
+	for _, user := range allUsers {
+		// Exclude the initiator, remote, hidden, muted and unreachable
+		// users, and anyone in their quiet hours
+		if user.ID != excludeUserID && !user.IsRemoteToday && !user.IsHidden && !user.IsMuted && !user.IsUnreachable && !user.InQuietHours(now) {
+			activeUsers = append(activeUsers, user)
+		}
+	}
+
+	return activeUsers, nil
+}
+
+// GetRemoteUsers returns visible users who are working remotely today
+func (s *SmokeService) GetRemoteUsers() ([]*domain.User, error) {
+	ctx, cancel := queryContext()
+	defer cancel()
+
+	if err := s.userRepo.ClearExpiredRemoteStatus(ctx); err != nil {
+		return nil, fmt.Errorf("failed to clear expired remote status: %w", err)
+	}
+
+	allUsers, err := s.userRepo.GetAll(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get users: %w", err)
+	}
+
+	var remoteUsers []*domain.User
+	for _, user := range allUsers {
+		if user.IsRemoteToday && !user.IsHidden {
+			remoteUsers = append(remoteUsers, user)
+		}
+	}
+
+	return remoteUsers, nil
+}
+
+// SetRemoteStatus sets a user as remote until end of day (23:59)
 func (s *SmokeService) SetRemoteStatus(userID int64) error {
-	now := time.Now()
+	ctx, cancel := queryContext()
+	defer cancel()
+
+	now := s.now()
 	endOfDay := time.Date(now.Year(), now.Month(), now.Day(), 23, 59, 59, 0, now.Location())
 
-	return s.userRepo.SetRemoteStatus(userID, endOfDay)
+	return s.userRepo.SetRemoteStatus(ctx, userID, endOfDay)
+}
+
+// ClearRemoteStatus removes remote status for a user
+func (s *SmokeService) ClearRemoteStatus(userID int64) error {
+	ctx, cancel := queryContext()
+	defer cancel()
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if user == nil {
+		return fmt.Errorf("user not found")
+	}
+
+	user.IsRemoteToday = false
+	user.RemoteUntil = nil
+
+	return s.userRepo.Update(ctx, user)
+}
+
+// ClearExpiredRemoteStatus clears every remote status that has run out
+func (s *SmokeService) ClearExpiredRemoteStatus() error {
+	ctx, cancel := queryContext()
+	defer cancel()
+
+	if err := s.userRepo.ClearExpiredRemoteStatus(ctx); err != nil {
+		return fmt.Errorf("failed to clear expired remote status: %w", err)
+	}
+
+	return nil
+}
+
+// ResetAllRemoteStatus brings every remote user back to the office and
+// returns how many were reset
+func (s *SmokeService) ResetAllRemoteStatus() (int64, error) {
+	ctx, cancel := queryContext()
+	defer cancel()
+
+	return s.userRepo.ClearAllRemoteStatus(ctx)
+}
+
+// RequestApproval puts a user into the approval queue. It returns true only
+// when the request is new, so admins are asked once per user.
+func (s *SmokeService) RequestApproval(userID int64) (bool, error) {
+	ctx, cancel := queryContext()
+	defer cancel()
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if user == nil {
+		return false, fmt.Errorf("user not found")
+	}
+
+	if user.Approval != domain.ApprovalNone {
+		return false, nil
+	}
+
+	user.Approval = domain.ApprovalPending
+
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// SetApproval records an admin's decision about a user
+func (s *SmokeService) SetApproval(userID int64, approved bool) (*domain.User, error) {
+	ctx, cancel := queryContext()
+	defer cancel()
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if user == nil {
+		return nil, fmt.Errorf("user not found")
+	}
+
+	user.Approval = domain.ApprovalRejected
+	if approved {
+		user.Approval = domain.ApprovalApproved
+	}
+
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// SetWeeklySummary sets whether a user receives a personal weekly summary
+func (s *SmokeService) SetWeeklySummary(userID int64, enabled bool) error {
+	ctx, cancel := queryContext()
+	defer cancel()
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if user == nil {
+		return fmt.Errorf("user not found")
+	}
+
+	user.WeeklySummary = enabled
+
+	return s.userRepo.Update(ctx, user)
+}
+
+// GetWeeklySummaryRecipients returns opted-in users who haven't received
+// the summary for the given week yet
+func (s *SmokeService) GetWeeklySummaryRecipients(week string) ([]*domain.User, error) {
+	ctx, cancel := queryContext()
+	defer cancel()
+
+	allUsers, err := s.userRepo.GetAll(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get users: %w", err)
+	}
+
+	var recipients []*domain.User
+	for _, user := range allUsers {
+		if user.WeeklySummary && user.WeeklySummaryWeek != week {
+			recipients = append(recipients, user)
+		}
+	}
+
+	return recipients, nil
+}
+
+// MarkWeeklySummarySent records that a user got the summary for the given
+// week. Only that is written, so settings the user changed while the
+// summaries were going out are kept.
+func (s *SmokeService) MarkWeeklySummarySent(userID int64, week string) error {
+	ctx, cancel := queryContext()
+	defer cancel()
+
+	return s.userRepo.SetWeeklySummaryWeek(ctx, userID, week)
+}
+
+// GetWeeklyDigest aggregates the sessions started in the week beginning at
+// weekStart. Cancelled sessions are ignored, attendees are those who
+// confirmed they came, and hidden users never show up as the top initiator.
+func (s *SmokeService) GetWeeklyDigest(weekStart time.Time) (*domain.Digest, error) {
+	ctx, cancel := queryContext()
+	defer cancel()
+
+	sessions, err := s.sessionRepo.GetSessionsBetween(ctx, weekStart, weekStart.AddDate(0, 0, 7))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sessions: %w", err)
+	}
+
+	digest := &domain.Digest{WeekStart: weekStart}
+	breaksByDay := make(map[time.Weekday]int)
+	breaksByInitiator := make(map[int64]int)
+	attendees := 0
+
+	for _, session := range sessions {
+		// A vote only picks where to go, nobody takes a break in it
+		if !session.Happened() || session.IsVote() {
+			continue
+		}
+
+		digest.TotalBreaks++
+		breaksByDay[session.CreatedAt.In(weekStart.Location()).Weekday()]++
+		breaksByInitiator[session.InitiatorID]++
+
+		responses, err := s.GetSessionResponses(session.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get responses: %w", err)
+		}
+
+		for _, resp := range responses {
+			if resp.IsAttendance() {
+				attendees++
+			}
+		}
+	}
+
+	if digest.TotalBreaks == 0 {
+		return digest, nil
+	}
+
+	digest.AverageAttendees = float64(attendees) / float64(digest.TotalBreaks)
+
+	// Walk the week in order so ties go to the earlier day
+	for i := 0; i < 7; i++ {
+		day := weekStart.AddDate(0, 0, i).Weekday()
+		if breaksByDay[day] > digest.BusiestDayBreaks {
+			digest.BusiestDay = day
+			digest.BusiestDayBreaks = breaksByDay[day]
+		}
+	}
+
+	for initiatorID, count := range breaksByInitiator {
+		if count < digest.TopInitiatorCount || (count == digest.TopInitiatorCount && initiatorID > digest.TopInitiatorID) {
+			continue
+		}
+
+		initiator, err := s.GetUser(initiatorID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get user: %w", err)
+		}
+
+		if initiator == nil || initiator.IsHidden {
+			continue
+		}
+
+		digest.TopInitiatorID = initiatorID
+		digest.TopInitiatorCount = count
+	}
+
+	return digest, nil
+}
+
+// ExportCSV writes every session with its response counts to w as CSV.
+// Acceptances are also split into confirmed attendance and those nobody
+// confirmed yet.
+// Hidden users are anonymized, or left out together with the sessions they
+// started when excludeHidden is set.
+func (s *SmokeService) ExportCSV(w io.Writer, excludeHidden bool) error {
+	ctx, cancel := queryContext()
+	defer cancel()
+
+	sessions, err := s.sessionRepo.GetSessionsBetween(ctx, time.Time{}, s.now().Add(time.Minute))
+	if err != nil {
+		return fmt.Errorf("failed to get sessions: %w", err)
+	}
+
+	users := make(map[int64]*domain.User)
+	lookup := func(userID int64) (*domain.User, error) {
+		if user, ok := users[userID]; ok {
+			return user, nil
+		}
+		user, err := s.GetUser(userID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get user: %w", err)
+		}
+		users[userID] = user
+		return user, nil
+	}
+
+	writer := csv.NewWriter(w)
+	header := []string{"session_id", "initiator", "created_at", "completed_at", "status", "failed", "accepted", "accepted_delayed", "maybe", "denied", "remote", "attended", "unconfirmed"}
+	if err := writer.Write(header); err != nil {
+		return fmt.Errorf("failed to write CSV: %w", err)
+	}
+
+	for _, session := range sessions {
+		initiator, err := lookup(session.InitiatorID)
+		if err != nil {
+			return err
+		}
+
+		initiatorName := strconv.FormatInt(session.InitiatorID, 10)
+		if initiator != nil {
+			initiatorName = initiator.Display()
+			if initiator.IsHidden {
+				if excludeHidden {
+					continue
+				}
+				initiatorName = "hidden"
+			}
+		}
+
+		responses, err := s.GetSessionResponses(session.ID)
+		if err != nil {
+			return fmt.Errorf("failed to get responses: %w", err)
+		}
+
+		counts := make(map[domain.ResponseType]int)
+		attended, unconfirmed := 0, 0
+		for _, resp := range responses {
+			if excludeHidden {
+				respondent, err := lookup(resp.UserID)
+				if err != nil {
+					return err
+				}
+				if respondent != nil && respondent.IsHidden {
+					continue
+				}
+			}
+			counts[resp.Response]++
+
+			if resp.IsAcceptance() {
+				switch {
+				case resp.Attended == nil:
+					unconfirmed++
+				case *resp.Attended:
+					attended++
+				}
+			}
+		}
+
+		completedAt := ""
+		if session.CompletedAt != nil {
+			completedAt = session.CompletedAt.Format(time.RFC3339)
+		}
+
+		row := []string{
+			strconv.FormatInt(session.ID, 10),
+			initiatorName,
+			session.CreatedAt.Format(time.RFC3339),
+			completedAt,
+			string(session.Status),
+			strconv.FormatBool(session.Failed),
+			strconv.Itoa(counts[domain.ResponseAccepted]),
+			strconv.Itoa(counts[domain.ResponseAcceptedDelayed]),
+			strconv.Itoa(counts[domain.ResponseMaybe]),
+			strconv.Itoa(counts[domain.ResponseDenied]),
+			strconv.Itoa(counts[domain.ResponseRemote]),
+			strconv.Itoa(attended),
+			strconv.Itoa(unconfirmed),
+		}
+		if err := writer.Write(row); err != nil {
+			return fmt.Errorf("failed to write CSV: %w", err)
+		}
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("failed to write CSV: %w", err)
+	}
+
+	return nil
+}
+
+// GetAnnouncementRecipients returns every visible user who is neither muted
+// nor unreachable
+func (s *SmokeService) GetAnnouncementRecipients() ([]*domain.User, error) {
+	ctx, cancel := queryContext()
+	defer cancel()
+
+	allUsers, err := s.userRepo.GetAll(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get users: %w", err)
+	}
+
+	var recipients []*domain.User
+	for _, user := range allUsers {
+		if !user.IsHidden && !user.IsMuted && !user.IsUnreachable && user.ID != SystemUserID {
+			recipients = append(recipients, user)
+		}
+	}
+
+	return recipients, nil
 }
 
-// ClearRemoteStatus removes remote status for a user
-func (s *SmokeService) ClearRemoteStatus(userID int64) error {
-	user, err := s.userRepo.GetByID(userID)
+// GetDigestRecipients returns visible, non-muted, reachable users who haven't
+// received the digest for the given week yet
+func (s *SmokeService) GetDigestRecipients(week string) ([]*domain.User, error) {
+	ctx, cancel := queryContext()
+	defer cancel()
+
+	allUsers, err := s.userRepo.GetAll(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get users: %w", err)
+	}
+
+	var recipients []*domain.User
+	for _, user := range allUsers {
+		if !user.IsHidden && !user.IsMuted && !user.IsUnreachable && user.DigestWeek != week {
+			recipients = append(recipients, user)
+		}
+	}
+
+	return recipients, nil
+}
+
+// MarkDigestSent records that a user got the digest for the given week.
+// Only that is written, so settings the user changed while the digest was
+// going out are kept.
+func (s *SmokeService) MarkDigestSent(userID int64, week string) error {
+	ctx, cancel := queryContext()
+	defer cancel()
+
+	return s.userRepo.SetDigestWeek(ctx, userID, week)
+}
+
+// SetSkipOwnSummary sets whether a user skips the final summary for
+// sessions they started and finished themselves
+func (s *SmokeService) SetSkipOwnSummary(userID int64, skip bool) error {
+	ctx, cancel := queryContext()
+	defer cancel()
+
+	user, err := s.userRepo.GetByID(ctx, userID)
 	if err != nil {
 		return fmt.Errorf("failed to get user: %w", err)
 	}
@@ -252,30 +1536,409 @@ func (s *SmokeService) ClearRemoteStatus(userID int64) error {
 		return fmt.Errorf("user not found")
 	}
 
-	user.IsRemoteToday = false
-	user.RemoteUntil = nil
+	user.SkipOwnSummary = skip
+
+	return s.userRepo.Update(ctx, user)
+}
+
+// SetTerseReplies sets whether a user gets short confirmations instead of
+// the full text
+func (s *SmokeService) SetTerseReplies(userID int64, terse bool) error {
+	ctx, cancel := queryContext()
+	defer cancel()
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if user == nil {
+		return fmt.Errorf("user not found")
+	}
+
+	user.TerseReplies = terse
+
+	return s.userRepo.Update(ctx, user)
+}
+
+// SetLanguage sets the language of the messages a user receives. An empty
+// language goes back to the bot's default.
+func (s *SmokeService) SetLanguage(userID int64, lang string) error {
+	ctx, cancel := queryContext()
+	defer cancel()
+
+	if lang != "" && !locale.IsSupported(lang) {
+		return fmt.Errorf("unsupported language %q", lang)
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if user == nil {
+		return fmt.Errorf("user not found")
+	}
+
+	user.Language = lang
+
+	return s.userRepo.Update(ctx, user)
+}
+
+// SetDisplayName sets the nickname shown for a user instead of their
+// username and returns it as stored. An empty name goes back to the username.
+func (s *SmokeService) SetDisplayName(userID int64, name string) (string, error) {
+	ctx, cancel := queryContext()
+	defer cancel()
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if user == nil {
+		return "", fmt.Errorf("user not found")
+	}
+
+	user.DisplayName = cleanText(name, MaxDisplayNameLength)
+
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return "", err
+	}
+
+	return user.DisplayName, nil
+}
+
+// SetMuted turns invitations off or back on for a user. Muted users can
+// still start sessions themselves.
+func (s *SmokeService) SetMuted(userID int64, muted bool) error {
+	ctx, cancel := queryContext()
+	defer cancel()
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if user == nil {
+		return fmt.Errorf("user not found")
+	}
+
+	user.IsMuted = muted
+
+	return s.userRepo.Update(ctx, user)
+}
+
+// GetUserByUsername retrieves a user by their Telegram username. The leading
+// @ is optional and case is ignored. It returns nil, nil if there is no such
+// user, so callers can tell "not found" from a failed lookup.
+func (s *SmokeService) GetUserByUsername(username string) (*domain.User, error) {
+	ctx, cancel := queryContext()
+	defer cancel()
+
+	return s.userRepo.GetByUsername(ctx, normalizeUsername(username))
+}
+
+// normalizeUsername strips whitespace and the leading @ from a username,
+// keeping its case for display. Lookups ignore case.
+func normalizeUsername(username string) string {
+	return strings.TrimPrefix(strings.TrimSpace(username), "@")
+}
+
+// SetHidden hides a user from invitations and summaries, or shows them again
+func (s *SmokeService) SetHidden(userID int64, hidden bool) error {
+	ctx, cancel := queryContext()
+	defer cancel()
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if user == nil {
+		return fmt.Errorf("user not found")
+	}
+
+	user.IsHidden = hidden
+
+	return s.userRepo.Update(ctx, user)
+}
+
+// MarkUserUnreachable stops messaging a user the bot can no longer reach,
+// for example because they blocked it, until they write to it again.
+// Unknown users are ignored.
+func (s *SmokeService) MarkUserUnreachable(userID int64) error {
+	ctx, cancel := queryContext()
+	defer cancel()
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if user == nil || user.IsUnreachable {
+		return nil
+	}
+
+	user.IsUnreachable = true
+
+	return s.userRepo.Update(ctx, user)
+}
+
+// SetQuietHours sets the user's daily quiet window in minutes since
+// midnight. Equal bounds clear it.
+func (s *SmokeService) SetQuietHours(userID int64, from, to int) error {
+	ctx, cancel := queryContext()
+	defer cancel()
+
+	if from < 0 || from >= 24*60 || to < 0 || to >= 24*60 {
+		return fmt.Errorf("quiet hours must be within a day")
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if user == nil {
+		return fmt.Errorf("user not found")
+	}
+
+	user.QuietFrom = from
+	user.QuietTo = to
+
+	return s.userRepo.Update(ctx, user)
+}
+
+// SetDelayMinutes sets how many minutes the user needs after answering "later"
+func (s *SmokeService) SetDelayMinutes(userID int64, minutes int) error {
+	ctx, cancel := queryContext()
+	defer cancel()
+
+	if minutes < MinDelayMinutes || minutes > MaxDelayMinutes {
+		return fmt.Errorf("delay must be between %d and %d minutes", MinDelayMinutes, MaxDelayMinutes)
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if user == nil {
+		return fmt.Errorf("user not found")
+	}
+
+	user.DelayMinutes = minutes
+
+	return s.userRepo.Update(ctx, user)
+}
+
+// SetTimezone validates an IANA timezone name and stores it for the user
+func (s *SmokeService) SetTimezone(userID int64, tz string) error {
+	ctx, cancel := queryContext()
+	defer cancel()
+
+	if _, err := time.LoadLocation(tz); err != nil {
+		return fmt.Errorf("invalid timezone %q: %w", tz, err)
+	}
 
-	return s.userRepo.Update(user)
+	return s.userRepo.SetTimezone(ctx, userID, tz)
 }
 
 // CompleteSession marks a session as completed
 func (s *SmokeService) CompleteSession(sessionID int64) error {
-	return s.sessionRepo.CompleteSession(sessionID)
+	return s.completeSession(sessionID, false)
+}
+
+// completeSession marks a session as completed, flagging it as failed when
+// too few people joined
+func (s *SmokeService) completeSession(sessionID int64, failed bool) error {
+	err := s.transitionSession(sessionID, domain.SessionStatusCompleted, func(session *domain.Session) {
+		session.Failed = failed
+	})
+	if err != nil {
+		return err
+	}
+
+	metrics.SessionsCompleted.Inc()
+
+	return nil
+}
+
+// GetActiveSession returns the active session of a chat if exists. Private
+// chats all see the same session, see activeSession.
+func (s *SmokeService) GetActiveSession(chatID int64) (*domain.Session, error) {
+	ctx, cancel := queryContext()
+	defer cancel()
+
+	return s.activeSession(ctx, chatID)
+}
+
+// activeSession returns the active session a chat takes part in. Each group
+// runs its own sessions, while a session started in a private chat invites
+// everyone, so all private chats share one.
+func (s *SmokeService) activeSession(ctx context.Context, chatID int64) (*domain.Session, error) {
+	if domain.IsGroupChat(chatID) {
+		return s.sessionRepo.GetActiveSessionByChat(ctx, chatID)
+	}
+	return s.sessionRepo.GetActivePrivateSession(ctx)
+}
+
+// GetLatestSession returns the most recent session of a chat, whatever its
+// status
+func (s *SmokeService) GetLatestSession(chatID int64) (*domain.Session, error) {
+	ctx, cancel := queryContext()
+	defer cancel()
+
+	return s.sessionRepo.GetLatestSessionByChat(ctx, chatID)
+}
+
+// ExtendSession reopens a session completed less than ExtendGracePeriod ago,
+// keeping its responses. It stays open for the default session timeout
+// from now.
+func (s *SmokeService) ExtendSession(sessionID int64) error {
+	ctx, cancel := queryContext()
+	defer cancel()
+
+	session, err := s.sessionRepo.GetByID(ctx, sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to get session: %w", err)
+	}
+
+	if session == nil {
+		return fmt.Errorf("session not found")
+	}
+
+	if session.Status != domain.SessionStatusCompleted || session.CompletedAt == nil ||
+		s.now().Sub(*session.CompletedAt) > ExtendGracePeriod {
+		return fmt.Errorf("session can no longer be extended")
+	}
+
+	activeSession, err := s.activeSession(ctx, session.ChatID)
+	if err != nil {
+		return fmt.Errorf("failed to check active session: %w", err)
+	}
+
+	if activeSession != nil {
+		return ErrActiveSessionExists
+	}
+
+	if err := session.Transition(domain.SessionStatusActive); err != nil {
+		return err
+	}
+
+	session.TimeoutMinutes = int((s.now().Sub(session.CreatedAt) + s.sessionTimeout) / time.Minute)
+
+	return s.sessionRepo.Update(ctx, session)
+}
+
+// GetSession returns a session by ID
+func (s *SmokeService) GetSession(sessionID int64) (*domain.Session, error) {
+	ctx, cancel := queryContext()
+	defer cancel()
+
+	return s.sessionRepo.GetByID(ctx, sessionID)
+}
+
+// SetStatusMessage remembers the initiator's message that is kept up to date
+// with the responses
+func (s *SmokeService) SetStatusMessage(sessionID int64, chatID int64, messageID int) error {
+	ctx, cancel := queryContext()
+	defer cancel()
+
+	return s.sessionRepo.SetStatusMessage(ctx, sessionID, chatID, messageID, false)
+}
+
+// SetGroupMessage remembers the invitation posted to a group chat, which
+// everyone answers and which is kept up to date with the responses
+func (s *SmokeService) SetGroupMessage(sessionID int64, chatID int64, messageID int) error {
+	ctx, cancel := queryContext()
+	defer cancel()
+
+	return s.sessionRepo.SetStatusMessage(ctx, sessionID, chatID, messageID, true)
+}
+
+// SetSessionLocation stores where the break of the chat's active session
+// takes place. Only the initiator may set it.
+func (s *SmokeService) SetSessionLocation(userID int64, chatID int64, latitude, longitude float64) (*domain.Session, error) {
+	ctx, cancel := queryContext()
+	defer cancel()
+
+	session, err := s.activeSession(ctx, chatID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get active session: %w", err)
+	}
+
+	if session == nil {
+		return nil, ErrNoActiveSession
+	}
+
+	if session.InitiatorID != userID {
+		return nil, ErrNotInitiator
+	}
+
+	if err := s.sessionRepo.SetLocation(ctx, session.ID, latitude, longitude); err != nil {
+		return nil, err
+	}
+
+	session.Latitude = &latitude
+	session.Longitude = &longitude
+
+	return session, nil
+}
+
+// CountActiveSessions returns how many sessions are still active
+func (s *SmokeService) CountActiveSessions() (int, error) {
+	ctx, cancel := queryContext()
+	defer cancel()
+
+	sessions, err := s.sessionRepo.GetAllActiveSessions(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(sessions), nil
 }
 
-// GetActiveSession returns the current active session if exists
-func (s *SmokeService) GetActiveSession() (*domain.Session, error) {
-	return s.sessionRepo.GetActiveSession()
+// GetAllActiveSessions returns every session that is still active
+func (s *SmokeService) GetAllActiveSessions() ([]*domain.Session, error) {
+	ctx, cancel := queryContext()
+	defer cancel()
+
+	return s.sessionRepo.GetAllActiveSessions(ctx)
 }
 
 // GetUser returns a user by ID
 func (s *SmokeService) GetUser(userID int64) (*domain.User, error) {
-	return s.userRepo.GetByID(userID)
+	ctx, cancel := queryContext()
+	defer cancel()
+
+	return s.userRepo.GetByID(ctx, userID)
+}
+
+// CancelSession cancels an active session. The initiator's reason, if any,
+// is cleaned up and kept to be shown to the participants.
+func (s *SmokeService) CancelSession(sessionID int64, reason string) error {
+	err := s.transitionSession(sessionID, domain.SessionStatusCancelled, func(session *domain.Session) {
+		session.CancelReason = cleanText(reason, MaxCancelReasonLength)
+	})
+	if err != nil {
+		return err
+	}
+
+	metrics.SessionsCancelled.Inc()
+
+	return nil
 }
 
-// CancelSession cancels an active session
-func (s *SmokeService) CancelSession(sessionID int64) error {
-	session, err := s.sessionRepo.GetByID(sessionID)
+// transitionSession moves a session to a new status. All status changes go
+// through here so illegal transitions are rejected consistently. A non-nil
+// update changes the session further before it is saved.
+func (s *SmokeService) transitionSession(sessionID int64, to domain.SessionStatus, update func(session *domain.Session)) error {
+	ctx, cancel := queryContext()
+	defer cancel()
+
+	session, err := s.sessionRepo.GetByID(ctx, sessionID)
 	if err != nil {
 		return fmt.Errorf("failed to get session: %w", err)
 	}
@@ -284,16 +1947,23 @@ func (s *SmokeService) CancelSession(sessionID int64) error {
 		return fmt.Errorf("session not found")
 	}
 
-	session.Status = domain.SessionStatusCancelled
-	now := time.Now()
-	session.CompletedAt = &now
+	if err := session.Transition(to); err != nil {
+		return err
+	}
+
+	if update != nil {
+		update(session)
+	}
 
-	return s.sessionRepo.Update(session)
+	return s.sessionRepo.Update(ctx, session)
 }
 
 // GetSessionRespondents returns all users who responded to a session
 func (s *SmokeService) GetSessionRespondents(sessionID int64) ([]*domain.User, error) {
-	responses, err := s.sessionRepo.GetResponses(sessionID)
+	ctx, cancel := queryContext()
+	defer cancel()
+
+	responses, err := s.sessionRepo.GetResponses(ctx, sessionID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get responses: %w", err)
 	}
@@ -302,10 +1972,10 @@ func (s *SmokeService) GetSessionRespondents(sessionID int64) ([]*domain.User, e
 	userMap := make(map[int64]bool) // To avoid duplicates
 
 	for _, resp := range responses {
-		// Only include users who accepted (not denied or remote)
-		if resp.Response == domain.ResponseAccepted || resp.Response == domain.ResponseAcceptedDelayed {
+		// Only include users who accepted or voted (not denied or remote)
+		if resp.IsAcceptance() || resp.Response == domain.ResponseVoted {
 			if !userMap[resp.UserID] {
-				user, err := s.userRepo.GetByID(resp.UserID)
+				user, err := s.userRepo.GetByID(ctx, resp.UserID)
 				if err != nil {
 					continue
 				}
@@ -320,5 +1990,93 @@ func (s *SmokeService) GetSessionRespondents(sessionID int64) ([]*domain.User, e
 
 // GetSessionResponses returns all responses for a session
 func (s *SmokeService) GetSessionResponses(sessionID int64) ([]*domain.SessionResponse, error) {
-	return s.sessionRepo.GetResponses(sessionID)
+	ctx, cancel := queryContext()
+	defer cancel()
+
+	return s.sessionRepo.GetResponses(ctx, sessionID)
+}
+
+// GetNonResponders returns active users, except the initiator, who haven't
+// answered the session invitation yet
+func (s *SmokeService) GetNonResponders(sessionID int64) ([]*domain.User, error) {
+	ctx, cancel := queryContext()
+	defer cancel()
+
+	session, err := s.sessionRepo.GetByID(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+
+	if session == nil {
+		return nil, fmt.Errorf("session not found")
+	}
+
+	activeUsers, err := s.GetActiveUsers(session.InitiatorID)
+	if err != nil {
+		return nil, err
+	}
+
+	responses, err := s.sessionRepo.GetResponses(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get responses: %w", err)
+	}
+
+	responded := make(map[int64]bool)
+	for _, resp := range responses {
+		responded[resp.UserID] = true
+	}
+
+	var nonResponders []*domain.User
+	for _, user := range activeUsers {
+		if !responded[user.ID] {
+			nonResponders = append(nonResponders, user)
+		}
+	}
+
+	return nonResponders, nil
+}
+
+// MarkPoked records that a non-responder was reminded about a session. It
+// returns false if they were already reminded.
+func (s *SmokeService) MarkPoked(sessionID int64, userID int64) (bool, error) {
+	ctx, cancel := queryContext()
+	defer cancel()
+
+	return s.sessionRepo.MarkPoked(ctx, sessionID, userID)
+}
+
+// GetDueDelayedResponses returns delayed responses whose promised time has
+// come and that haven't been reminded about yet
+func (s *SmokeService) GetDueDelayedResponses() ([]*domain.SessionResponse, error) {
+	ctx, cancel := queryContext()
+	defer cancel()
+
+	now := s.now()
+	candidates, err := s.sessionRepo.GetDueDelayedResponses(ctx, now.Add(-MinDelayMinutes*time.Minute))
+	if err != nil {
+		return nil, err
+	}
+
+	// Each user has their own delay, so the final cut is made per response
+	var due []*domain.SessionResponse
+	for _, resp := range candidates {
+		user, err := s.userRepo.GetByID(ctx, resp.UserID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get user: %w", err)
+		}
+
+		if user == nil || !now.Before(resp.CreatedAt.Add(user.Delay())) {
+			due = append(due, resp)
+		}
+	}
+
+	return due, nil
+}
+
+// MarkReminderSent records that the reminder for a delayed response was sent
+func (s *SmokeService) MarkReminderSent(responseID int64) error {
+	ctx, cancel := queryContext()
+	defer cancel()
+
+	return s.sessionRepo.MarkReminderSent(ctx, responseID)
 }
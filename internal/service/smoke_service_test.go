@@ -0,0 +1,529 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/glebk/smoke-bot/internal/config"
+	"github.com/glebk/smoke-bot/internal/domain"
+	"github.com/glebk/smoke-bot/internal/repository/memory"
+	"github.com/glebk/smoke-bot/internal/repository/sqlite"
+)
+
+// testTimeout is the default session lifetime in the tests
+const testTimeout = 15 * time.Minute
+
+// testService is a SmokeService on in-memory repositories
+type testService struct {
+	*SmokeService
+	users    *memory.UserRepository
+	sessions *memory.SessionRepository
+}
+
+func newTestService(t *testing.T, startCooldown time.Duration) *testService {
+	t.Helper()
+
+	users := memory.NewUserRepository()
+	sessions := memory.NewSessionRepository(users)
+	svc := NewSmokeService(users, sessions, memory.NewChatRepository(), memory.NewStateRepository(),
+		testTimeout, startCooldown, time.UTC, false)
+
+	return &testService{SmokeService: svc, users: users, sessions: sessions}
+}
+
+// addUsers registers users with the given IDs
+func (s *testService) addUsers(t *testing.T, ids ...int64) {
+	t.Helper()
+
+	for _, id := range ids {
+		if _, err := s.RegisterUser(id, fmt.Sprintf("user%d", id), fmt.Sprintf("User %d", id), ""); err != nil {
+			t.Fatalf("RegisterUser(%d): %v", id, err)
+		}
+	}
+}
+
+// addSession stores a session created at the given time, bypassing the
+// checks StartSession makes
+func (s *testService) addSession(t *testing.T, session *domain.Session) *domain.Session {
+	t.Helper()
+
+	if session.TimeoutMinutes == 0 {
+		session.TimeoutMinutes = int(testTimeout / time.Minute)
+	}
+	if err := s.sessions.Create(context.Background(), session); err != nil {
+		t.Fatalf("creating session: %v", err)
+	}
+	return session
+}
+
+// attend records that a user accepted a session and confirmed they came
+func (s *testService) attend(t *testing.T, sessionID, userID int64) {
+	t.Helper()
+
+	ctx := context.Background()
+	response := &domain.SessionResponse{SessionID: sessionID, UserID: userID, Response: domain.ResponseAccepted}
+	if err := s.sessions.AddResponse(ctx, response); err != nil {
+		t.Fatalf("AddResponse: %v", err)
+	}
+	if err := s.sessions.SetAttendance(ctx, sessionID, userID, true); err != nil {
+		t.Fatalf("SetAttendance: %v", err)
+	}
+}
+
+func TestStartSessionRejectsSecondActiveSession(t *testing.T) {
+	tests := []struct {
+		name        string
+		firstChat   int64
+		secondChat  int64
+		wantErr     error
+		wantSession bool
+	}{
+		{"same group", -100, -100, ErrActiveSessionExists, false},
+		{"same private chat", 1, 1, ErrActiveSessionExists, false},
+		{"another private chat", 1, 2, ErrActiveSessionExists, false},
+		{"another group", -100, -200, nil, true},
+		{"group after private chat", 1, -100, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := newTestService(t, 0)
+			svc.addUsers(t, 1, 2, 3)
+
+			if _, err := svc.StartSession(1, tt.firstChat, 0, ""); err != nil {
+				t.Fatalf("first StartSession: %v", err)
+			}
+
+			session, err := svc.StartSession(2, tt.secondChat, 0, "")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("second StartSession error = %v, want %v", err, tt.wantErr)
+			}
+			if got := session != nil; got != tt.wantSession {
+				t.Errorf("second session created = %v, want %v", got, tt.wantSession)
+			}
+		})
+	}
+}
+
+func TestStartSessionConcurrently(t *testing.T) {
+	const starters = 10
+
+	backends := []struct {
+		name       string
+		newService func(t *testing.T) *SmokeService
+	}{
+		{"memory", func(t *testing.T) *SmokeService {
+			return newTestService(t, 0).SmokeService
+		}},
+		{"sqlite", func(t *testing.T) *SmokeService {
+			db, err := sqlite.New(":memory:")
+			if err != nil {
+				t.Fatalf("opening database: %v", err)
+			}
+			t.Cleanup(func() { db.Close() })
+
+			return NewSmokeService(sqlite.NewUserRepository(db), sqlite.NewSessionRepository(db),
+				sqlite.NewChatRepository(db), sqlite.NewStateRepository(db), testTimeout, 0, time.UTC, false)
+		}},
+	}
+	chats := []struct {
+		name   string
+		chatID func(userID int64) int64
+	}{
+		{"one group", func(int64) int64 { return -100 }},
+		{"private chats", func(userID int64) int64 { return userID }},
+	}
+
+	for _, backend := range backends {
+		for _, chat := range chats {
+			t.Run(backend.name+"/"+chat.name, func(t *testing.T) {
+				svc := backend.newService(t)
+				for id := int64(1); id <= starters+1; id++ {
+					if _, err := svc.RegisterUser(id, fmt.Sprintf("user%d", id), "User", ""); err != nil {
+						t.Fatalf("RegisterUser(%d): %v", id, err)
+					}
+				}
+
+				var wg sync.WaitGroup
+				errs := make(chan error, starters)
+				for id := int64(1); id <= starters; id++ {
+					wg.Add(1)
+					go func(userID int64) {
+						defer wg.Done()
+						_, err := svc.StartSession(userID, chat.chatID(userID), 0, "")
+						errs <- err
+					}(id)
+				}
+				wg.Wait()
+				close(errs)
+
+				started := 0
+				for err := range errs {
+					switch {
+					case err == nil:
+						started++
+					case !errors.Is(err, ErrActiveSessionExists):
+						t.Errorf("StartSession: %v", err)
+					}
+				}
+				if started != 1 {
+					t.Errorf("%d concurrent starts succeeded, want exactly 1", started)
+				}
+
+				active, err := svc.CountActiveSessions()
+				if err != nil {
+					t.Fatalf("CountActiveSessions: %v", err)
+				}
+				if active != 1 {
+					t.Errorf("%d active sessions, want 1", active)
+				}
+			})
+		}
+	}
+}
+
+func TestStartSessionCooldown(t *testing.T) {
+	msk := time.FixedZone("MSK", 3*60*60)
+	at := func(day, hour, minute, second int) time.Time {
+		return time.Date(2024, 3, day, hour, minute, second, 0, msk)
+	}
+
+	tests := []struct {
+		name     string
+		cooldown time.Duration
+		last     time.Time
+		now      time.Time
+		wantWait time.Duration
+	}{
+		{"within the cooldown", 2 * time.Minute, at(5, 12, 0, 0), at(5, 12, 1, 0), time.Minute},
+		{"one second before it ends", 2 * time.Minute, at(5, 12, 0, 0), at(5, 12, 1, 59), time.Second},
+		{"exactly when it ends", 2 * time.Minute, at(5, 12, 0, 0), at(5, 12, 2, 0), 0},
+		{"after it ends", 2 * time.Minute, at(5, 12, 0, 0), at(5, 12, 5, 0), 0},
+		{"across midnight", 2 * time.Minute, at(5, 23, 59, 30), at(6, 0, 0, 30), 0},
+		{"evening break before the next morning", 12 * time.Hour, at(5, 22, 55, 0), at(6, 9, 5, 0), 0},
+		{"same day in the configured timezone", 12 * time.Hour, at(6, 0, 30, 0), at(6, 9, 5, 0), 3*time.Hour + 25*time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := newTestService(t, tt.cooldown)
+			svc.location = msk
+			svc.addUsers(t, 1, 2)
+			svc.addSession(t, &domain.Session{
+				InitiatorID: 1,
+				ChatID:      1,
+				Status:      domain.SessionStatusCompleted,
+				CreatedAt:   tt.last,
+			})
+			svc.now = func() time.Time { return tt.now }
+
+			session, err := svc.StartSession(1, 1, 0, "")
+
+			var cooldownErr *CooldownError
+			if tt.wantWait == 0 {
+				if err != nil {
+					t.Fatalf("StartSession: %v, want it allowed", err)
+				}
+				if session == nil {
+					t.Fatal("StartSession returned no session")
+				}
+				return
+			}
+			if !errors.As(err, &cooldownErr) {
+				t.Fatalf("StartSession error = %v, want a cooldown", err)
+			}
+			if cooldownErr.Remaining != tt.wantWait {
+				t.Errorf("remaining cooldown = %s, want %s", cooldownErr.Remaining, tt.wantWait)
+			}
+		})
+	}
+}
+
+func TestAutoCompleteOldSessionsAtTimeout(t *testing.T) {
+	created := time.Date(2024, 3, 5, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		age           time.Duration
+		wantCompleted bool
+	}{
+		{"just started", time.Minute, false},
+		{"one second before the timeout", testTimeout - time.Second, false},
+		{"exactly at the timeout", testTimeout, false},
+		{"one second past the timeout", testTimeout + time.Second, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := newTestService(t, 0)
+			svc.addUsers(t, 1, 2)
+			session := svc.addSession(t, &domain.Session{
+				InitiatorID: 1,
+				ChatID:      1,
+				Status:      domain.SessionStatusActive,
+				CreatedAt:   created,
+			})
+			svc.now = func() time.Time { return created.Add(tt.age) }
+
+			completed, err := svc.AutoCompleteOldSessions(0, 0)
+			if err != nil {
+				t.Fatalf("AutoCompleteOldSessions: %v", err)
+			}
+			if got := len(completed) == 1; got != tt.wantCompleted {
+				t.Fatalf("completed %d sessions, want completed = %v", len(completed), tt.wantCompleted)
+			}
+
+			stored, err := svc.GetSession(session.ID)
+			if err != nil {
+				t.Fatalf("GetSession: %v", err)
+			}
+			wantStatus := domain.SessionStatusActive
+			if tt.wantCompleted {
+				wantStatus = domain.SessionStatusCompleted
+			}
+			if stored.Status != wantStatus {
+				t.Errorf("status = %s, want %s", stored.Status, wantStatus)
+			}
+		})
+	}
+}
+
+func TestGetActiveUsersExcludesUnavailableUsers(t *testing.T) {
+	svc := newTestService(t, 0)
+	svc.addUsers(t, 1, 2, 3, 4, 5)
+
+	if err := svc.SetRemoteStatus(2); err != nil {
+		t.Fatalf("SetRemoteStatus: %v", err)
+	}
+	if err := svc.SetHidden(3, true); err != nil {
+		t.Fatalf("SetHidden: %v", err)
+	}
+	if err := svc.SetMuted(4, true); err != nil {
+		t.Fatalf("SetMuted: %v", err)
+	}
+
+	users, err := svc.GetActiveUsers(1)
+	if err != nil {
+		t.Fatalf("GetActiveUsers: %v", err)
+	}
+
+	var ids []int64
+	for _, user := range users {
+		ids = append(ids, user.ID)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	// 1 is the initiator, 2 is remote, 3 hidden and 4 muted
+	if len(ids) != 1 || ids[0] != 5 {
+		t.Errorf("active users = %v, want [5]", ids)
+	}
+}
+
+func TestUnreachableUserComesBack(t *testing.T) {
+	svc := newTestService(t, 0)
+	svc.addUsers(t, 1, 2, 3)
+
+	if err := svc.SetMuted(3, true); err != nil {
+		t.Fatalf("SetMuted: %v", err)
+	}
+	for _, id := range []int64{2, 3} {
+		if err := svc.MarkUserUnreachable(id); err != nil {
+			t.Fatalf("MarkUserUnreachable(%d): %v", id, err)
+		}
+	}
+
+	activeIDs := func() []int64 {
+		t.Helper()
+
+		users, err := svc.GetActiveUsers(1)
+		if err != nil {
+			t.Fatalf("GetActiveUsers: %v", err)
+		}
+		var ids []int64
+		for _, user := range users {
+			ids = append(ids, user.ID)
+		}
+		return ids
+	}
+
+	if ids := activeIDs(); len(ids) != 0 {
+		t.Fatalf("active users = %v while unreachable, want none", ids)
+	}
+
+	// Writing to the bot again, with an unchanged profile, shows they
+	// unblocked it; 3 had muted invitations themselves and stays muted
+	svc.addUsers(t, 2, 3)
+
+	if ids := activeIDs(); len(ids) != 1 || ids[0] != 2 {
+		t.Errorf("active users = %v after coming back, want [2]", ids)
+	}
+}
+
+func TestGetCurrentStreakFollowsWorkCalendar(t *testing.T) {
+	msk := time.FixedZone("MSK", 3*60*60)
+	calendar := config.WorkingHours{
+		Location: msk,
+		Holidays: map[string]bool{"2024-03-05": true}, // a Tuesday
+	}
+	// Wednesday morning, before today's first break
+	now := time.Date(2024, 3, 6, 9, 30, 0, 0, msk)
+
+	tests := []struct {
+		name     string
+		sessions []time.Time
+		want     int
+	}{
+		{
+			name: "holiday and weekend don't break the streak",
+			sessions: []time.Time{
+				time.Date(2024, 3, 4, 12, 0, 0, 0, msk), // Monday
+				time.Date(2024, 3, 1, 12, 0, 0, 0, msk), // Friday
+				time.Date(2024, 2, 29, 12, 0, 0, 0, msk),
+			},
+			want: 3,
+		},
+		{
+			name: "days are taken in the calendar's timezone",
+			sessions: []time.Time{
+				// Sunday night in UTC is already Monday in Moscow
+				time.Date(2024, 3, 3, 22, 30, 0, 0, time.UTC),
+				time.Date(2024, 3, 1, 12, 0, 0, 0, msk),
+			},
+			want: 2,
+		},
+		{
+			name: "a missed working day ends the streak",
+			sessions: []time.Time{
+				time.Date(2024, 3, 4, 12, 0, 0, 0, msk),
+				time.Date(2024, 2, 29, 12, 0, 0, 0, msk), // Friday is missing
+			},
+			want: 1,
+		},
+		{
+			name: "today counts once attended",
+			sessions: []time.Time{
+				time.Date(2024, 3, 6, 9, 0, 0, 0, msk),
+				time.Date(2024, 3, 4, 12, 0, 0, 0, msk),
+			},
+			want: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := newTestService(t, 0)
+			svc.addUsers(t, 1, 2)
+			svc.now = func() time.Time { return now }
+
+			for _, created := range tt.sessions {
+				session := svc.addSession(t, &domain.Session{
+					InitiatorID: 1,
+					ChatID:      1,
+					Status:      domain.SessionStatusCompleted,
+					CreatedAt:   created,
+				})
+				svc.attend(t, session.ID, 2)
+			}
+
+			streak, err := svc.GetCurrentStreak(2, calendar, msk)
+			if err != nil {
+				t.Fatalf("GetCurrentStreak: %v", err)
+			}
+			if streak != tt.want {
+				t.Errorf("streak = %d, want %d", streak, tt.want)
+			}
+		})
+	}
+}
+
+func TestAttendanceCountsOnlyConfirmedAcceptances(t *testing.T) {
+	weekStart := time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)
+	completedAt := weekStart.Add(26 * time.Hour)
+
+	svc := newTestService(t, 0)
+	svc.addUsers(t, 1, 2, 3, 4)
+	session := svc.addSession(t, &domain.Session{
+		InitiatorID: 1,
+		ChatID:      -100,
+		Status:      domain.SessionStatusCompleted,
+		CreatedAt:   weekStart.Add(25 * time.Hour),
+		CompletedAt: &completedAt,
+	})
+
+	ctx := context.Background()
+	svc.attend(t, session.ID, 2)
+	for _, userID := range []int64{3, 4} {
+		response := &domain.SessionResponse{SessionID: session.ID, UserID: userID, Response: domain.ResponseAccepted}
+		if err := svc.sessions.AddResponse(ctx, response); err != nil {
+			t.Fatalf("AddResponse: %v", err)
+		}
+	}
+	// 3 never answered whether they came, 4 said they didn't
+	if err := svc.sessions.SetAttendance(ctx, session.ID, 4, false); err != nil {
+		t.Fatalf("SetAttendance: %v", err)
+	}
+
+	history, err := svc.GetUserHistory(1, 10)
+	if err != nil {
+		t.Fatalf("GetUserHistory: %v", err)
+	}
+	if len(history) != 1 || history[0].Attendees != 1 {
+		t.Errorf("history = %+v, want one session with 1 attendee", history)
+	}
+
+	digest, err := svc.GetWeeklyDigest(weekStart)
+	if err != nil {
+		t.Fatalf("GetWeeklyDigest: %v", err)
+	}
+	if digest.TotalBreaks != 1 || digest.AverageAttendees != 1 {
+		t.Errorf("digest has %d breaks averaging %.1f attendees, want 1 averaging 1", digest.TotalBreaks, digest.AverageAttendees)
+	}
+
+	leaders, err := svc.GetLeaderboard(time.Time{}, 10)
+	if err != nil {
+		t.Fatalf("GetLeaderboard: %v", err)
+	}
+	if len(leaders) != 1 || leaders[0].UserID != 2 {
+		t.Errorf("leaderboard = %+v, want only user 2", leaders)
+	}
+}
+
+func TestGetSessionSummaryLanguage(t *testing.T) {
+	svc := newTestService(t, 0)
+	svc.addUsers(t, 1, 2)
+	session := svc.addSession(t, &domain.Session{
+		InitiatorID: 1,
+		ChatID:      1,
+		Status:      domain.SessionStatusActive,
+		CreatedAt:   time.Now(),
+	})
+	svc.attend(t, session.ID, 2)
+
+	tests := []struct {
+		lang string
+		want []string
+	}{
+		{"ru", []string{"Статус перекура", "Идут сейчас", "@user2", "Идёт 0 мин"}},
+		{"en", []string{"Break status", "Going now", "@user2", "Running for 0 min"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.lang, func(t *testing.T) {
+			summary, err := svc.GetSessionSummary(session.ID, tt.lang, false)
+			if err != nil {
+				t.Fatalf("GetSessionSummary: %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(summary, want) {
+					t.Errorf("summary %q doesn't contain %q", summary, want)
+				}
+			}
+		})
+	}
+}